@@ -35,12 +35,12 @@ export const useDatabaseStore = defineStore('database', () => {
     }
   }
 
-  async function selectDatabase(dbPath) {
+  async function selectDatabase(dbPath, passphrase = '') {
     isLoading.value = true
     error.value = null
     
     try {
-      await SwitchDatabase(dbPath)
+      await SwitchDatabase(dbPath, passphrase)
       currentDatabase.value = databases.value.find(db => db.path === dbPath)
       isConnected.value = true
     } catch (err) {
@@ -52,13 +52,13 @@ export const useDatabaseStore = defineStore('database', () => {
     }
   }
 
-  async function createDatabase(name) {
+  async function createDatabase(name, passphrase = '') {
       isLoading.value = true
       error.value = null
       
       try {
         console.log('[Store] Creating database:', name)
-        await CreateDatabase(name)
+        await CreateDatabase(name, passphrase)
         console.log('[Store] Database created successfully')
         await loadDatabases()
       } catch (err) {
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"stoktakip/internal/services"
+
+	"gorm.io/gorm"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// ErrorBody is the JSON body of every error response
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes an error; Code is stable, Message is for people
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiError is an error raised by the API layer itself with its status
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string { return e.message }
+
+// badRequest reports a malformed request
+func badRequest(message string) error {
+	return &apiError{status: http.StatusBadRequest, code: "bad_request", message: message}
+}
+
+// notFound reports that the record named in the route does not exist. A
+// missing record referenced from the request body is invalid, not missing.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &apiError{status: http.StatusNotFound, code: "not_found", message: err.Error()}
+	}
+	return err
+}
+
+// classify maps a service error to an HTTP status and error code
+func classify(err error) (int, string) {
+	var apiErr *apiError
+	var conflict *services.ConflictError
+	var sqliteErr *sqlite.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.status, apiErr.code
+	case errors.Is(err, services.ErrNotAuthenticated):
+		return http.StatusUnauthorized, "unauthorized"
+	case errors.Is(err, services.ErrPermissionDenied):
+		return http.StatusForbidden, "forbidden"
+	case errors.As(err, &conflict):
+		return http.StatusConflict, "conflict"
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// The route's own record goes through notFound, so this one is a
+		// category, product, unit or location the body refers to
+		return http.StatusUnprocessableEntity, "invalid"
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return http.StatusConflict, "conflict"
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return http.StatusUnprocessableEntity, "invalid"
+		}
+		return http.StatusInternalServerError, "internal"
+	case err.Error() == "no database connection":
+		return http.StatusServiceUnavailable, "no_database"
+	case errors.Unwrap(err) != nil:
+		// Services wrap the errors of the database and file system
+		return http.StatusInternalServerError, "internal"
+	}
+	// Everything else is a validation message such as "code and name are required"
+	return http.StatusUnprocessableEntity, "invalid"
+}
+
+// writeError writes err as an ErrorBody with the status it maps to.
+// Internal errors are logged and not shown to the client.
+func writeError(w http.ResponseWriter, err error) {
+	status, code := classify(err)
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Printf("API error: %v", err)
+		message = "internal server error"
+	}
+	writeJSON(w, status, ErrorBody{Error: ErrorDetail{Code: code, Message: message}})
+}
+
+// writeJSON writes v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("API error writing response: %v", err)
+	}
+}
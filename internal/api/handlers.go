@@ -0,0 +1,347 @@
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"stoktakip/internal/config"
+	"stoktakip/internal/models"
+	"stoktakip/internal/services"
+)
+
+// openAPISpec describes the endpoints below
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// maxBodyBytes limits request bodies
+const maxBodyBytes = 1 << 20
+
+// routes registers the endpoints. Reading needs a viewer token, creating and
+// updating a clerk token and deleting an admin token, as in the desktop app.
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(openAPISpec)
+	})
+
+	mux.HandleFunc("GET /api/v1/categories", s.authorized(models.RoleViewer, s.listCategories))
+	mux.HandleFunc("POST /api/v1/categories", s.authorized(models.RoleClerk, s.createCategory))
+	mux.HandleFunc("GET /api/v1/categories/{id}", s.authorized(models.RoleViewer, s.getCategory))
+	mux.HandleFunc("PUT /api/v1/categories/{id}", s.authorized(models.RoleClerk, s.updateCategory))
+	mux.HandleFunc("DELETE /api/v1/categories/{id}", s.authorized(models.RoleAdmin, s.deleteCategory))
+
+	mux.HandleFunc("GET /api/v1/products", s.authorized(models.RoleViewer, s.listProducts))
+	mux.HandleFunc("POST /api/v1/products", s.authorized(models.RoleClerk, s.createProduct))
+	mux.HandleFunc("GET /api/v1/products/{id}", s.authorized(models.RoleViewer, s.getProduct))
+	mux.HandleFunc("PUT /api/v1/products/{id}", s.authorized(models.RoleClerk, s.updateProduct))
+	mux.HandleFunc("DELETE /api/v1/products/{id}", s.authorized(models.RoleAdmin, s.deleteProduct))
+
+	mux.HandleFunc("GET /api/v1/movements", s.authorized(models.RoleViewer, s.listMovements))
+	mux.HandleFunc("POST /api/v1/movements", s.authorized(models.RoleClerk, s.createMovement))
+	mux.HandleFunc("GET /api/v1/movements/{id}", s.authorized(models.RoleViewer, s.getMovement))
+	mux.HandleFunc("DELETE /api/v1/movements/{id}", s.authorized(models.RoleAdmin, s.deleteMovement))
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, ErrorBody{Error: ErrorDetail{
+			Code:    "not_found",
+			Message: fmt.Sprintf("no endpoint %s %s", r.Method, r.URL.Path),
+		}})
+	})
+
+	return mux
+}
+
+// Category handlers
+
+func (s *Server) listCategories(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	categories, err := s.categories.GetAll()
+	respond(w, http.StatusOK, categories, err)
+}
+
+func (s *Server) getCategory(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	category, err := s.categories.GetByID(id)
+	respond(w, http.StatusOK, category, notFound(err))
+}
+
+func (s *Server) createCategory(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	var dto services.CategoryDTO
+	if err := decodeBody(w, r, &dto); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var category *services.CategoryDTO
+	err := s.write(token, func() (err error) {
+		category, err = s.categories.Create(dto)
+		return err
+	})
+	if err == nil {
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/categories/%d", category.ID))
+	}
+	respond(w, http.StatusCreated, category, err)
+}
+
+func (s *Server) updateCategory(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	id, err := pathID(r)
+	if err == nil {
+		var dto services.CategoryDTO
+		err = decodeBody(w, r, &dto)
+		if err == nil && dto.Name == "" {
+			err = badRequest("category name cannot be empty")
+		}
+		if err == nil {
+			var category *services.CategoryDTO
+			err = s.write(token, func() (err error) {
+				if _, err = s.categories.GetByID(id); err != nil {
+					return notFound(err)
+				}
+				category, err = s.categories.Update(id, dto)
+				return err
+			})
+			respond(w, http.StatusOK, category, err)
+			return
+		}
+	}
+	writeError(w, err)
+}
+
+func (s *Server) deleteCategory(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	id, err := pathID(r)
+	if err == nil {
+		err = s.write(token, func() error {
+			if _, err := s.categories.GetByID(id); err != nil {
+				return notFound(err)
+			}
+			return s.categories.Delete(id)
+		})
+	}
+	respond(w, http.StatusNoContent, nil, err)
+}
+
+// Product handlers
+
+// listProducts returns all products; ?low=true limits them to those at or
+// below their critical limit and ?code= finds one product by code
+func (s *Server) listProducts(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	query := r.URL.Query()
+
+	if code := query.Get("code"); code != "" {
+		product, err := s.products.GetByCode(code)
+		if err != nil {
+			writeError(w, notFound(err))
+			return
+		}
+		respond(w, http.StatusOK, []services.ProductDTO{*product}, nil)
+		return
+	}
+
+	low, err := boolParam(r, "low")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var products []services.ProductDTO
+	if low {
+		products, err = s.products.GetLowStock()
+	} else {
+		products, err = s.products.GetAll()
+	}
+	respond(w, http.StatusOK, products, err)
+}
+
+func (s *Server) getProduct(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	product, err := s.products.GetByID(id)
+	respond(w, http.StatusOK, product, notFound(err))
+}
+
+func (s *Server) createProduct(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	var dto services.ProductDTO
+	if err := decodeBody(w, r, &dto); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var product *services.ProductDTO
+	err := s.write(token, func() (err error) {
+		product, err = s.products.Create(dto)
+		return err
+	})
+	if err == nil {
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/products/%d", product.ID))
+	}
+	respond(w, http.StatusCreated, product, err)
+}
+
+func (s *Server) updateProduct(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	id, err := pathID(r)
+	if err == nil {
+		var dto services.ProductDTO
+		err = decodeBody(w, r, &dto)
+		if err == nil && (dto.Code == "" || dto.Name == "") {
+			err = badRequest("product code and name cannot be empty")
+		}
+		if err == nil {
+			var product *services.ProductDTO
+			err = s.write(token, func() (err error) {
+				if _, err = s.products.GetByID(id); err != nil {
+					return notFound(err)
+				}
+				product, err = s.products.Update(id, dto)
+				return err
+			})
+			respond(w, http.StatusOK, product, err)
+			return
+		}
+	}
+	writeError(w, err)
+}
+
+func (s *Server) deleteProduct(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	id, err := pathID(r)
+	if err == nil {
+		err = s.write(token, func() error {
+			if _, err := s.products.GetByID(id); err != nil {
+				return notFound(err)
+			}
+			return s.products.Delete(id)
+		})
+	}
+	respond(w, http.StatusNoContent, nil, err)
+}
+
+// Movement handlers
+
+// listMovements returns movements, newest first; ?product_id= limits them to one product
+func (s *Server) listMovements(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	var movements []services.MovementDTO
+	var err error
+	if value := r.URL.Query().Get("product_id"); value != "" {
+		var productID uint
+		if productID, err = parseID(value); err == nil {
+			movements, err = s.movements.GetByProduct(productID)
+		}
+	} else {
+		movements, err = s.movements.GetAll()
+	}
+	respond(w, http.StatusOK, movements, err)
+}
+
+func (s *Server) getMovement(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	movement, err := s.movements.GetByID(id)
+	respond(w, http.StatusOK, movement, notFound(err))
+}
+
+func (s *Server) createMovement(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	var dto services.MovementDTO
+	if err := decodeBody(w, r, &dto); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var movement *services.MovementDTO
+	err := s.write(token, func() (err error) {
+		movement, err = s.movements.Create(dto)
+		return err
+	})
+	if err == nil {
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/movements/%d", movement.ID))
+	}
+	respond(w, http.StatusCreated, movement, err)
+}
+
+func (s *Server) deleteMovement(w http.ResponseWriter, r *http.Request, token *config.APIToken) {
+	id, err := pathID(r)
+	if err == nil {
+		err = s.write(token, func() error {
+			if _, err := s.movements.GetByID(id); err != nil {
+				return notFound(err)
+			}
+			return s.movements.Delete(id)
+		})
+	}
+	respond(w, http.StatusNoContent, nil, err)
+}
+
+// Helpers
+
+// respond writes v with status, or err as an error body
+func respond(w http.ResponseWriter, status int, v interface{}, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	writeJSON(w, status, v)
+}
+
+// decodeBody reads a JSON request body into v
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err := decoder.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			return &apiError{status: http.StatusRequestEntityTooLarge, code: "too_large", message: "request body is too large"}
+		case errors.Is(err, io.EOF):
+			return badRequest("request body is empty")
+		}
+		return badRequest(fmt.Sprintf("invalid JSON body: %v", err))
+	}
+	if decoder.More() {
+		return badRequest("request body must be a single JSON object")
+	}
+	return nil
+}
+
+// pathID parses the {id} path segment
+func pathID(r *http.Request) (uint, error) {
+	return parseID(r.PathValue("id"))
+}
+
+// parseID parses a positive record ID
+func parseID(value string) (uint, error) {
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		return 0, badRequest(fmt.Sprintf("invalid id '%s'", value))
+	}
+	return uint(id), nil
+}
+
+// boolParam parses an optional true/false query parameter
+func boolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, badRequest(fmt.Sprintf("invalid %s '%s': use true or false", name, value))
+	}
+	return b, nil
+}
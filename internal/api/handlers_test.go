@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"stoktakip/internal/config"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"stoktakip/internal/services"
+)
+
+func TestErrorStatus(t *testing.T) {
+	dbManager := database.GetConnectionManager()
+	if err := dbManager.Connect(filepath.Join(t.TempDir(), "api.db")); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer dbManager.Close()
+
+	s := NewServer(dbManager)
+	s.settings.Tokens = []config.APIToken{{Name: "test", Role: models.RoleAdmin, Hash: HashToken("secret")}}
+	handler := s.routes()
+
+	product, err := s.products.Create(services.ProductDTO{Code: "P-1", Name: "Bolt", CategoryID: 1, Unit: "adet"})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"missing product", "GET", "/api/v1/products/999", "", http.StatusNotFound},
+		{"missing product code", "GET", "/api/v1/products?code=NONE", "", http.StatusNotFound},
+		{"update of a missing product", "PUT", "/api/v1/products/999", `{"code":"X","name":"X","category_id":1,"unit":"adet"}`, http.StatusNotFound},
+		{"delete of a missing movement", "DELETE", "/api/v1/movements/999", "", http.StatusNotFound},
+		{"product with a missing category", "POST", "/api/v1/products", `{"code":"P-2","name":"Nut","category_id":999,"unit":"adet"}`, http.StatusUnprocessableEntity},
+		{"product update to a missing category", "PUT", "/api/v1/products/1", `{"code":"P-1","name":"Bolt","category_id":999,"unit":"adet"}`, http.StatusUnprocessableEntity},
+		{"movement of a missing product", "POST", "/api/v1/movements", `{"product_id":999,"type":"IN","quantity":1}`, http.StatusUnprocessableEntity},
+		{"movement to a missing location", "POST", "/api/v1/movements", `{"product_id":1,"location_id":999,"type":"IN","quantity":1}`, http.StatusUnprocessableEntity},
+		{"product update with an empty name", "PUT", "/api/v1/products/1", `{"code":"P-1","category_id":1,"unit":"adet"}`, http.StatusBadRequest},
+		{"duplicate product code", "POST", "/api/v1/products", `{"code":"P-1","name":"Bolt","category_id":1,"unit":"adet"}`, http.StatusConflict},
+		{"insufficient stock", "POST", "/api/v1/movements", `{"product_id":1,"type":"OUT","quantity":1}`, http.StatusConflict},
+		{"invalid quantity", "POST", "/api/v1/movements", `{"product_id":1,"type":"IN","quantity":0}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		request.Header.Set("Authorization", "Bearer secret")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code != tt.status {
+			t.Errorf("%s: %s %s = %d %s, want %d", tt.name, tt.method, tt.path, response.Code, strings.TrimSpace(response.Body.String()), tt.status)
+		}
+	}
+
+	if got, _ := s.products.GetByID(product.ID); got.CategoryID != 1 {
+		t.Errorf("refused update changed the category to %d", got.CategoryID)
+	}
+}
@@ -0,0 +1,224 @@
+// Package api serves categories, products and movements over a local
+// HTTP/JSON API so other programs, such as a POS, can read stock and post
+// movements while the desktop app is running.
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"stoktakip/internal/config"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"stoktakip/internal/services"
+)
+
+// Status tells the UI whether the API is listening
+type Status struct {
+	Enabled bool   `json:"enabled"`
+	Running bool   `json:"running"`
+	Address string `json:"address"`
+	Error   string `json:"error"` // Why the server is not running although enabled
+}
+
+// Server is the HTTP API. It has its own service instances so that changes
+// made through the API are audited under the token's name rather than the
+// user logged in to the desktop app.
+type Server struct {
+	audit      *services.AuditService
+	categories *services.CategoryService
+	products   *services.ProductService
+	movements  *services.MovementService
+
+	// API writes run one at a time so the audit actor is the writing token
+	writeMu sync.Mutex
+	actor   string
+
+	mu         sync.Mutex
+	settings   config.APIConfig
+	httpServer *http.Server
+	lastError  string
+}
+
+// shutdownTimeout is how long Stop waits for running requests
+const shutdownTimeout = 5 * time.Second
+
+// NewServer creates a stopped API server
+func NewServer(dbManager *database.ConnectionManager) *Server {
+	audit := services.NewAuditService(dbManager)
+	// Never logged in: movements posted through the API have no desktop user
+	auth := services.NewAuthService(dbManager, audit)
+
+	s := &Server{
+		audit:      audit,
+		categories: services.NewCategoryService(dbManager, audit),
+		products:   services.NewProductService(dbManager, audit),
+		movements:  services.NewMovementService(dbManager, audit, auth),
+	}
+	audit.SetActor(func() string { return s.actor })
+	return s
+}
+
+// Configure applies new settings, starting, restarting or stopping the
+// listener as needed. Token changes apply without a restart.
+func (s *Server) Configure(settings config.APIConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	running := s.httpServer != nil
+	sameAddress := running && s.httpServer.Addr == settings.Address
+	s.settings = settings
+
+	if running && (!settings.Enabled || !sameAddress) {
+		s.stopLocked()
+	}
+	if !settings.Enabled || sameAddress {
+		s.lastError = ""
+		return nil
+	}
+	return s.startLocked()
+}
+
+// startLocked listens on the configured address and serves in the background
+func (s *Server) startLocked() error {
+	if err := s.settings.Validate(); err != nil {
+		s.lastError = err.Error()
+		return err
+	}
+
+	listener, err := net.Listen("tcp", s.settings.Address)
+	if err != nil {
+		s.lastError = err.Error()
+		return fmt.Errorf("failed to start API server: %w", err)
+	}
+
+	server := &http.Server{
+		Addr:              s.settings.Address,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.httpServer = server
+	s.lastError = ""
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("API server stopped: %v", err)
+			s.mu.Lock()
+			if s.httpServer == server {
+				s.httpServer = nil
+				s.lastError = err.Error()
+			}
+			s.mu.Unlock()
+		}
+	}()
+	log.Printf("API server listening on %s", s.settings.Address)
+	return nil
+}
+
+// Stop shuts the server down, waiting briefly for running requests
+func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopLocked()
+}
+
+func (s *Server) stopLocked() {
+	if s.httpServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		log.Printf("Error stopping API server: %v", err)
+	}
+	s.httpServer = nil
+	log.Printf("API server stopped")
+}
+
+// Status returns whether the server is listening and where
+func (s *Server) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Status{
+		Enabled: s.settings.Enabled,
+		Running: s.httpServer != nil,
+		Address: s.settings.Address,
+		Error:   s.lastError,
+	}
+}
+
+// NewToken generates a random API token and the hash to store in the config
+func NewToken() (token, hash string, err error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token = "stk_" + hex.EncodeToString(secret)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex SHA-256 of a token, as stored in config.APIToken
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticate finds the configured token sent as "Authorization: Bearer <token>"
+func (s *Server) authenticate(r *http.Request) (*config.APIToken, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, fmt.Errorf("%w: missing bearer token", services.ErrNotAuthenticated)
+	}
+	hash := []byte(HashToken(strings.TrimSpace(token)))
+
+	s.mu.Lock()
+	tokens := s.settings.Tokens
+	s.mu.Unlock()
+
+	for i := range tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(tokens[i].Hash)) == 1 {
+			found := tokens[i]
+			return &found, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: invalid token", services.ErrNotAuthenticated)
+}
+
+// authorized wraps a handler so it only runs for a token with at least role
+func (s *Server) authorized(role models.Role, handler func(w http.ResponseWriter, r *http.Request, token *config.APIToken)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := s.authenticate(r)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="stoktakip"`)
+			writeError(w, err)
+			return
+		}
+		if !token.Role.Includes(role) {
+			writeError(w, fmt.Errorf("%w: requires %s role", services.ErrPermissionDenied, role))
+			return
+		}
+		handler(w, r, token)
+	}
+}
+
+// write runs a change with the token as the audit actor
+func (s *Server) write(token *config.APIToken, change func() error) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.actor = "api:" + token.Name
+	return change()
+}
@@ -4,24 +4,49 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"stoktakip/internal/api"
 	"stoktakip/internal/config"
 	"stoktakip/internal/database"
+	"stoktakip/internal/models"
 	"stoktakip/internal/services"
 	"stoktakip/internal/utils"
+	"strings"
+	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // App struct
 type App struct {
-	ctx             context.Context
-	pathManager     *utils.PathManager
-	configManager   *config.Manager
-	dbManager       *database.ConnectionManager
-	databaseService *services.DatabaseService
-	productService  *services.ProductService
-	categoryService *services.CategoryService
-	movementService *services.MovementService
+	ctx              context.Context
+	pathManager      *utils.PathManager
+	configManager    *config.Manager
+	dbManager        *database.ConnectionManager
+	databaseService  *services.DatabaseService
+	productService   *services.ProductService
+	categoryService  *services.CategoryService
+	movementService  *services.MovementService
+	reconcileService *services.ReconciliationService
+	locationService  *services.LocationService
+	unitService      *services.UnitService
+	auditService     *services.AuditService
+	authService      *services.AuthService
+	backupScheduler  *services.BackupScheduler
+	importService    *services.ImportService
+	exportService    *services.ExportService
+	reportService    *services.ReportService
+	barcodeService   *services.BarcodeService
+	labelService     *services.LabelService
+	countService     *services.StockCountService
+	supplierService  *services.SupplierService
+	purchaseService  *services.PurchaseOrderService
+	customerService  *services.CustomerService
+	salesService     *services.SalesOrderService
+	reserveService   *services.ReservationService
+	expirer          *services.ReservationExpirer
+	apiServer        *api.Server
 }
 
 // NewApp creates a new App application struct
@@ -50,18 +75,66 @@ func NewApp() (*App, error) {
 
 	// Initialize services immediately
 	databaseService := services.NewDatabaseService(dbManager, pathManager, configManager)
-	productService := services.NewProductService(dbManager)
-	categoryService := services.NewCategoryService(dbManager)
-	movementService := services.NewMovementService(dbManager)
+	auditService := services.NewAuditService(dbManager)
+	authService := services.NewAuthService(dbManager, auditService)
+	productService := services.NewProductService(dbManager, auditService)
+	categoryService := services.NewCategoryService(dbManager, auditService)
+	movementService := services.NewMovementService(dbManager, auditService, authService)
+	reconcileService := services.NewReconciliationService(dbManager, auditService)
+	locationService := services.NewLocationService(dbManager)
+	unitService := services.NewUnitService(dbManager)
+	importService := services.NewImportService(dbManager, productService, categoryService, movementService)
+	exportService := services.NewExportService(dbManager, configManager)
+	reportService := services.NewReportService(dbManager, productService, configManager)
+	barcodeService := services.NewBarcodeService(dbManager, auditService, productService)
+	labelService := services.NewLabelService(dbManager, configManager)
+	countService := services.NewStockCountService(dbManager, auditService, authService, movementService)
+	supplierService := services.NewSupplierService(dbManager, auditService)
+	purchaseService := services.NewPurchaseOrderService(dbManager, auditService, authService, movementService)
+	customerService := services.NewCustomerService(dbManager, auditService)
+	salesService := services.NewSalesOrderService(dbManager, auditService, authService, movementService)
+	reserveService := services.NewReservationService(dbManager, auditService, authService)
+
+	// Verify the stock ledger every time a database is opened
+	dbManager.OnConnect(reconcileService.VerifyOnConnect)
+
+	backupScheduler := services.NewBackupScheduler(databaseService, dbManager, configManager.GetBackupSettings())
+	dbManager.OnConnect(backupScheduler.OnConnect)
+
+	expirer := services.NewReservationExpirer(reserveService, dbManager)
+	dbManager.OnConnect(expirer.OnConnect)
+
+	// Users are stored per database: opening one requires a new login
+	dbManager.OnConnect(authService.OnConnect)
+	auditService.SetActor(authService.ActorName)
 
 	app := &App{
-		pathManager:     pathManager,
-		configManager:   configManager,
-		dbManager:       dbManager,
-		databaseService: databaseService,
-		productService:  productService,
-		categoryService: categoryService,
-		movementService: movementService,
+		pathManager:      pathManager,
+		configManager:    configManager,
+		dbManager:        dbManager,
+		databaseService:  databaseService,
+		productService:   productService,
+		categoryService:  categoryService,
+		movementService:  movementService,
+		reconcileService: reconcileService,
+		locationService:  locationService,
+		unitService:      unitService,
+		auditService:     auditService,
+		authService:      authService,
+		backupScheduler:  backupScheduler,
+		importService:    importService,
+		exportService:    exportService,
+		reportService:    reportService,
+		barcodeService:   barcodeService,
+		labelService:     labelService,
+		countService:     countService,
+		supplierService:  supplierService,
+		purchaseService:  purchaseService,
+		customerService:  customerService,
+		salesService:     salesService,
+		reserveService:   reserveService,
+		expirer:          expirer,
+		apiServer:        api.NewServer(dbManager),
 	}
 
 	return app, nil
@@ -78,7 +151,10 @@ func (a *App) Startup(ctx context.Context) {
 	if lastDB != "" {
 		// Convert filename to full path
 		fullPath := a.pathManager.GetDatabasePath(lastDB)
-		if a.pathManager.FileExists(fullPath) {
+		if encrypted, _ := database.IsEncrypted(fullPath); encrypted {
+			// The passphrase is asked for when the user picks the database
+			log.Printf("Last database is encrypted, waiting for passphrase: %s", lastDB)
+		} else if a.pathManager.FileExists(fullPath) {
 			if err := a.dbManager.Connect(fullPath); err != nil {
 				log.Printf("Warning: Failed to connect to last database: %v", err)
 			} else {
@@ -86,12 +162,37 @@ func (a *App) Startup(ctx context.Context) {
 			}
 		}
 	}
+
+	// Back up the active database on the configured interval
+	a.backupScheduler.Start()
+
+	// Release reservations as they expire
+	a.expirer.Start()
+
+	// Serve the HTTP API if enabled
+	if err := a.apiServer.Configure(a.configManager.GetAPISettings()); err != nil {
+		log.Printf("Warning: Failed to start API server: %v", err)
+	}
 }
 
 // Shutdown is called when the app is closing
 func (a *App) Shutdown(ctx context.Context) {
 	log.Println("Application shutting down")
 
+	// Stop serving the API before the database closes
+	a.apiServer.Stop()
+	a.expirer.Stop()
+
+	// Stop timed backups, then take the shutdown backup if enabled
+	a.backupScheduler.Stop()
+	if settings := a.configManager.GetBackupSettings(); settings.Enabled && settings.OnShutdown && a.dbManager.IsConnected() {
+		if path, err := a.backupScheduler.BackupNow(); err != nil {
+			log.Printf("Error backing up database: %v", err)
+		} else {
+			log.Printf("Shutdown backup written: %s", path)
+		}
+	}
+
 	// Close database connection
 	if err := a.dbManager.Close(); err != nil {
 		log.Printf("Error closing database: %v", err)
@@ -130,14 +231,190 @@ func (a *App) ListDatabases() ([]services.DatabaseInfo, error) {
 	return a.databaseService.ListDatabases()
 }
 
-// CreateDatabase creates a new database
-func (a *App) CreateDatabase(name string) error {
-	return a.databaseService.CreateDatabase(name)
+// CreateDatabase creates a new database, encrypted when a passphrase is given
+func (a *App) CreateDatabase(name, passphrase string) error {
+	return a.databaseService.CreateDatabase(name, passphrase)
+}
+
+// SwitchDatabase switches to a different database; encrypted ones need their passphrase
+func (a *App) SwitchDatabase(path, passphrase string) error {
+	return a.databaseService.SwitchDatabase(path, passphrase)
+}
+
+// BackupDatabase writes a verified backup of the current database and returns its path
+func (a *App) BackupDatabase() (string, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return "", err
+	}
+	return a.backupScheduler.BackupNow()
+}
+
+// ListBackups returns the backups of the current database, newest first
+func (a *App) ListBackups() ([]services.BackupInfo, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.databaseService.ListBackups()
+}
+
+// GetBackupSummary returns the row counts of a backup; the passphrase is
+// only needed for backups made with an older passphrase
+func (a *App) GetBackupSummary(backupPath, passphrase string) (*services.DatabaseSummary, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.databaseService.BackupSummary(backupPath, passphrase)
+}
+
+// PreviewRestore shows what restoring a backup would change; the passphrase
+// is only needed for backups made with an older passphrase
+func (a *App) PreviewRestore(backupPath, passphrase string) (*services.RestorePreview, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.databaseService.PreviewRestore(backupPath, passphrase)
+}
+
+// RestoreBackup replaces the current database with a backup and returns the
+// path of the safety copy. The user has to log in again afterwards.
+func (a *App) RestoreBackup(backupPath, passphrase string) (string, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return "", err
+	}
+	return a.databaseService.RestoreBackup(backupPath, passphrase)
+}
+
+// GetBackupStatus returns when the last backup succeeded and when the next one is due
+func (a *App) GetBackupStatus() (*services.BackupStatus, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	status := a.backupScheduler.Status()
+	return &status, nil
+}
+
+// GetBackupSettings returns the automatic backup settings
+func (a *App) GetBackupSettings() (*config.BackupConfig, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	settings := a.configManager.GetBackupSettings()
+	return &settings, nil
+}
+
+// SetBackupSettings updates the automatic backup settings and reschedules
+func (a *App) SetBackupSettings(settings config.BackupConfig) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	if err := a.configManager.SetBackupSettings(settings); err != nil {
+		return err
+	}
+	a.backupScheduler.Configure(settings)
+	return nil
+}
+
+// API server methods - exported for Wails
+
+// GetAPISettings returns the HTTP API settings; token hashes are left out
+func (a *App) GetAPISettings() (*config.APIConfig, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	settings := a.configManager.GetAPISettings()
+	for i := range settings.Tokens {
+		settings.Tokens[i].Hash = ""
+	}
+	return &settings, nil
+}
+
+// SetAPISettings updates whether and where the HTTP API listens and applies
+// it at once. Tokens are managed with CreateAPIToken and DeleteAPIToken.
+func (a *App) SetAPISettings(settings config.APIConfig) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	settings.Tokens = a.configManager.GetAPISettings().Tokens
+	if err := a.configManager.SetAPISettings(settings); err != nil {
+		return err
+	}
+	return a.apiServer.Configure(settings)
+}
+
+// GetAPIStatus returns whether the HTTP API is listening
+func (a *App) GetAPIStatus() (*api.Status, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	status := a.apiServer.Status()
+	return &status, nil
+}
+
+// CreateAPIToken adds an API token with the rights of role and returns it.
+// The token is not stored and cannot be shown again.
+func (a *App) CreateAPIToken(name string, role models.Role) (string, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return "", err
+	}
+
+	token, hash, err := api.NewToken()
+	if err != nil {
+		return "", err
+	}
+	settings := a.configManager.GetAPISettings()
+	settings.Tokens = append(settings.Tokens, config.APIToken{
+		Name:      strings.TrimSpace(name),
+		Role:      role,
+		Hash:      hash,
+		CreatedAt: time.Now(),
+	})
+	if err := a.configManager.SetAPISettings(settings); err != nil {
+		return "", err
+	}
+	if err := a.apiServer.Configure(settings); err != nil {
+		log.Printf("Warning: Failed to apply API settings: %v", err)
+	}
+	return token, nil
+}
+
+// DeleteAPIToken revokes the API token with the given name
+func (a *App) DeleteAPIToken(name string) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+
+	settings := a.configManager.GetAPISettings()
+	kept := settings.Tokens[:0]
+	for _, token := range settings.Tokens {
+		if token.Name != name {
+			kept = append(kept, token)
+		}
+	}
+	if len(kept) == len(settings.Tokens) {
+		return fmt.Errorf("API token not found: %s", name)
+	}
+	settings.Tokens = kept
+	if err := a.configManager.SetAPISettings(settings); err != nil {
+		return err
+	}
+	return a.apiServer.Configure(settings)
+}
+
+// EncryptDatabase converts the current database into an encrypted one.
+// Copies and backups made before stay unencrypted.
+func (a *App) EncryptDatabase(passphrase string) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.databaseService.EncryptDatabase(passphrase)
 }
 
-// SwitchDatabase switches to a different database
-func (a *App) SwitchDatabase(path string) error {
-	return a.databaseService.SwitchDatabase(path)
+// ChangeDatabasePassphrase changes the passphrase of the current encrypted database
+func (a *App) ChangeDatabasePassphrase(oldPassphrase, newPassphrase string) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.databaseService.ChangePassphrase(oldPassphrase, newPassphrase)
 }
 
 // GetCurrentDatabase returns the currently connected database info
@@ -154,26 +431,41 @@ func (a *App) IsConnected() bool {
 
 // GetAllCategories returns all categories
 func (a *App) GetAllCategories() ([]services.CategoryDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	return a.categoryService.GetAll()
 }
 
 // GetCategoryByID returns a category by ID
 func (a *App) GetCategoryByID(id uint) (*services.CategoryDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	return a.categoryService.GetByID(id)
 }
 
 // CreateCategory creates a new category
 func (a *App) CreateCategory(dto services.CategoryDTO) (*services.CategoryDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
 	return a.categoryService.Create(dto)
 }
 
 // UpdateCategory updates an existing category
 func (a *App) UpdateCategory(id uint, dto services.CategoryDTO) (*services.CategoryDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
 	return a.categoryService.Update(id, dto)
 }
 
 // DeleteCategory deletes a category
 func (a *App) DeleteCategory(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
 	return a.categoryService.Delete(id)
 }
 
@@ -181,66 +473,925 @@ func (a *App) DeleteCategory(id uint) error {
 
 // GetAllProducts returns all products
 func (a *App) GetAllProducts() ([]services.ProductDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	return a.productService.GetAll()
 }
 
+// SearchProducts finds products by words or word prefixes of their code,
+// name or category, best matches first, ignoring case and Turkish letters
+func (a *App) SearchProducts(query string, limit int) ([]services.ProductDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.productService.Search(query, limit)
+}
+
+// ListProducts returns one page of products matching the query with the total count
+func (a *App) ListProducts(q services.ProductQuery) (*services.ProductPage, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.productService.List(q)
+}
+
 // GetProductByID returns a product by ID
 func (a *App) GetProductByID(id uint) (*services.ProductDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	return a.productService.GetByID(id)
 }
 
 // CreateProduct creates a new product
 func (a *App) CreateProduct(dto services.ProductDTO) (*services.ProductDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
 	return a.productService.Create(dto)
 }
 
 // UpdateProduct updates an existing product
 func (a *App) UpdateProduct(id uint, dto services.ProductDTO) (*services.ProductDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
 	return a.productService.Update(id, dto)
 }
 
 // DeleteProduct deletes a product
 func (a *App) DeleteProduct(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
 	return a.productService.Delete(id)
 }
 
 // GetLowStockProducts returns products with low stock
 func (a *App) GetLowStockProducts() ([]services.ProductDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	return a.productService.GetLowStock()
 }
 
+// GetLowStockProductsAtLocation returns products with low stock at a location
+func (a *App) GetLowStockProductsAtLocation(locationID uint) ([]services.ProductDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.productService.GetLowStockAtLocation(locationID)
+}
+
+// Barcode service methods - exported for Wails
+
+// GetProductBarcodes returns the barcodes of a product
+func (a *App) GetProductBarcodes(productID uint) ([]services.BarcodeDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.barcodeService.GetByProduct(productID)
+}
+
+// AddProductBarcode assigns a barcode to a product; an empty symbology is
+// detected from the code
+func (a *App) AddProductBarcode(productID uint, barcode string, symbology models.BarcodeSymbology) (*services.BarcodeDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.barcodeService.Add(productID, barcode, symbology)
+}
+
+// DeleteProductBarcode removes a barcode from its product
+func (a *App) DeleteProductBarcode(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.barcodeService.Delete(id)
+}
+
+// FindProductByBarcode returns the product a scanned barcode or product code belongs to
+func (a *App) FindProductByBarcode(barcode string) (*services.ProductDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.barcodeService.FindProductByBarcode(barcode)
+}
+
+// GetLabelLayout returns the barcode label sheet layout
+func (a *App) GetLabelLayout() (config.LabelLayout, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return config.LabelLayout{}, err
+	}
+	return a.configManager.GetLabelLayout(), nil
+}
+
+// SetLabelLayout updates the barcode label sheet layout
+func (a *App) SetLabelLayout(layout config.LabelLayout) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.configManager.SetLabelLayout(layout)
+}
+
+// PrintLabels asks where to save and writes barcode label sheets as "pdf" or
+// "png". A PNG holds one sheet; further sheets are saved next to it with -2,
+// -3 and so on appended. It returns the saved path, or an empty string when
+// the dialog is cancelled.
+func (a *App) PrintLabels(items []services.LabelItem, format string) (string, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return "", err
+	}
+	if !services.IsValidLabelFormat(format) {
+		return "", fmt.Errorf("unknown label format: %s", format)
+	}
+
+	ext := "." + format
+	filter := runtime.FileFilter{DisplayName: "PDF document (*.pdf)", Pattern: "*.pdf"}
+	if format == services.LabelFormatPNG {
+		filter = runtime.FileFilter{DisplayName: "PNG image (*.png)", Pattern: "*.png"}
+	}
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Save labels",
+		DefaultFilename: fmt.Sprintf("labels_%s%s", time.Now().Format("2006-01-02"), ext),
+		Filters:         []runtime.FileFilter{filter},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if !strings.EqualFold(filepath.Ext(path), ext) {
+		path += ext
+	}
+
+	if format == services.LabelFormatPNG {
+		sheets, err := a.labelService.RenderPNG(items)
+		if err != nil {
+			return "", err
+		}
+		base := strings.TrimSuffix(path, filepath.Ext(path))
+		for i, sheet := range sheets {
+			sheetPath := path
+			if i > 0 {
+				sheetPath = fmt.Sprintf("%s-%d%s", base, i+1, ext)
+			}
+			if err := os.WriteFile(sheetPath, sheet, 0644); err != nil {
+				return "", fmt.Errorf("failed to save labels: %w", err)
+			}
+		}
+		return path, nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create label file: %w", err)
+	}
+	if err := a.labelService.WritePDF(file, items); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to save labels: %w", err)
+	}
+	return path, nil
+}
+
+// Location service methods - exported for Wails
+
+// GetAllLocations returns all locations
+func (a *App) GetAllLocations() ([]services.LocationDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.locationService.GetAll()
+}
+
+// GetLocationByID returns a location by ID
+func (a *App) GetLocationByID(id uint) (*services.LocationDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.locationService.GetByID(id)
+}
+
+// CreateLocation creates a new location
+func (a *App) CreateLocation(dto services.LocationDTO) (*services.LocationDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.locationService.Create(dto)
+}
+
+// UpdateLocation updates an existing location
+func (a *App) UpdateLocation(id uint, dto services.LocationDTO) (*services.LocationDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.locationService.Update(id, dto)
+}
+
+// DeleteLocation deletes a location
+func (a *App) DeleteLocation(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.locationService.Delete(id)
+}
+
+// Unit service methods - exported for Wails
+
+// GetAllUnits returns all units of measure
+func (a *App) GetAllUnits() ([]services.UnitDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.unitService.GetAll()
+}
+
+// CreateUnit creates a new unit of measure
+func (a *App) CreateUnit(dto services.UnitDTO) (*services.UnitDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.unitService.Create(dto)
+}
+
+// UpdateUnit updates a unit of measure
+func (a *App) UpdateUnit(id uint, dto services.UnitDTO) (*services.UnitDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.unitService.Update(id, dto)
+}
+
+// DeleteUnit deletes an unused unit of measure
+func (a *App) DeleteUnit(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.unitService.Delete(id)
+}
+
+// SetUnitPrecision sets how many decimals quantities in a unit may use
+func (a *App) SetUnitPrecision(name string, decimals int) (*services.UnitDTO, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.unitService.SetPrecision(name, decimals)
+}
+
+// GetUnitConversions returns the general conversions and those of a product
+func (a *App) GetUnitConversions(productID uint) ([]services.UnitConversionDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.unitService.GetConversions(productID)
+}
+
+// SaveUnitConversion creates or updates a unit conversion
+func (a *App) SaveUnitConversion(dto services.UnitConversionDTO) (*services.UnitConversionDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.unitService.SaveConversion(dto)
+}
+
+// DeleteUnitConversion deletes a unit conversion
+func (a *App) DeleteUnitConversion(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.unitService.DeleteConversion(id)
+}
+
+// GetAllowedUnits returns the units a product's movements may be recorded in
+func (a *App) GetAllowedUnits(productID uint) ([]services.UnitDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.unitService.GetAllowedUnits(productID)
+}
+
 // Movement service methods - exported for Wails
 
 // GetAllMovements returns all movements
 func (a *App) GetAllMovements() ([]services.MovementDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	return a.movementService.GetAll()
 }
 
+// ListMovements returns one page of movements matching the query with the total count
+func (a *App) ListMovements(q services.MovementQuery) (*services.MovementPage, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.movementService.List(q)
+}
+
 // GetMovementByID returns a movement by ID
 func (a *App) GetMovementByID(id uint) (*services.MovementDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	return a.movementService.GetByID(id)
 }
 
 // CreateMovement creates a new movement
 func (a *App) CreateMovement(dto services.MovementDTO) (*services.MovementDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
 	return a.movementService.Create(dto)
 }
 
 // DeleteMovement deletes a movement
 func (a *App) DeleteMovement(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
 	return a.movementService.Delete(id)
 }
 
 // GetMovementsByProduct returns movements for a specific product
 func (a *App) GetMovementsByProduct(productID uint) ([]services.MovementDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	return a.movementService.GetByProduct(productID)
 }
 
 // GetMovementStats returns movement statistics
 func (a *App) GetMovementStats() (*services.MovementStats, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
 	return a.movementService.GetStats()
 }
 
+// Stock count service methods - exported for Wails
+
+// GetStockCounts returns all stock counts, newest first
+func (a *App) GetStockCounts() ([]services.StockCountDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.countService.GetAll()
+}
+
+// GetStockCount returns a stock count by ID
+func (a *App) GetStockCount(id uint) (*services.StockCountDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.countService.GetByID(id)
+}
+
+// GetStockCountLines returns the products of a count, optionally of one category
+func (a *App) GetStockCountLines(countID, categoryID uint) ([]services.StockCountLineDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.countService.GetLines(countID, categoryID)
+}
+
+// OpenStockCount starts a count and snapshots the stock of its products
+func (a *App) OpenStockCount(dto services.StockCountDTO) (*services.StockCountDTO, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.countService.Open(dto)
+}
+
+// SetStockCounted records the quantity counted of a product
+func (a *App) SetStockCounted(countID, productID uint, counted models.Quantity) (*services.StockCountLineDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.countService.SetCounted(countID, productID, counted)
+}
+
+// GetStockCountVariance returns the differences of a count and their value
+func (a *App) GetStockCountVariance(countID uint) (*services.StockCountVariance, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.countService.Variance(countID)
+}
+
+// PostStockCount books the differences of a count as adjustments and closes it
+func (a *App) PostStockCount(countID uint, zeroUncounted bool) (*services.StockCountDTO, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.countService.Post(countID, zeroUncounted)
+}
+
+// CancelStockCount closes a count without changing stock
+func (a *App) CancelStockCount(countID uint) (*services.StockCountDTO, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.countService.Cancel(countID)
+}
+
+// Supplier service methods - exported for Wails
+
+// GetSuppliers returns all suppliers
+func (a *App) GetSuppliers() ([]services.SupplierDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.supplierService.GetAll()
+}
+
+// GetSupplier returns a supplier by ID
+func (a *App) GetSupplier(id uint) (*services.SupplierDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.supplierService.GetByID(id)
+}
+
+// CreateSupplier creates a new supplier
+func (a *App) CreateSupplier(dto services.SupplierDTO) (*services.SupplierDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.supplierService.Create(dto)
+}
+
+// UpdateSupplier updates an existing supplier
+func (a *App) UpdateSupplier(id uint, dto services.SupplierDTO) (*services.SupplierDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.supplierService.Update(id, dto)
+}
+
+// DeleteSupplier deletes a supplier without purchase orders
+func (a *App) DeleteSupplier(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.supplierService.Delete(id)
+}
+
+// GetProductSuppliers returns the suppliers of a product, cheapest first
+func (a *App) GetProductSuppliers(productID uint) ([]services.ProductSupplierDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.productService.GetSuppliers(productID)
+}
+
+// GetCheapestSupplier returns the supplier with the lowest last price for a
+// product, or nil when none has a price
+func (a *App) GetCheapestSupplier(productID uint) (*services.ProductSupplierDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.productService.GetCheapestSupplier(productID)
+}
+
+// SetProductSupplier links a supplier to a product or updates its terms
+func (a *App) SetProductSupplier(dto services.ProductSupplierDTO) (*services.ProductSupplierDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.productService.SetSupplier(dto)
+}
+
+// RemoveProductSupplier unlinks a supplier from a product
+func (a *App) RemoveProductSupplier(productID, supplierID uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.productService.RemoveSupplier(productID, supplierID)
+}
+
+// Purchase order service methods - exported for Wails
+
+// GetPurchaseOrders returns purchase orders, newest first, optionally of one status
+func (a *App) GetPurchaseOrders(status string) ([]services.PurchaseOrderDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.purchaseService.GetAll(status)
+}
+
+// GetPurchaseOrder returns a purchase order by ID
+func (a *App) GetPurchaseOrder(id uint) (*services.PurchaseOrderDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.purchaseService.GetByID(id)
+}
+
+// CreatePurchaseOrder creates a draft purchase order
+func (a *App) CreatePurchaseOrder(dto services.PurchaseOrderDTO) (*services.PurchaseOrderDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.purchaseService.Create(dto)
+}
+
+// UpdatePurchaseOrder replaces a draft purchase order
+func (a *App) UpdatePurchaseOrder(id uint, dto services.PurchaseOrderDTO) (*services.PurchaseOrderDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.purchaseService.Update(id, dto)
+}
+
+// DeletePurchaseOrder deletes a draft purchase order
+func (a *App) DeletePurchaseOrder(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.purchaseService.Delete(id)
+}
+
+// SendPurchaseOrder marks a draft purchase order as sent to the supplier
+func (a *App) SendPurchaseOrder(id uint) (*services.PurchaseOrderDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.purchaseService.Send(id)
+}
+
+// ReceivePurchaseOrder books delivered goods of a purchase order as IN movements
+func (a *App) ReceivePurchaseOrder(id uint, receipts []services.PurchaseReceiptLine, note string) (*services.PurchaseOrderDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.purchaseService.Receive(id, receipts, note)
+}
+
+// CancelPurchaseOrder closes a purchase order that was not received in full
+func (a *App) CancelPurchaseOrder(id uint) (*services.PurchaseOrderDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.purchaseService.Cancel(id)
+}
+
+// Customer service methods - exported for Wails
+
+// GetCustomers returns customers, cost centers and projects, optionally of one kind
+func (a *App) GetCustomers(kind string) ([]services.CustomerDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.customerService.GetAll(kind)
+}
+
+// GetCustomer returns a customer by ID
+func (a *App) GetCustomer(id uint) (*services.CustomerDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.customerService.GetByID(id)
+}
+
+// CreateCustomer creates a new customer, cost center or project
+func (a *App) CreateCustomer(dto services.CustomerDTO) (*services.CustomerDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.customerService.Create(dto)
+}
+
+// UpdateCustomer updates an existing customer
+func (a *App) UpdateCustomer(id uint, dto services.CustomerDTO) (*services.CustomerDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.customerService.Update(id, dto)
+}
+
+// DeleteCustomer deletes a customer without sales orders or movements
+func (a *App) DeleteCustomer(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.customerService.Delete(id)
+}
+
+// GetConsumption sums the OUT movements issued to each customer, cost
+// center or project over a date range
+func (a *App) GetConsumption(q services.ConsumptionQuery) (*services.ConsumptionReport, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.customerService.Consumption(q)
+}
+
+// Sales order service methods - exported for Wails
+
+// GetSalesOrders returns sales orders, newest first, optionally of one status
+func (a *App) GetSalesOrders(status string) ([]services.SalesOrderDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.salesService.GetAll(status)
+}
+
+// GetSalesOrder returns a sales order by ID
+func (a *App) GetSalesOrder(id uint) (*services.SalesOrderDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.salesService.GetByID(id)
+}
+
+// CreateSalesOrder creates a draft sales order
+func (a *App) CreateSalesOrder(dto services.SalesOrderDTO) (*services.SalesOrderDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.salesService.Create(dto)
+}
+
+// UpdateSalesOrder replaces a draft sales order
+func (a *App) UpdateSalesOrder(id uint, dto services.SalesOrderDTO) (*services.SalesOrderDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.salesService.Update(id, dto)
+}
+
+// DeleteSalesOrder deletes a draft sales order
+func (a *App) DeleteSalesOrder(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.salesService.Delete(id)
+}
+
+// ConfirmSalesOrder reserves the stock of a draft sales order
+func (a *App) ConfirmSalesOrder(id uint) (*services.SalesOrderDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.salesService.Confirm(id)
+}
+
+// ShipSalesOrder books shipped goods of a sales order as OUT movements
+func (a *App) ShipSalesOrder(id uint, shipments []services.SalesShipmentLine, note string) (*services.SalesOrderDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.salesService.Ship(id, shipments, note)
+}
+
+// CancelSalesOrder closes a sales order that was not shipped in full
+func (a *App) CancelSalesOrder(id uint) (*services.SalesOrderDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.salesService.Cancel(id)
+}
+
+// Reservation service methods - exported for Wails
+
+// GetReservations returns reservations, newest first, optionally of one
+// product and status
+func (a *App) GetReservations(productID uint, status string) ([]services.ReservationDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.reserveService.GetAll(productID, status)
+}
+
+// GetReservation returns a reservation by ID
+func (a *App) GetReservation(id uint) (*services.ReservationDTO, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.reserveService.GetByID(id)
+}
+
+// CreateReservation reserves stock of a product
+func (a *App) CreateReservation(dto services.ReservationDTO) (*services.ReservationDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.reserveService.Create(dto)
+}
+
+// ReleaseReservation gives up what an active reservation still holds
+func (a *App) ReleaseReservation(id uint) (*services.ReservationDTO, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+	return a.reserveService.Release(id)
+}
+
+// Import service methods - exported for Wails
+
+// SelectImportFile asks the user for a CSV file and returns its path, or an
+// empty string when the dialog is cancelled
+func (a *App) SelectImportFile() (string, error) {
+	return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Select CSV file",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "CSV files (*.csv, *.txt)", Pattern: "*.csv;*.txt"},
+		},
+	})
+}
+
+// ImportProducts imports products and opening stock from a CSV file. With
+// options.DryRun it only returns the validation report.
+func (a *App) ImportProducts(path string, options services.ProductImportOptions) (*services.ImportReport, error) {
+	if err := a.authService.Require(models.RoleClerk); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	return a.importService.ImportProducts(file, options)
+}
+
+// Export service methods - exported for Wails
+
+// ExportWorkbook asks where to save and writes an Excel workbook of products,
+// the movements in the range of q and the stock valuation. It returns the
+// saved path, or an empty string when the dialog is cancelled.
+func (a *App) ExportWorkbook(q services.ExportQuery) (string, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return "", err
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export to Excel",
+		DefaultFilename: fmt.Sprintf("stok_%s.xlsx", time.Now().Format("2006-01-02")),
+		Filters: []runtime.FileFilter{
+			{DisplayName: "Excel workbook (*.xlsx)", Pattern: "*.xlsx"},
+		},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".xlsx") {
+		path += ".xlsx"
+	}
+
+	if err := a.exportService.ExportWorkbook(path, q); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Report service methods - exported for Wails
+
+// ExportReport asks where to save and writes a PDF report: "count_sheet",
+// "valuation" or "low_stock". It returns the saved path, or an empty string
+// when the dialog is cancelled.
+func (a *App) ExportReport(kind string) (string, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return "", err
+	}
+	if !services.IsValidReport(kind) {
+		return "", fmt.Errorf("unknown report: %s", kind)
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Save report",
+		DefaultFilename: fmt.Sprintf("%s_%s.pdf", kind, time.Now().Format("2006-01-02")),
+		Filters: []runtime.FileFilter{
+			{DisplayName: "PDF document (*.pdf)", Pattern: "*.pdf"},
+		},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".pdf") {
+		path += ".pdf"
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create report file: %w", err)
+	}
+	if err := a.reportService.Write(file, kind); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to save report: %w", err)
+	}
+	return path, nil
+}
+
+// Reconciliation service methods - exported for Wails
+
+// VerifyStock compares each product's stock with the sum of its movements
+func (a *App) VerifyStock() (*services.StockReconciliationReport, error) {
+	if err := a.authService.Require(models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return a.reconcileService.Verify()
+}
+
+// FixStock corrects every product whose stock differs from its movements
+func (a *App) FixStock() (*services.StockReconciliationReport, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.reconcileService.Fix()
+}
+
+// GetLastStockCheck returns the result of the most recent stock verification,
+// or nil when nobody is logged in
+func (a *App) GetLastStockCheck() *services.StockReconciliationReport {
+	if a.authService.Require(models.RoleViewer) != nil {
+		return nil
+	}
+	return a.reconcileService.GetLastReport()
+}
+
+// Audit service methods - exported for Wails
+
+// QueryAuditLog returns audit log entries filtered by entity, action and date range
+func (a *App) QueryAuditLog(query services.AuditQuery) ([]services.AuditEntryDTO, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.auditService.Query(query)
+}
+
+// Auth service methods - exported for Wails
+
+// NeedsSetup reports whether the current database has no users yet
+func (a *App) NeedsSetup() (bool, error) {
+	return a.authService.NeedsSetup()
+}
+
+// SetupAdmin creates the first admin of a new database and logs in
+func (a *App) SetupAdmin(username, password string) (*services.UserDTO, error) {
+	return a.authService.SetupAdmin(username, password)
+}
+
+// Login logs in to the current database
+func (a *App) Login(username, password string) (*services.UserDTO, error) {
+	return a.authService.Login(username, password)
+}
+
+// Logout ends the current session
+func (a *App) Logout() {
+	a.authService.Logout()
+}
+
+// GetCurrentUser returns the logged in user, or nil
+func (a *App) GetCurrentUser() *services.UserDTO {
+	return a.authService.CurrentUser()
+}
+
+// ChangePassword changes the logged in user's password
+func (a *App) ChangePassword(oldPassword, newPassword string) error {
+	return a.authService.ChangePassword(oldPassword, newPassword)
+}
+
+// GetAllUsers returns all users of the current database
+func (a *App) GetAllUsers() ([]services.UserDTO, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.authService.GetAllUsers()
+}
+
+// CreateUser creates a new user
+func (a *App) CreateUser(dto services.UserDTO) (*services.UserDTO, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.authService.CreateUser(dto)
+}
+
+// UpdateUser updates a user's name, role, active flag and, when given, password
+func (a *App) UpdateUser(id uint, dto services.UserDTO) (*services.UserDTO, error) {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return a.authService.UpdateUser(id, dto)
+}
+
+// DeleteUser deletes a user
+func (a *App) DeleteUser(id uint) error {
+	if err := a.authService.Require(models.RoleAdmin); err != nil {
+		return err
+	}
+	return a.authService.DeleteUser(id)
+}
+
 // Config service methods - exported for Wails
 
 // GetTheme returns the current theme
@@ -253,6 +1404,16 @@ func (a *App) SetTheme(theme string) error {
 	return a.configManager.SetTheme(theme)
 }
 
+// GetLanguage returns the interface language
+func (a *App) GetLanguage() string {
+	return a.configManager.GetLanguage()
+}
+
+// SetLanguage sets the interface language
+func (a *App) SetLanguage(language string) error {
+	return a.configManager.SetLanguage(language)
+}
+
 // ResetAndReload clears the last database and reloads the window
 func (a *App) ResetAndReload() error {
 	// Clear last database from config
@@ -0,0 +1,386 @@
+// Package cli runs maintenance tasks from the command line on the same
+// services as the desktop app, without starting the Wails window.
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"stoktakip/internal/config"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"stoktakip/internal/services"
+	"stoktakip/internal/utils"
+)
+
+// Environment variables read when the matching flag is not given, so that
+// secrets stay out of scripts and process lists
+const (
+	envPassphrase = "STOKTAKIP_PASSPHRASE"
+	envUser       = "STOKTAKIP_USER"
+	envPassword   = "STOKTAKIP_PASSWORD"
+)
+
+// Exit codes
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// usageError is an error caused by a wrong command line
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// usagef returns a usage error; Run prints the command's usage after it
+func usagef(format string, args ...interface{}) error {
+	return usageError(fmt.Sprintf(format, args...))
+}
+
+// command is a CLI subcommand such as "product list"
+type command struct {
+	name    string
+	args    string // Positional arguments, for the usage text
+	summary string
+
+	// role is the least role needed when the database has users; commands
+	// with no role do not open a database
+	role models.Role
+
+	// define registers the command's flags and returns the function that runs it
+	define func(fs *flag.FlagSet) func(s *session, args []string) error
+}
+
+// globalOptions are the flags every command accepts
+type globalOptions struct {
+	db         string
+	passphrase string
+	user       string
+	password   string
+	format     string
+	verbose    bool
+}
+
+// session holds the services of one CLI run
+type session struct {
+	out     io.Writer
+	format  string
+	options globalOptions
+
+	pathManager     *utils.PathManager
+	configManager   *config.Manager
+	dbManager       *database.ConnectionManager
+	databaseService *services.DatabaseService
+	auditService    *services.AuditService
+	authService     *services.AuthService
+	productService  *services.ProductService
+	categoryService *services.CategoryService
+	movementService *services.MovementService
+	locationService *services.LocationService
+	reconcile       *services.ReconciliationService
+	importService   *services.ImportService
+	exportService   *services.ExportService
+	reportService   *services.ReportService
+	backupScheduler *services.BackupScheduler
+}
+
+// IsCommand reports whether args, without the program name, start with a
+// CLI command rather than being meant for the desktop app
+func IsCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if args[0] == "help" || args[0] == "--help" || args[0] == "-h" {
+		return true
+	}
+	for _, cmd := range commands {
+		if strings.Fields(cmd.name)[0] == args[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes the command in args, without the program name, and returns
+// the process exit code
+func Run(args []string, stdout, stderr io.Writer) int {
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		if len(args) > 0 && args[0] != "help" && args[0] != "--help" && args[0] != "-h" {
+			fmt.Fprintf(stderr, "unknown command: %s\n\n", strings.Join(args, " "))
+			printUsage(stderr)
+			return exitUsage
+		}
+		printUsage(stdout)
+		return exitOK
+	}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var options globalOptions
+	fs.StringVar(&options.db, "db", "", "database file")
+	fs.StringVar(&options.passphrase, "passphrase", "", "passphrase of an encrypted database (or $"+envPassphrase+")")
+	fs.StringVar(&options.user, "user", "", "user name when the database has users (or $"+envUser+")")
+	fs.StringVar(&options.password, "password", "", "password of the user (or $"+envPassword+")")
+	fs.StringVar(&options.format, "format", "table", "output format: table or json")
+	fs.BoolVar(&options.verbose, "verbose", false, "log progress to stderr")
+	run := cmd.define(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: stoktakip %s [flags] %s\n\n%s\n\nflags:\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseInterspersed(fs, rest)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		return exitUsage
+	}
+	if options.format != "table" && options.format != "json" {
+		fmt.Fprintf(stderr, "invalid format '%s': use table or json\n", options.format)
+		return exitUsage
+	}
+
+	if !options.verbose {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	s, err := newSession(stdout, options)
+	if err == nil {
+		defer s.close(stderr)
+		if cmd.role != "" {
+			err = s.open(cmd.role)
+		}
+		if err == nil {
+			err = run(s, positional)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		var usage usageError
+		if errors.As(err, &usage) {
+			fs.Usage()
+			return exitUsage
+		}
+		return exitError
+	}
+	return exitOK
+}
+
+// findCommand matches the longest command name at the start of args
+func findCommand(args []string) (*command, []string) {
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) < len(words) {
+			continue
+		}
+		if strings.Join(args[:len(words)], " ") == commands[i].name {
+			return &commands[i], args[len(words):]
+		}
+	}
+	return nil, nil
+}
+
+// printUsage lists the commands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: stoktakip <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\nWithout a command the desktop app starts. Commands:")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	names := make([]string, len(commands))
+	byName := make(map[string]command, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.name
+		byName[cmd.name] = cmd
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := byName[name]
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nRun 'stoktakip <command> -h' for the flags of a command.")
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		// Everything after "--" is positional
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		args = rest
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// newSession creates the services the way the desktop app does
+func newSession(out io.Writer, options globalOptions) (*session, error) {
+	pathManager, err := utils.NewPathManager()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize path manager: %w", err)
+	}
+
+	configManager := config.NewManager(pathManager)
+	if _, err := configManager.Load(); err != nil {
+		log.Printf("Warning: Failed to load config: %v", err)
+	}
+
+	dbManager := database.GetConnectionManager()
+	databaseService := services.NewDatabaseService(dbManager, pathManager, configManager)
+	auditService := services.NewAuditService(dbManager)
+	authService := services.NewAuthService(dbManager, auditService)
+	productService := services.NewProductService(dbManager, auditService)
+	categoryService := services.NewCategoryService(dbManager, auditService)
+	movementService := services.NewMovementService(dbManager, auditService, authService)
+	dbManager.OnConnect(authService.OnConnect)
+	auditService.SetActor(authService.ActorName)
+
+	return &session{
+		out:             out,
+		format:          options.format,
+		options:         options,
+		pathManager:     pathManager,
+		configManager:   configManager,
+		dbManager:       dbManager,
+		databaseService: databaseService,
+		auditService:    auditService,
+		authService:     authService,
+		productService:  productService,
+		categoryService: categoryService,
+		movementService: movementService,
+		locationService: services.NewLocationService(dbManager),
+		reconcile:       services.NewReconciliationService(dbManager, auditService),
+		importService:   services.NewImportService(dbManager, productService, categoryService, movementService),
+		exportService:   services.NewExportService(dbManager, configManager),
+		reportService:   services.NewReportService(dbManager, productService, configManager),
+		backupScheduler: services.NewBackupScheduler(databaseService, dbManager, configManager.GetBackupSettings()),
+	}, nil
+}
+
+// open connects to the --db database and, when it has users, logs in and
+// checks that the user has at least role
+func (s *session) open(role models.Role) error {
+	path := s.databasePath()
+	if path == "" {
+		return usagef("--db is required")
+	}
+	if !s.pathManager.FileExists(path) {
+		return fmt.Errorf("database file not found: %s", path)
+	}
+
+	encrypted, err := database.IsEncrypted(path)
+	if err != nil {
+		return fmt.Errorf("failed to read database: %w", err)
+	}
+	if encrypted {
+		passphrase := valueOrEnv(s.options.passphrase, envPassphrase)
+		if passphrase == "" {
+			return fmt.Errorf("database is encrypted: give --passphrase or set %s", envPassphrase)
+		}
+		err = s.dbManager.ConnectEncrypted(path, passphrase)
+	} else {
+		err = s.dbManager.Connect(path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	// Databases without users are not protected, as in the desktop app setup
+	needsSetup, err := s.authService.NeedsSetup()
+	if err != nil {
+		return err
+	}
+	if needsSetup {
+		return nil
+	}
+
+	user := valueOrEnv(s.options.user, envUser)
+	password := valueOrEnv(s.options.password, envPassword)
+	if user == "" || password == "" {
+		return fmt.Errorf("database has users: give --user and --password or set %s and %s", envUser, envPassword)
+	}
+	if _, err := s.authService.Login(user, password); err != nil {
+		return err
+	}
+	return s.require(role)
+}
+
+// databasePath resolves --db: a file path, or the name of a database in the
+// Data folder as listed by "db list"
+func (s *session) databasePath() string {
+	name := s.options.db
+	if name == "" || s.pathManager.FileExists(name) || strings.ContainsAny(name, `/\\`) {
+		return name
+	}
+	if filepath.Ext(name) != ".db" {
+		name += ".db"
+	}
+	return s.pathManager.GetDatabasePath(name)
+}
+
+// require checks the logged in user's role; databases without users allow everything
+func (s *session) require(role models.Role) error {
+	if s.authService.CurrentUser() == nil {
+		return nil
+	}
+	return s.authService.Require(role)
+}
+
+// close closes the database, which also writes back an encrypted database
+func (s *session) close(stderr io.Writer) {
+	if !s.dbManager.IsConnected() {
+		return
+	}
+	if err := s.dbManager.Close(); err != nil {
+		fmt.Fprintf(stderr, "error: failed to close database: %v\n", err)
+	}
+}
+
+// print writes v as indented JSON, or as a table of rows under headers
+func (s *session) print(v interface{}, headers []string, rows [][]string) error {
+	if s.format == "json" {
+		encoder := json.NewEncoder(s.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// valueOrEnv returns value, or the environment variable when value is empty
+func valueOrEnv(value, env string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
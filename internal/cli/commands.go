@@ -0,0 +1,515 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"stoktakip/internal/models"
+	"stoktakip/internal/services"
+)
+
+// dateLayout is the format of --from and --to
+const dateLayout = "2006-01-02"
+
+// commands lists every CLI command
+var commands = []command{
+	{
+		name:    "db list",
+		summary: "List the databases in the Data folder",
+		define: func(fs *flag.FlagSet) func(s *session, args []string) error {
+			return runDBList
+		},
+	},
+	{
+		name:    "db create",
+		args:    "NAME",
+		summary: "Create a database in the Data folder; --passphrase encrypts it",
+		define: func(fs *flag.FlagSet) func(s *session, args []string) error {
+			return runDBCreate
+		},
+	},
+	{
+		name:    "product list",
+		summary: "List products with their stock",
+		role:    models.RoleViewer,
+		define: func(fs *flag.FlagSet) func(s *session, args []string) error {
+			low := fs.Bool("low", false, "only products at or below their critical limit")
+			return func(s *session, args []string) error {
+				return runProductList(s, args, *low)
+			}
+		},
+	},
+	{
+		name:    "product import",
+		args:    "FILE",
+		summary: "Import products and opening stock from a CSV file",
+		role:    models.RoleClerk,
+		define: func(fs *flag.FlagSet) func(s *session, args []string) error {
+			var options services.ProductImportOptions
+			columns := columnFlag{}
+			location := fs.String("location", "", "location of the opening stock, by ID or name (default location if empty)")
+			fs.StringVar(&options.Delimiter, "delimiter", "", `field delimiter (default ";" with --decimal-comma, else ",")`)
+			fs.BoolVar(&options.DecimalComma, "decimal-comma", false, `numbers are written like "1.234,5"`)
+			fs.BoolVar(&options.NoHeader, "no-header", false, "the first line is data; map columns by number")
+			fs.BoolVar(&options.DryRun, "dry-run", false, "validate every row but write nothing")
+			fs.Var(columns, "column", "map a field to a header name or 1-based column number, as field=column (repeatable)")
+			return func(s *session, args []string) error {
+				options.Columns = columns
+				return runProductImport(s, args, options, *location)
+			}
+		},
+	},
+	{
+		name:    "movement list",
+		summary: "List stock movements, newest first",
+		role:    models.RoleViewer,
+		define: func(fs *flag.FlagSet) func(s *session, args []string) error {
+			product := fs.String("product", "", "only the movements of the product with this code")
+			return func(s *session, args []string) error {
+				return runMovementList(s, args, *product)
+			}
+		},
+	},
+	{
+		name:    "movement add",
+		summary: "Record a stock movement",
+		role:    models.RoleClerk,
+		define: func(fs *flag.FlagSet) func(s *session, args []string) error {
+			var options movementOptions
+			fs.StringVar(&options.product, "product", "", "product code (required)")
+			fs.StringVar(&options.kind, "type", "", "IN, OUT or TRANSFER (required)")
+			fs.StringVar(&options.quantity, "quantity", "", "quantity, with a dot as decimal mark (required)")
+			fs.StringVar(&options.unit, "unit", "", "unit of the quantity (the product's unit if empty)")
+			fs.StringVar(&options.location, "location", "", "location by ID or name (default location if empty)")
+			fs.StringVar(&options.toLocation, "to-location", "", "target location of a TRANSFER, by ID or name")
+			fs.StringVar(&options.note, "note", "", "note")
+			return func(s *session, args []string) error {
+				return runMovementAdd(s, args, options)
+			}
+		},
+	},
+	{
+		name:    "backup",
+		summary: "Back up the database and prune old backups by the retention settings",
+		role:    models.RoleClerk,
+		define: func(fs *flag.FlagSet) func(s *session, args []string) error {
+			return runBackup
+		},
+	},
+	{
+		name:    "reconcile",
+		summary: "Compare stored stock with the movement ledger; --fix corrects it (admin)",
+		role:    models.RoleViewer,
+		define: func(fs *flag.FlagSet) func(s *session, args []string) error {
+			fix := fs.Bool("fix", false, "correct the stored stock")
+			return func(s *session, args []string) error {
+				return runReconcile(s, args, *fix)
+			}
+		},
+	},
+	{
+		name: "report",
+		args: "KIND",
+		summary: "Write a report: " + services.ReportCountSheet + ", " + services.ReportValuation + " or " +
+			services.ReportLowStock + " as PDF, or " + reportWorkbook + " as Excel",
+		role: models.RoleViewer,
+		define: func(fs *flag.FlagSet) func(s *session, args []string) error {
+			var options reportOptions
+			fs.StringVar(&options.out, "out", "", "output file (required)")
+			fs.StringVar(&options.from, "from", "", "first movement date of the workbook, as YYYY-MM-DD")
+			fs.StringVar(&options.to, "to", "", "last movement date of the workbook, as YYYY-MM-DD")
+			return func(s *session, args []string) error {
+				return runReport(s, args, options)
+			}
+		},
+	},
+}
+
+// reportWorkbook is the report kind of the Excel export
+const reportWorkbook = "workbook"
+
+// columnFlag collects repeated --column field=column flags
+type columnFlag map[string]string
+
+func (c columnFlag) String() string {
+	pairs := make([]string, 0, len(c))
+	for field, column := range c {
+		pairs = append(pairs, field+"="+column)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (c columnFlag) Set(value string) error {
+	field, column, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(field) == "" || strings.TrimSpace(column) == "" {
+		return fmt.Errorf("expected field=column, got '%s'", value)
+	}
+	c[strings.TrimSpace(field)] = strings.TrimSpace(column)
+	return nil
+}
+
+// movementOptions are the flags of "movement add"
+type movementOptions struct {
+	product    string
+	kind       string
+	quantity   string
+	unit       string
+	location   string
+	toLocation string
+	note       string
+}
+
+// reportOptions are the flags of "report"
+type reportOptions struct {
+	out  string
+	from string
+	to   string
+}
+
+// expectArgs fails with a usage error unless there are exactly n positional arguments
+func expectArgs(args []string, n int) error {
+	if len(args) != n {
+		return usagef("expected %d argument(s), got %d", n, len(args))
+	}
+	return nil
+}
+
+func runDBList(s *session, args []string) error {
+	if err := expectArgs(args, 0); err != nil {
+		return err
+	}
+
+	databases, err := s.databaseService.ListDatabases()
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(databases))
+	for i, db := range databases {
+		rows[i] = []string{db.Name, fmt.Sprintf("%.2f", db.Size), db.Modified, yesNo(db.Encrypted), db.Path}
+	}
+	return s.print(databases, []string{"NAME", "SIZE (MB)", "MODIFIED", "ENCRYPTED", "PATH"}, rows)
+}
+
+func runDBCreate(s *session, args []string) error {
+	if err := expectArgs(args, 1); err != nil {
+		return err
+	}
+
+	passphrase := valueOrEnv(s.options.passphrase, envPassphrase)
+	if err := s.databaseService.CreateDatabase(args[0], passphrase); err != nil {
+		return err
+	}
+
+	info, err := s.databaseService.GetCurrentDatabase()
+	if err != nil {
+		return err
+	}
+	return s.print(info, []string{"CREATED"}, [][]string{{info.Path}})
+}
+
+func runProductList(s *session, args []string, low bool) error {
+	if err := expectArgs(args, 0); err != nil {
+		return err
+	}
+
+	var products []services.ProductDTO
+	var err error
+	if low {
+		products, err = s.productService.GetLowStock()
+	} else {
+		products, err = s.productService.GetAll()
+	}
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(products))
+	for i, p := range products {
+		rows[i] = []string{
+			p.Code, p.Name, p.Unit,
+			p.CurrentStock.String(), p.CriticalLimit.String(),
+			p.Price.String(), p.StockValue.String(),
+		}
+	}
+	return s.print(products, []string{"CODE", "NAME", "UNIT", "STOCK", "CRITICAL", "PRICE", "VALUE"}, rows)
+}
+
+func runProductImport(s *session, args []string, options services.ProductImportOptions, location string) error {
+	if err := expectArgs(args, 1); err != nil {
+		return err
+	}
+
+	locationID, err := s.resolveLocation(location)
+	if err != nil {
+		return err
+	}
+	options.LocationID = locationID
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	report, err := s.importService.ImportProducts(file, options)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(report.Rows))
+	for i, row := range report.Rows {
+		status := "ok"
+		if len(row.Errors) > 0 {
+			status = strings.Join(row.Errors, "; ")
+		}
+		rows[i] = []string{strconv.Itoa(row.Line), row.Code, row.Name, row.OpeningStock.String(), status}
+	}
+	if err := s.print(report, []string{"LINE", "CODE", "NAME", "OPENING STOCK", "STATUS"}, rows); err != nil {
+		return err
+	}
+
+	if report.InvalidRows > 0 {
+		return fmt.Errorf("%d of %d rows are invalid; nothing was imported", report.InvalidRows, report.TotalRows)
+	}
+	return nil
+}
+
+func runMovementList(s *session, args []string, productCode string) error {
+	if err := expectArgs(args, 0); err != nil {
+		return err
+	}
+
+	var movements []services.MovementDTO
+	if productCode != "" {
+		product, err := s.productService.GetByCode(productCode)
+		if err != nil {
+			return err
+		}
+		movements, err = s.movementService.GetByProduct(product.ID)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		movements, err = s.movementService.GetAll()
+		if err != nil {
+			return err
+		}
+	}
+
+	products, err := s.productService.GetAll()
+	if err != nil {
+		return err
+	}
+	codes := make(map[uint]string, len(products))
+	for _, p := range products {
+		codes[p.ID] = p.Code
+	}
+
+	rows := make([][]string, len(movements))
+	for i, m := range movements {
+		rows[i] = []string{
+			strconv.FormatUint(uint64(m.ID), 10),
+			m.CreatedAt.Local().Format("2006-01-02 15:04"),
+			codes[m.ProductID], m.Type, m.Quantity.String(), m.Username, m.Note,
+		}
+	}
+	return s.print(movements, []string{"ID", "DATE", "PRODUCT", "TYPE", "QUANTITY", "USER", "NOTE"}, rows)
+}
+
+func runMovementAdd(s *session, args []string, options movementOptions) error {
+	if err := expectArgs(args, 0); err != nil {
+		return err
+	}
+	if options.product == "" || options.kind == "" || options.quantity == "" {
+		return usagef("--product, --type and --quantity are required")
+	}
+
+	quantity, err := models.ParseQuantity(options.quantity)
+	if err != nil {
+		return fmt.Errorf("invalid quantity '%s': %w", options.quantity, err)
+	}
+
+	product, err := s.productService.GetByCode(options.product)
+	if err != nil {
+		return err
+	}
+	locationID, err := s.resolveLocation(options.location)
+	if err != nil {
+		return err
+	}
+	toLocationID, err := s.resolveLocation(options.toLocation)
+	if err != nil {
+		return err
+	}
+
+	dto := services.MovementDTO{
+		ProductID:    product.ID,
+		LocationID:   locationID,
+		ToLocationID: toLocationID,
+		Type:         strings.ToUpper(options.kind),
+		Quantity:     quantity,
+		Note:         options.note,
+	}
+	if options.unit != "" {
+		dto.EnteredUnit = options.unit
+		dto.EnteredQuantity = quantity
+	}
+
+	movement, err := s.movementService.Create(dto)
+	if err != nil {
+		return err
+	}
+	return s.print(movement, []string{"ID", "PRODUCT", "TYPE", "QUANTITY"}, [][]string{{
+		strconv.FormatUint(uint64(movement.ID), 10), product.Code, movement.Type, movement.Quantity.String(),
+	}})
+}
+
+func runBackup(s *session, args []string) error {
+	if err := expectArgs(args, 0); err != nil {
+		return err
+	}
+
+	path, err := s.backupScheduler.BackupNow()
+	if err != nil {
+		return err
+	}
+	return s.print(map[string]string{"path": path}, []string{"BACKUP"}, [][]string{{path}})
+}
+
+func runReconcile(s *session, args []string, fix bool) error {
+	if err := expectArgs(args, 0); err != nil {
+		return err
+	}
+
+	var report *services.StockReconciliationReport
+	var err error
+	if fix {
+		if err := s.require(models.RoleAdmin); err != nil {
+			return err
+		}
+		report, err = s.reconcile.Fix()
+	} else {
+		report, err = s.reconcile.Verify()
+	}
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+	for _, d := range report.Discrepancies {
+		rows = append(rows, []string{d.ProductCode, "", d.StoredStock.String(), d.ComputedStock.String(), d.Difference.String()})
+	}
+	for _, d := range report.BalanceDiscrepancies {
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(d.ProductID), 10), strconv.FormatUint(uint64(d.LocationID), 10),
+			d.StoredStock.String(), d.ComputedStock.String(), d.Difference.String(),
+		})
+	}
+	if err := s.print(report, []string{"PRODUCT", "LOCATION", "STORED", "COMPUTED", "DIFFERENCE"}, rows); err != nil {
+		return err
+	}
+
+	// A script can tell from the exit code that the ledger needs fixing
+	if !report.Fixed && len(rows) > 0 {
+		return fmt.Errorf("%d discrepancies found; run with --fix to correct them", len(rows))
+	}
+	return nil
+}
+
+func runReport(s *session, args []string, options reportOptions) error {
+	if err := expectArgs(args, 1); err != nil {
+		return err
+	}
+	kind := args[0]
+	if kind != reportWorkbook && !services.IsValidReport(kind) {
+		return usagef("unknown report '%s'", kind)
+	}
+	if options.out == "" {
+		return usagef("--out is required")
+	}
+
+	if kind == reportWorkbook {
+		var q services.ExportQuery
+		var err error
+		if q.From, err = parseDate(options.from, false); err != nil {
+			return err
+		}
+		if q.To, err = parseDate(options.to, true); err != nil {
+			return err
+		}
+		if err := s.exportService.ExportWorkbook(options.out, q); err != nil {
+			return err
+		}
+	} else if err := s.writeReport(kind, options.out); err != nil {
+		return err
+	}
+
+	return s.print(map[string]string{"path": options.out}, []string{"REPORT"}, [][]string{{options.out}})
+}
+
+// writeReport writes a PDF report to path, removing the file on failure
+func (s *session) writeReport(kind, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+
+	err = s.reportService.Write(file, kind)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// resolveLocation finds a location by ID or name; empty means the default location
+func (s *session) resolveLocation(value string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	locations, err := s.locationService.GetAll()
+	if err != nil {
+		return 0, err
+	}
+	id, idErr := strconv.ParseUint(value, 10, 64)
+	for _, location := range locations {
+		if (idErr == nil && uint64(location.ID) == id) || strings.EqualFold(location.Name, value) {
+			return location.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("location not found: %s", value)
+}
+
+// parseDate parses a --from or --to date in local time; the end of a range
+// includes the whole day
+func parseDate(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, usagef("invalid date '%s', use YYYY-MM-DD", value)
+	}
+	if end {
+		date = date.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return date, nil
+}
+
+// yesNo formats a flag for table output
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
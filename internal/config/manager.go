@@ -2,15 +2,140 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
 	"os"
+	"stoktakip/internal/models"
 	"stoktakip/internal/utils"
+	"time"
 )
 
 // Config represents the application configuration
 type Config struct {
-	LastDatabase string `json:"last_database"`
-	Theme        string `json:"theme"`
-	Language     string `json:"language"`
+	LastDatabase string       `json:"last_database"`
+	Theme        string       `json:"theme"`
+	Language     string       `json:"language"`
+	Backup       BackupConfig `json:"backup"`
+	API          APIConfig    `json:"api"`
+	Labels       LabelLayout  `json:"labels"`
+}
+
+// BackupConfig controls automatic backups of the active database
+type BackupConfig struct {
+	Enabled       bool `json:"enabled"`
+	IntervalHours int  `json:"interval_hours"` // 0 disables backups on a timer
+	OnShutdown    bool `json:"on_shutdown"`    // Back up when the app closes
+
+	// Retention: the newest backup of each of the last N days, weeks and
+	// months is kept and the rest deleted. All zero keeps every backup.
+	KeepDaily   int `json:"keep_daily"`
+	KeepWeekly  int `json:"keep_weekly"`
+	KeepMonthly int `json:"keep_monthly"`
+}
+
+// Validate checks the backup settings
+func (c BackupConfig) Validate() error {
+	if c.IntervalHours < 0 || c.IntervalHours > 24*365 {
+		return fmt.Errorf("backup interval must be between 0 and %d hours", 24*365)
+	}
+	if c.KeepDaily < 0 || c.KeepWeekly < 0 || c.KeepMonthly < 0 {
+		return fmt.Errorf("backup retention counts cannot be negative")
+	}
+	return nil
+}
+
+// DefaultAPIAddress is where the HTTP API listens unless configured otherwise
+const DefaultAPIAddress = "127.0.0.1:8686"
+
+// APIConfig controls the local HTTP API for integrations
+type APIConfig struct {
+	Enabled     bool       `json:"enabled"`
+	Address     string     `json:"address"`      // host:port to listen on
+	AllowRemote bool       `json:"allow_remote"` // Permit an address other than localhost
+	Tokens      []APIToken `json:"tokens"`
+}
+
+// APIToken grants a client access to the HTTP API with the rights of a role.
+// Only a hash of the token is stored; the token is shown once when created.
+type APIToken struct {
+	Name      string      `json:"name"`
+	Role      models.Role `json:"role"`
+	Hash      string      `json:"hash"` // Hex SHA-256 of the token
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Validate checks the API settings
+func (c APIConfig) Validate() error {
+	host, _, err := net.SplitHostPort(c.Address)
+	if err != nil {
+		return fmt.Errorf("invalid API address '%s': %w", c.Address, err)
+	}
+	if !c.AllowRemote && !isLoopback(host) {
+		return fmt.Errorf("API address '%s' is not on localhost; allow remote access to use it", c.Address)
+	}
+
+	names := make(map[string]bool, len(c.Tokens))
+	for _, token := range c.Tokens {
+		if token.Name == "" {
+			return fmt.Errorf("API token name cannot be empty")
+		}
+		if names[token.Name] {
+			return fmt.Errorf("API token '%s' already exists", token.Name)
+		}
+		names[token.Name] = true
+		if !token.Role.IsValid() {
+			return fmt.Errorf("invalid role for API token '%s': %s", token.Name, token.Role)
+		}
+	}
+	return nil
+}
+
+// LabelLayout describes a sheet of barcode labels. All lengths are in
+// millimetres; labels are placed left to right, then top to bottom.
+type LabelLayout struct {
+	PageWidth   float64 `json:"page_width"`
+	PageHeight  float64 `json:"page_height"`
+	Columns     int     `json:"columns"`
+	Rows        int     `json:"rows"`
+	LabelWidth  float64 `json:"label_width"`
+	LabelHeight float64 `json:"label_height"`
+	MarginLeft  float64 `json:"margin_left"`
+	MarginTop   float64 `json:"margin_top"`
+	GapX        float64 `json:"gap_x"` // Space between columns
+	GapY        float64 `json:"gap_y"` // Space between rows
+}
+
+// Validate checks that the labels fit on the page
+func (l LabelLayout) Validate() error {
+	if l.PageWidth <= 0 || l.PageHeight <= 0 {
+		return fmt.Errorf("label page size must be positive")
+	}
+	if l.Columns < 1 || l.Rows < 1 {
+		return fmt.Errorf("label sheet needs at least one column and one row")
+	}
+	if l.LabelWidth < 20 || l.LabelHeight < 10 {
+		return fmt.Errorf("labels must be at least 20 x 10 mm")
+	}
+	if l.MarginLeft < 0 || l.MarginTop < 0 || l.GapX < 0 || l.GapY < 0 {
+		return fmt.Errorf("label margins and gaps cannot be negative")
+	}
+
+	width := l.MarginLeft + float64(l.Columns)*l.LabelWidth + float64(l.Columns-1)*l.GapX
+	height := l.MarginTop + float64(l.Rows)*l.LabelHeight + float64(l.Rows-1)*l.GapY
+	if width > l.PageWidth+0.01 || height > l.PageHeight+0.01 {
+		return fmt.Errorf("%d x %d labels of %g x %g mm do not fit on a %g x %g mm page",
+			l.Columns, l.Rows, l.LabelWidth, l.LabelHeight, l.PageWidth, l.PageHeight)
+	}
+	return nil
+}
+
+// isLoopback reports whether host names this machine only
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
 // Manager handles configuration file operations
@@ -43,8 +168,8 @@ func (m *Manager) Load() (*Config, error) {
 		return nil, err
 	}
 
-	// Parse JSON
-	var config Config
+	// Parse JSON over the defaults, so settings added later get their default
+	config := *m.getDefaultConfig()
 	if err := json.Unmarshal(data, &config); err != nil {
 		// If parsing fails, return default config
 		m.config = m.getDefaultConfig()
@@ -115,6 +240,85 @@ func (m *Manager) GetTheme() string {
 	return m.config.Theme
 }
 
+// SetLanguage updates the interface language
+func (m *Manager) SetLanguage(language string) error {
+	if m.config == nil {
+		m.config = m.getDefaultConfig()
+	}
+	m.config.Language = language
+	return m.Save()
+}
+
+// GetLanguage returns the interface language
+func (m *Manager) GetLanguage() string {
+	if m.config == nil {
+		m.config = m.getDefaultConfig()
+	}
+	return m.config.Language
+}
+
+// SetBackupSettings updates the automatic backup settings
+func (m *Manager) SetBackupSettings(settings BackupConfig) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	if m.config == nil {
+		m.config = m.getDefaultConfig()
+	}
+	m.config.Backup = settings
+	return m.Save()
+}
+
+// GetBackupSettings returns the automatic backup settings
+func (m *Manager) GetBackupSettings() BackupConfig {
+	if m.config == nil {
+		m.config = m.getDefaultConfig()
+	}
+	return m.config.Backup
+}
+
+// SetAPISettings updates the HTTP API settings
+func (m *Manager) SetAPISettings(settings APIConfig) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	if m.config == nil {
+		m.config = m.getDefaultConfig()
+	}
+	m.config.API = settings
+	return m.Save()
+}
+
+// GetAPISettings returns the HTTP API settings
+func (m *Manager) GetAPISettings() APIConfig {
+	if m.config == nil {
+		m.config = m.getDefaultConfig()
+	}
+	settings := m.config.API
+	settings.Tokens = append([]APIToken(nil), settings.Tokens...)
+	return settings
+}
+
+// SetLabelLayout updates the barcode label sheet layout
+func (m *Manager) SetLabelLayout(layout LabelLayout) error {
+	if err := layout.Validate(); err != nil {
+		return err
+	}
+	if m.config == nil {
+		m.config = m.getDefaultConfig()
+	}
+	m.config.Labels = layout
+	return m.Save()
+}
+
+// GetLabelLayout returns the barcode label sheet layout
+func (m *Manager) GetLabelLayout() LabelLayout {
+	if m.config == nil {
+		m.config = m.getDefaultConfig()
+	}
+	return m.config.Labels
+}
+
 // ClearLastDatabase clears the last database setting
 func (m *Manager) ClearLastDatabase() error {
 	if m.config == nil {
@@ -130,5 +334,28 @@ func (m *Manager) getDefaultConfig() *Config {
 		LastDatabase: "",
 		Theme:        "light",
 		Language:     "tr",
+		Backup: BackupConfig{
+			Enabled:       true,
+			IntervalHours: 24,
+			OnShutdown:    true,
+			KeepDaily:     7,
+			KeepWeekly:    4,
+			KeepMonthly:   12,
+		},
+		API: APIConfig{
+			Enabled: false,
+			Address: DefaultAPIAddress,
+		},
+		// A4 sheet of 3 x 8 labels, 70 x 37 mm each
+		Labels: LabelLayout{
+			PageWidth:   210,
+			PageHeight:  297,
+			Columns:     3,
+			Rows:        8,
+			LabelWidth:  70,
+			LabelHeight: 37,
+			MarginLeft:  0,
+			MarginTop:   0.5,
+		},
 	}
 }
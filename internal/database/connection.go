@@ -4,8 +4,12 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"stoktakip/internal/models"
+	"strings"
 	"sync"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -13,12 +17,22 @@ import (
 	_ "modernc.org/sqlite" // Pure Go SQLite driver (no CGO needed!)
 )
 
+// ConnectHook is called with the new connection after a successful Connect
+type ConnectHook func(db *gorm.DB)
+
 // ConnectionManager manages database connections (Singleton pattern)
 type ConnectionManager struct {
 	db    *gorm.DB
+	path  string
+	vault *encryptedDB // Set while an encrypted database is open
 	mutex sync.RWMutex
+	hooks []ConnectHook
 }
 
+// flushInterval is how often an open encrypted database is checked for
+// committed changes, which are then written back to its file
+const flushInterval = 2 * time.Second
+
 var (
 	instance *ConnectionManager
 	once     sync.Once
@@ -32,29 +46,90 @@ func GetConnectionManager() *ConnectionManager {
 	return instance
 }
 
+// OnConnect registers a hook that runs after every successful Connect
+func (cm *ConnectionManager) OnConnect(hook ConnectHook) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	cm.hooks = append(cm.hooks, hook)
+}
+
 // Connect opens a connection to the specified database file
 func (cm *ConnectionManager) Connect(dbPath string) error {
+	return cm.open(dbPath, "")
+}
+
+// ConnectEncrypted opens an encrypted database file with its passphrase,
+// creating a new encrypted database when the file does not exist
+func (cm *ConnectionManager) ConnectEncrypted(dbPath, passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("passphrase cannot be empty")
+	}
+	return cm.open(dbPath, passphrase)
+}
+
+// open connects and runs the connect hooks
+func (cm *ConnectionManager) open(dbPath, passphrase string) error {
+	removeStaleSnapshots()
+
+	if err := cm.connect(dbPath, passphrase); err != nil {
+		return err
+	}
+
+	// Run hooks outside the lock so they may use the manager themselves
+	cm.mutex.RLock()
+	db := cm.db
+	hooks := append([]ConnectHook(nil), cm.hooks...)
+	cm.mutex.RUnlock()
+
+	for _, hook := range hooks {
+		hook(db)
+	}
+
+	return nil
+}
+
+// connect replaces the current connection with one to dbPath
+func (cm *ConnectionManager) connect(dbPath, passphrase string) error {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 
 	// Close existing connection if any
-	if cm.db != nil {
-		sqlDB, err := cm.db.DB()
-		if err == nil {
-			sqlDB.Close()
+	if err := cm.closeLocked(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	return cm.connectLocked(dbPath, passphrase, nil)
+}
+
+// connectLocked opens dbPath; the caller holds the lock and has closed any
+// previous connection. The database is encrypted when a passphrase or key is
+// given; see openEncrypted.
+func (cm *ConnectionManager) connectLocked(dbPath, passphrase string, key *fileKey) error {
+	// Encrypted files are opened through a plain working copy
+	openPath := dbPath
+	var vault *encryptedDB
+	if passphrase != "" || key != nil {
+		var err error
+		if vault, err = openEncrypted(dbPath, passphrase, key); err != nil {
+			return err
 		}
+		openPath = vault.workPath
+	} else if encrypted, err := IsEncrypted(dbPath); err == nil && encrypted {
+		return fmt.Errorf("database is encrypted, a passphrase is required")
 	}
 
 	// Open database with modernc.org/sqlite (pure Go, no CGO required)
 	// First open with database/sql to use modernc driver
-	sqlDB, err := sql.Open("sqlite", dbPath)
+	sqlDB, err := sql.Open("sqlite", buildDSN(openPath))
 	if err != nil {
+		vault.discard()
 		return fmt.Errorf("failed to open database with modernc sqlite: %w", err)
 	}
 
 	// Test the connection
 	if err := sqlDB.Ping(); err != nil {
 		sqlDB.Close()
+		vault.discard()
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
@@ -65,30 +140,50 @@ func (cm *ConnectionManager) Connect(dbPath string) error {
 
 	if err != nil {
 		sqlDB.Close()
+		vault.discard()
 		return fmt.Errorf("failed to initialize GORM: %w", err)
 	}
 
-	// Configure connection pool
-	poolDB, err := db.DB()
-	if err != nil {
-		return fmt.Errorf("failed to get database instance: %w", err)
-	}
-
 	// Set connection pool settings
-	poolDB.SetMaxOpenConns(10)
-	poolDB.SetMaxIdleConns(5)
+	sqlDB.SetMaxOpenConns(10)
+	sqlDB.SetMaxIdleConns(5)
 
 	// Run migrations
-	if err := cm.runMigrations(db); err != nil {
+	if err := cm.runMigrations(db, dbPath, vault); err != nil {
+		sqlDB.Close()
+		vault.discard()
 		return fmt.Errorf("migration failed: %w", err)
 	}
 
+	// Write new and just migrated encrypted databases out straight away
+	if vault != nil {
+		if err := vault.flush(db); err != nil {
+			sqlDB.Close()
+			vault.discard()
+			return err
+		}
+		go cm.flushPeriodically(vault)
+	}
+
 	cm.db = db
+	cm.path = dbPath
+	cm.vault = vault
 	log.Printf("Successfully connected to database: %s", dbPath)
 
 	return nil
 }
 
+// sqliteTimeFormat is how times are written, one of the formats SQLite's
+// date and time functions understand
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// buildDSN appends the connection options every pooled connection needs.
+// Transactions start with BEGIN IMMEDIATE so concurrent writers queue on the
+// busy timeout instead of failing when a read lock is upgraded to a write lock.
+func buildDSN(dbPath string) string {
+	return dbPath + "?_pragma=busy_timeout(5000)&_txlock=immediate&_time_format=sqlite"
+}
+
 // GetDB returns the current database connection
 func (cm *ConnectionManager) GetDB() *gorm.DB {
 	cm.mutex.RLock()
@@ -103,16 +198,43 @@ func (cm *ConnectionManager) IsConnected() bool {
 	return cm.db != nil
 }
 
+// GetPath returns the file of the current database, or "" when not connected
+func (cm *ConnectionManager) GetPath() string {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.path
+}
+
+// IsEncrypted reports whether the current database is encrypted
+func (cm *ConnectionManager) IsEncrypted() bool {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.vault != nil
+}
+
 // Close closes the current database connection
 func (cm *ConnectionManager) Close() error {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
+	return cm.closeLocked()
+}
 
+// closeLocked writes back and closes the current connection; the caller
+// holds the lock
+func (cm *ConnectionManager) closeLocked() error {
 	if cm.db == nil {
 		return nil
 	}
 
-	sqlDB, err := cm.db.DB()
+	db, vault := cm.db, cm.vault
+	cm.db, cm.path, cm.vault = nil, "", nil
+
+	var flushErr error
+	if vault != nil {
+		flushErr = vault.flush(db)
+	}
+
+	sqlDB, err := db.DB()
 	if err != nil {
 		return err
 	}
@@ -121,19 +243,184 @@ func (cm *ConnectionManager) Close() error {
 		return err
 	}
 
-	cm.db = nil
+	if vault != nil {
+		// Never delete the only up-to-date copy
+		if flushErr != nil {
+			return fmt.Errorf("failed to save encrypted database, unsaved changes are in %s: %w", vault.workPath, flushErr)
+		}
+		vault.discard()
+	}
+
 	log.Println("Database connection closed")
 	return nil
 }
 
-// runMigrations automatically migrates the database schema
-func (cm *ConnectionManager) runMigrations(db *gorm.DB) error {
-	// Auto migrate all models
-	if err := db.AutoMigrate(
-		&models.Category{},
-		&models.Product{},
-		&models.StockMovement{},
-	); err != nil {
+// Encrypt converts the current plain database into an encrypted one and
+// reopens it. The session stays open, so connect hooks do not run.
+func (cm *ConnectionManager) Encrypt(passphrase string) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if cm.db == nil {
+		return fmt.Errorf("no database connection")
+	}
+	if cm.vault != nil {
+		return fmt.Errorf("database is already encrypted")
+	}
+	if passphrase == "" {
+		return fmt.Errorf("passphrase cannot be empty")
+	}
+
+	dbPath := cm.path
+	if err := cm.closeLocked(); err != nil {
+		return err
+	}
+
+	if err := EncryptFile(dbPath, passphrase); err != nil {
+		// Reopen the untouched plain file
+		if reopenErr := cm.connectLocked(dbPath, "", nil); reopenErr != nil {
+			log.Printf("Warning: failed to reopen database: %v", reopenErr)
+		}
+		return err
+	}
+
+	return cm.connectLocked(dbPath, passphrase, nil)
+}
+
+// ChangePassphrase re-encrypts the current database with a new passphrase
+func (cm *ConnectionManager) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	if cm.vault == nil {
+		return fmt.Errorf("database is not encrypted")
+	}
+	if !cm.vault.matches(oldPassphrase) {
+		return ErrWrongPassphrase
+	}
+
+	key, err := newFileKey(newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	return cm.vault.rekey(cm.db, key)
+}
+
+// Backup writes a consistent copy of the current database to backupPath and
+// verifies it. Backups of encrypted databases are encrypted with the same key.
+func (cm *ConnectionManager) Backup(backupPath string) error {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	if cm.db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	// Encrypted databases are snapshotted next to their working copy
+	snapshotPath := backupPath
+	if cm.vault != nil {
+		snapshotPath = filepath.Join(cm.vault.workDir, filepath.Base(backupPath))
+		defer os.Remove(snapshotPath)
+	}
+
+	// VACUUM INTO reads inside one transaction, so the copy is consistent
+	// even while other connections write
+	if err := cm.db.Exec("VACUUM INTO ?", snapshotPath).Error; err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	if err := CheckIntegrity(snapshotPath); err != nil {
+		os.Remove(snapshotPath)
+		return err
+	}
+
+	if cm.vault != nil {
+		if err := cm.vault.encryptCopy(snapshotPath, backupPath); err != nil {
+			return fmt.Errorf("failed to encrypt backup: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CheckIntegrity runs PRAGMA integrity_check on the plain database at path
+func CheckIntegrity(path string) error {
+	sqlDB, err := sql.Open("sqlite", path+"?_pragma=query_only(1)")
+	if err != nil {
+		return fmt.Errorf("failed to open database for integrity check: %w", err)
+	}
+	defer sqlDB.Close()
+
+	rows, err := sqlDB.Query("PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("integrity check failed: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return fmt.Errorf("integrity check failed: %w", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("integrity check failed: %w", err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// flushPeriodically writes changes to vault back soon after they are
+// committed, until it is closed
+func (cm *ConnectionManager) flushPeriodically(vault *encryptedDB) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cm.mutex.RLock()
+		if cm.vault != vault {
+			cm.mutex.RUnlock()
+			return
+		}
+		vault.touch()
+		if err := vault.flush(cm.db); err != nil {
+			log.Printf("Warning: failed to save encrypted database: %v", err)
+		}
+		cm.mutex.RUnlock()
+	}
+}
+
+// runMigrations brings the database schema up to date
+func (cm *ConnectionManager) runMigrations(db *gorm.DB, dbPath string, vault *encryptedDB) error {
+	// Pre-migration backups of encrypted databases are encrypted too
+	backup := func(version int) (string, error) {
+		if vault == nil {
+			return backupBeforeMigration(db, dbPath, version)
+		}
+
+		plainPath, err := backupBeforeMigration(db, vault.workPath, version)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(plainPath)
+
+		backupPath := filepath.Join(filepath.Dir(dbPath), filepath.Base(plainPath))
+		if err := vault.encryptCopy(plainPath, backupPath); err != nil {
+			return "", fmt.Errorf("failed to encrypt backup: %w", err)
+		}
+		return backupPath, nil
+	}
+
+	// Apply pending versioned migrations
+	if err := migrate(db, backup); err != nil {
 		return err
 	}
 
@@ -0,0 +1,580 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+	"gorm.io/gorm"
+)
+
+// Encrypted databases are whole-file encrypted: the file on disk holds a
+// header followed by the SQLite file in AES-256-GCM chunks. While open, the
+// plain file lives in a private folder on the local disk, never next to the
+// encrypted file, which may be on a removable drive. It is written back
+// encrypted shortly after every committed change and on close. A folder left
+// behind by a crash is recovered or removed when the file is opened again.
+//
+// Encryption only covers the database file and the backups made after it.
+// Plain .bak files and backups from before EncryptFile stay readable until
+// they are deleted.
+//
+// Header: magic (16) | scrypt log2(N) (1) | salt (16) | nonce prefix (7)
+// Each chunk is sealed with nonce prefix | chunk index (4) | last flag (1)
+// and the header as additional data, so chunks cannot be reordered,
+// truncated or moved to another file.
+
+const (
+	encryptedMagic = "STOKTAKIP-CRYPT1"
+
+	scryptLogN      = 15
+	saltSize        = 16
+	noncePrefixSize = 7
+	headerSize      = len(encryptedMagic) + 1 + saltSize + noncePrefixSize
+
+	chunkSize = 64 * 1024
+
+	// flushedMarker is the file in a working folder holding the change
+	// counter of the working copy when it was last written back
+	flushedMarker = "flushed"
+	// staleWorkDirAge is how long a working folder must be left untouched
+	// before it counts as left behind; open databases touch theirs on every
+	// flush check
+	staleWorkDirAge = time.Minute
+)
+
+// ErrWrongPassphrase is returned when a passphrase does not open a database
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// fileKey is the key of one encrypted database and the salt it was derived with
+type fileKey struct {
+	logN byte
+	salt []byte
+	key  []byte
+}
+
+// newFileKey derives a key from passphrase with a fresh salt
+func newFileKey(passphrase string) (*fileKey, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	return deriveFileKey(passphrase, scryptLogN, salt)
+}
+
+// deriveFileKey derives the key for passphrase with the given parameters
+func deriveFileKey(passphrase string, logN byte, salt []byte) (*fileKey, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<logN, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return &fileKey{logN: logN, salt: salt, key: key}, nil
+}
+
+// matches reports whether passphrase derives this key
+func (k *fileKey) matches(passphrase string) bool {
+	other, err := deriveFileKey(passphrase, k.logN, k.salt)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(k.key, other.key) == 1
+}
+
+// IsEncrypted reports whether the file at path is an encrypted database,
+// reading only its header
+func IsEncrypted(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(file, magic); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return string(magic) == encryptedMagic, nil
+}
+
+// EncryptFile converts the plain database at path into an encrypted one in
+// place. The database must not be open.
+func EncryptFile(path, passphrase string) error {
+	if encrypted, err := IsEncrypted(path); err != nil {
+		return fmt.Errorf("failed to read database: %w", err)
+	} else if encrypted {
+		return fmt.Errorf("database is already encrypted")
+	}
+
+	key, err := newFileKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	return encryptFile(path, path, key)
+}
+
+// encryptedDB is an open encrypted database: the encrypted file and the
+// plain working copy the connection uses
+type encryptedDB struct {
+	path     string // Encrypted file
+	workDir  string // Private folder holding the working copy
+	workPath string
+
+	mu      sync.Mutex
+	key     *fileKey
+	flushed int64 // Change counter of the working copy when last written back; -1 forces the next flush
+}
+
+// workDirPrefix returns the start of the names of the working folders of
+// dbPath in the temporary folder, keyed by a hash of its absolute path
+func workDirPrefix(dbPath string) string {
+	if abs, err := filepath.Abs(dbPath); err == nil {
+		dbPath = abs
+	}
+	sum := sha256.Sum256([]byte(dbPath))
+	return "stoktakip-work-" + hex.EncodeToString(sum[:8]) + "-"
+}
+
+// openEncrypted decrypts dbPath into a new working copy. A missing file
+// starts a new encrypted database. When known is set the file may be opened
+// with it and is written back with it, whatever key it was encrypted with.
+func openEncrypted(dbPath, passphrase string, known *fileKey) (*encryptedDB, error) {
+	workDir, err := os.MkdirTemp("", workDirPrefix(dbPath)+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create working folder: %w", err)
+	}
+
+	vault := &encryptedDB{
+		path:     dbPath,
+		workDir:  workDir,
+		workPath: filepath.Join(workDir, filepath.Base(dbPath)),
+		flushed:  -1,
+	}
+
+	exists := true
+	if _, err = os.Stat(dbPath); os.IsNotExist(err) {
+		exists = false
+		vault.key, err = newFileKey(passphrase)
+	} else {
+		vault.key, err = decryptFile(dbPath, vault.workPath, passphrase, known)
+		if err == nil {
+			vault.flushed = changeCounter(vault.workPath)
+			if known != nil && vault.key != known {
+				// Re-encrypt with the known key on the first flush
+				vault.key = known
+				vault.flushed = -1
+			}
+		}
+	}
+	if err != nil {
+		os.RemoveAll(workDir)
+		return nil, err
+	}
+
+	// Only now that the key is known may a left-behind copy be adopted
+	vault.recoverStale(exists)
+
+	return vault, nil
+}
+
+// recoverStale handles the working folders earlier sessions of the database
+// left in the temporary folder. The newest copy holding changes that were never written back
+// replaces the fresh working copy, unless the database file is gone; all
+// left-behind folders are then removed. Folders touched recently belong to
+// an open session and are left alone.
+func (v *encryptedDB) recoverStale(exists bool) {
+	dir := os.TempDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	var stale []string
+	var newest string
+	var newestAt time.Time
+	for _, entry := range entries {
+		workDir := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), workDirPrefix(v.path)) || workDir == v.workDir {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleWorkDirAge {
+			continue
+		}
+		stale = append(stale, workDir)
+
+		// The integrity check also rolls back a transaction the crash interrupted
+		workPath := filepath.Join(workDir, filepath.Base(v.path))
+		if !exists || CheckIntegrity(workPath) != nil || !unsaved(workDir, workPath) {
+			continue
+		}
+		if changed := modTime(workPath); newest == "" || changed.After(newestAt) {
+			newest, newestAt = workPath, changed
+		}
+	}
+
+	if newest != "" {
+		if err := os.Rename(newest, v.workPath); err != nil {
+			log.Printf("Warning: failed to recover unsaved changes from %s: %v", newest, err)
+			return
+		}
+		v.flushed = -1
+		log.Printf("Recovered unsaved changes from %s", newest)
+	}
+	for _, workDir := range stale {
+		if err := os.RemoveAll(workDir); err != nil {
+			log.Printf("Warning: failed to remove working folder %s: %v", workDir, err)
+		}
+	}
+}
+
+// unsaved reports whether the working copy in workDir changed after it was
+// last written back
+func unsaved(workDir, workPath string) bool {
+	data, err := os.ReadFile(filepath.Join(workDir, flushedMarker))
+	if err != nil {
+		return true
+	}
+	flushed, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return err != nil || flushed != changeCounter(workPath)
+}
+
+// matches reports whether passphrase opens the database
+func (v *encryptedDB) matches(passphrase string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.key.matches(passphrase)
+}
+
+// currentKey returns the key the file is written with
+func (v *encryptedDB) currentKey() *fileKey {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.key
+}
+
+// flush writes the working copy back encrypted if it changed since the last
+// flush. A consistent snapshot is taken, so the connection stays usable.
+func (v *encryptedDB) flush(db *gorm.DB) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.flushLocked(db)
+}
+
+// rekey switches to key and rewrites the file with it
+func (v *encryptedDB) rekey(db *gorm.DB, key *fileKey) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	previous := v.key
+	v.key = key
+	v.flushed = -1
+	if err := v.flushLocked(db); err != nil {
+		v.key = previous
+		return err
+	}
+	return nil
+}
+
+// flushLocked does the work of flush; the caller holds v.mu
+func (v *encryptedDB) flushLocked(db *gorm.DB) error {
+	// Read before the snapshot: a commit in between only causes another flush
+	counter := changeCounter(v.workPath)
+	if v.flushed >= 0 && counter == v.flushed {
+		return nil
+	}
+
+	snapshotPath := filepath.Join(v.workDir, "snapshot.db")
+	os.Remove(snapshotPath)
+	defer os.Remove(snapshotPath)
+
+	if err := db.Exec("VACUUM INTO ?", snapshotPath).Error; err != nil {
+		return fmt.Errorf("failed to snapshot encrypted database: %w", err)
+	}
+	if err := encryptFile(snapshotPath, v.path, v.key); err != nil {
+		return err
+	}
+
+	v.flushed = counter
+	if err := os.WriteFile(filepath.Join(v.workDir, flushedMarker), []byte(strconv.FormatInt(counter, 10)), 0600); err != nil {
+		log.Printf("Warning: failed to record flush: %v", err)
+	}
+	return nil
+}
+
+// touch marks the working folder as in use, see staleWorkDirAge
+func (v *encryptedDB) touch() {
+	now := time.Now()
+	os.Chtimes(v.workDir, now, now)
+}
+
+// encryptCopy writes the plain file src to dst encrypted with this database's key
+func (v *encryptedDB) encryptCopy(src, dst string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return encryptFile(src, dst, v.key)
+}
+
+// discard deletes the working copy. It is safe to call on nil.
+func (v *encryptedDB) discard() {
+	if v == nil {
+		return
+	}
+	os.RemoveAll(v.workDir)
+}
+
+// changeCounter returns the file change counter SQLite keeps in the header
+// of the database at path and bumps on every committed write, or -1
+func changeCounter(path string) int64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return -1
+	}
+	defer file.Close()
+
+	counter := make([]byte, 4)
+	if _, err := file.ReadAt(counter, 24); err != nil {
+		return -1
+	}
+	return int64(binary.BigEndian.Uint32(counter))
+}
+
+// modTime returns the modification time of path, or the zero time
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// encryptFile writes src encrypted with key to dst, replacing dst only once
+// the encrypted copy is complete. src and dst may be the same file.
+func encryptFile(src, dst string, key *fileKey) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer in.Close()
+
+	tmpPath := dst + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create encrypted file: %w", err)
+	}
+
+	if err := writeEncrypted(out, in, key); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write encrypted file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write encrypted file: %w", err)
+	}
+
+	// Windows cannot replace a file that is still open
+	in.Close()
+
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace encrypted file: %w", err)
+	}
+
+	return nil
+}
+
+// decryptFile writes the plain database in src to dst and returns its key.
+// known is used when src was encrypted with it, passphrase otherwise.
+func decryptFile(src, dst, passphrase string, known *fileKey) (*fileKey, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create working copy: %w", err)
+	}
+
+	key, err := readEncrypted(out, in, passphrase, known)
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write working copy: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(dst)
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// writeEncrypted writes the header and the sealed chunks of r to w
+func writeEncrypted(w io.Writer, r io.Reader, key *fileKey) error {
+	header := make([]byte, 0, headerSize)
+	header = append(header, encryptedMagic...)
+	header = append(header, key.logN)
+	header = append(header, key.salt...)
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(noncePrefix); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	header = append(header, noncePrefix...)
+
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write encrypted file: %w", err)
+	}
+
+	aead, err := newAEAD(key.key)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(r, chunkSize)
+	plain := make([]byte, chunkSize)
+	sealed := make([]byte, 0, chunkSize+aead.Overhead())
+	for index := uint32(0); ; index++ {
+		n, err := io.ReadFull(reader, plain)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("failed to read database: %w", err)
+		}
+
+		last := n < chunkSize
+		if !last {
+			if _, err := reader.Peek(1); errors.Is(err, io.EOF) {
+				last = true
+			}
+		}
+
+		sealed = aead.Seal(sealed[:0], chunkNonce(noncePrefix, index, last), plain[:n], header)
+		if _, err := w.Write(sealed); err != nil {
+			return fmt.Errorf("failed to write encrypted file: %w", err)
+		}
+
+		if last {
+			return nil
+		}
+		if index == ^uint32(0) {
+			return fmt.Errorf("database is too large to encrypt")
+		}
+	}
+}
+
+// readEncrypted checks the header, derives or picks the key and writes the
+// opened chunks of r to w
+func readEncrypted(w io.Writer, r io.Reader, passphrase string, known *fileKey) (*fileKey, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.HasPrefix(header, []byte(encryptedMagic)) {
+		return nil, fmt.Errorf("not an encrypted database")
+	}
+
+	offset := len(encryptedMagic)
+	logN := header[offset]
+	salt := append([]byte(nil), header[offset+1:offset+1+saltSize]...)
+	noncePrefix := header[offset+1+saltSize:]
+	if logN < 10 || logN > 22 {
+		return nil, fmt.Errorf("unsupported encryption parameters")
+	}
+
+	var key *fileKey
+	var err error
+	switch {
+	case known != nil && known.logN == logN && bytes.Equal(known.salt, salt):
+		key = known
+	case passphrase != "":
+		if key, err = deriveFileKey(passphrase, logN, salt); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrWrongPassphrase
+	}
+
+	aead, err := newAEAD(key.key)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReaderSize(r, chunkSize+aead.Overhead())
+	sealed := make([]byte, chunkSize+aead.Overhead())
+	plain := make([]byte, 0, chunkSize)
+	for index := uint32(0); ; index++ {
+		n, err := io.ReadFull(reader, sealed)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("failed to read database: %w", err)
+		}
+
+		last := n < len(sealed)
+		if !last {
+			if _, err := reader.Peek(1); errors.Is(err, io.EOF) {
+				last = true
+			}
+		}
+
+		plain, err = aead.Open(plain[:0], chunkNonce(noncePrefix, index, last), sealed[:n], header)
+		if err != nil {
+			if index == 0 {
+				return nil, ErrWrongPassphrase
+			}
+			return nil, fmt.Errorf("encrypted database is damaged")
+		}
+		if _, err := w.Write(plain); err != nil {
+			return nil, fmt.Errorf("failed to write working copy: %w", err)
+		}
+
+		if last {
+			return key, nil
+		}
+	}
+}
+
+// newAEAD returns AES-256-GCM for key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce builds the nonce of one chunk
+func chunkNonce(prefix []byte, index uint32, last bool) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, index)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
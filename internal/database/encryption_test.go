@@ -0,0 +1,259 @@
+package database
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stoktakip/internal/models"
+)
+
+// testKey derives a key cheaply; production keys use scryptLogN
+func testKey(t *testing.T, passphrase string) *fileKey {
+	t.Helper()
+	key, err := deriveFileKey(passphrase, 10, bytes.Repeat([]byte{7}, saltSize))
+	if err != nil {
+		t.Fatalf("derive key: %v", err)
+	}
+	return key
+}
+
+// encrypt seals plain with key
+func encrypt(t *testing.T, plain []byte, key *fileKey) []byte {
+	t.Helper()
+	var sealed bytes.Buffer
+	if err := writeEncrypted(&sealed, bytes.NewReader(plain), key); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	return sealed.Bytes()
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	key := testKey(t, "secret")
+	sizes := []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 100}
+	for _, size := range sizes {
+		plain := make([]byte, size)
+		rand.Read(plain)
+
+		sealed := encrypt(t, plain, key)
+		if !bytes.HasPrefix(sealed, []byte(encryptedMagic)) {
+			t.Fatalf("size %d: encrypted data lacks the magic", size)
+		}
+
+		var opened bytes.Buffer
+		got, err := readEncrypted(&opened, bytes.NewReader(sealed), "secret", nil)
+		if err != nil {
+			t.Fatalf("size %d: decrypt: %v", size, err)
+		}
+		if !bytes.Equal(got.key, key.key) {
+			t.Errorf("size %d: decrypt derived another key", size)
+		}
+		if !bytes.Equal(opened.Bytes(), plain) {
+			t.Errorf("size %d: decrypted data differs", size)
+		}
+	}
+}
+
+func TestEncryptFileInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plain.db")
+	plain := bytes.Repeat([]byte("stoktakip"), chunkSize/4)
+	if err := os.WriteFile(path, plain, 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := EncryptFile(path, "secret"); err != nil {
+		t.Fatalf("encrypt file: %v", err)
+	}
+	if encrypted, err := IsEncrypted(path); err != nil || !encrypted {
+		t.Fatalf("IsEncrypted = %v, %v, want true", encrypted, err)
+	}
+	if err := EncryptFile(path, "secret"); err == nil {
+		t.Errorf("encrypting twice succeeded")
+	}
+
+	plainPath := filepath.Join(t.TempDir(), "opened.db")
+	if _, err := decryptFile(path, plainPath, "secret", nil); err != nil {
+		t.Fatalf("decrypt file: %v", err)
+	}
+	opened, err := os.ReadFile(plainPath)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(opened, plain) {
+		t.Errorf("decrypted file differs")
+	}
+}
+
+func TestEncryptedWrongPassphrase(t *testing.T) {
+	sealed := encrypt(t, bytes.Repeat([]byte{1}, 2*chunkSize), testKey(t, "secret"))
+
+	tests := []struct {
+		name       string
+		passphrase string
+		known      *fileKey
+	}{
+		{name: "other passphrase", passphrase: "Secret"},
+		{name: "no passphrase"},
+		{name: "key of another file", known: testKey(t, "other")},
+	}
+	for _, tt := range tests {
+		var opened bytes.Buffer
+		_, err := readEncrypted(&opened, bytes.NewReader(sealed), tt.passphrase, tt.known)
+		if !errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("%s: error = %v, want ErrWrongPassphrase", tt.name, err)
+		}
+		if opened.Len() != 0 {
+			t.Errorf("%s: %d bytes written with a wrong key", tt.name, opened.Len())
+		}
+	}
+}
+
+func TestEncryptedDamage(t *testing.T) {
+	key := testKey(t, "secret")
+	plain := make([]byte, 3*chunkSize+100)
+	rand.Read(plain)
+	sealed := encrypt(t, plain, key)
+	sealedChunk := chunkSize + 16 // GCM tag
+
+	tampered := func(offset int) []byte {
+		data := bytes.Clone(sealed)
+		data[offset] ^= 1
+		return data
+	}
+	// The first chunk opening proves the key, so later chunks are swapped
+	chunk := func(index int) []byte {
+		return sealed[headerSize+index*sealedChunk : headerSize+(index+1)*sealedChunk]
+	}
+	swapped := bytes.Clone(sealed[:headerSize+sealedChunk])
+	swapped = append(append(append(swapped, chunk(2)...), chunk(1)...), sealed[headerSize+3*sealedChunk:]...)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "last chunk dropped", data: sealed[:headerSize+3*sealedChunk]},
+		{name: "truncated mid chunk", data: sealed[:headerSize+sealedChunk+100]},
+		{name: "tag truncated", data: sealed[:len(sealed)-1]},
+		{name: "byte appended", data: append(bytes.Clone(sealed), 0)},
+		{name: "second chunk tampered", data: tampered(headerSize + sealedChunk + 5)},
+		{name: "last chunk tampered", data: tampered(len(sealed) - 1)},
+		{name: "chunks swapped", data: swapped},
+	}
+	for _, tt := range tests {
+		var opened bytes.Buffer
+		_, err := readEncrypted(&opened, bytes.NewReader(tt.data), "secret", nil)
+		if err == nil {
+			t.Errorf("%s: damaged data opened", tt.name)
+			continue
+		}
+		if errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("%s: damage reported as a wrong passphrase", tt.name)
+		}
+	}
+
+	// The header is authenticated with every chunk
+	var opened bytes.Buffer
+	if _, err := readEncrypted(&opened, bytes.NewReader(tampered(headerSize-1)), "secret", nil); err == nil {
+		t.Errorf("tampered header opened")
+	}
+	if _, err := readEncrypted(&opened, bytes.NewReader(sealed[:headerSize-1]), "secret", nil); err == nil {
+		t.Errorf("truncated header opened")
+	}
+}
+
+func TestChangePassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "encrypted.db")
+	cm := &ConnectionManager{}
+	if err := cm.ConnectEncrypted(path, "old"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := cm.GetDB().Create(&models.Category{Name: "Kept", Color: "#000000"}).Error; err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+
+	if err := cm.ChangePassphrase("wrong", "new"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("change with wrong passphrase: error = %v, want ErrWrongPassphrase", err)
+	}
+	if err := cm.ChangePassphrase("old", "new"); err != nil {
+		t.Fatalf("change passphrase: %v", err)
+	}
+	if err := cm.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if err := cm.ConnectEncrypted(path, "old"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("open with old passphrase: error = %v, want ErrWrongPassphrase", err)
+	}
+	if err := cm.ConnectEncrypted(path, "new"); err != nil {
+		t.Fatalf("open with new passphrase: %v", err)
+	}
+	defer cm.Close()
+
+	var count int64
+	if err := cm.GetDB().Model(&models.Category{}).Where("name = ?", "Kept").Count(&count).Error; err != nil {
+		t.Fatalf("count categories: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("categories named Kept = %d, want 1", count)
+	}
+}
+
+func TestWorkingCopyRecoveredFromLocalDisk(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "encrypted.db")
+
+	cm := &ConnectionManager{}
+	if err := cm.ConnectEncrypted(path, "secret"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := cm.GetDB().Create(&models.Category{Name: "Unsaved", Color: "#000000"}).Error; err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("list database folder: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "encrypted.db" {
+			t.Errorf("%s written next to the encrypted file", entry.Name())
+		}
+	}
+
+	// Crash: drop the connection without writing back
+	workDir := cm.vault.workDir
+	sqlDB, err := cm.GetDB().DB()
+	if err != nil {
+		t.Fatalf("get connection: %v", err)
+	}
+	cm.db, cm.path, cm.vault = nil, "", nil
+	sqlDB.Close()
+	old := time.Now().Add(-2 * staleWorkDirAge)
+	if err := os.Chtimes(workDir, old, old); err != nil {
+		t.Fatalf("age working folder: %v", err)
+	}
+
+	if err := cm.ConnectEncrypted(path, "secret"); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	var count int64
+	if err := cm.GetDB().Model(&models.Category{}).Where("name = ?", "Unsaved").Count(&count).Error; err != nil {
+		t.Fatalf("count categories: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("categories named Unsaved = %d, want 1", count)
+	}
+	if err := cm.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("%d working folders left after close", len(entries))
+	}
+}
@@ -0,0 +1,654 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"stoktakip/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a single, numbered schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+}
+
+// SchemaMigration records a migration that has been applied to a database file
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"size:200;not null" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
+
+// TableName specifies the table name for SchemaMigration model
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// migrations lists every schema change in the order it must be applied.
+// Never edit or reorder an entry that has shipped; append a new one instead.
+// Migrations must not use the live models, which keep changing; they use
+// frozen snapshot structs or plain SQL.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		Up: func(tx *gorm.DB) error {
+			// AutoMigrate also adopts files created before versioning existed
+			return tx.AutoMigrate(
+				&v1Category{},
+				&v1Product{},
+				&v1StockMovement{},
+			)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "locations and per-location stock balances",
+		Up: func(tx *gorm.DB) error {
+			now := time.Now()
+			if err := execAll(tx,
+				`CREATE TABLE locations (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					name varchar(100) NOT NULL,
+					description varchar(500),
+					is_default numeric DEFAULT false,
+					created_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE UNIQUE INDEX idx_locations_name ON locations(name)`,
+				`CREATE TABLE stock_balances (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					product_id integer NOT NULL,
+					location_id integer NOT NULL,
+					quantity integer NOT NULL DEFAULT 0,
+					updated_at datetime
+				)`,
+				`CREATE UNIQUE INDEX idx_stock_balance_product_location ON stock_balances(product_id, location_id)`,
+				`CREATE INDEX idx_stock_balances_location_id ON stock_balances(location_id)`,
+				// SQLite does not enforce varchar lengths, so "TRANSFER" fits the existing type column
+				`ALTER TABLE stock_movements ADD COLUMN location_id integer NOT NULL DEFAULT 0`,
+				`ALTER TABLE stock_movements ADD COLUMN to_location_id integer`,
+				`CREATE INDEX idx_stock_movements_location_id ON stock_movements(location_id)`,
+				`CREATE INDEX idx_stock_movements_to_location_id ON stock_movements(to_location_id)`,
+			); err != nil {
+				return err
+			}
+
+			// Everything held so far is in the main depot
+			if err := tx.Exec(`INSERT INTO locations (name, description, is_default, created_at, updated_at)
+				VALUES (?, '', true, ?, ?)`, "Ana Depo", now, now).Error; err != nil {
+				return err
+			}
+			return execAll(tx,
+				`UPDATE stock_movements SET location_id = (SELECT id FROM locations WHERE is_default)`,
+				`INSERT INTO stock_balances (product_id, location_id, quantity, updated_at)
+					SELECT id, (SELECT id FROM locations WHERE is_default), current_stock, updated_at
+					FROM products WHERE current_stock <> 0`,
+			)
+		},
+	},
+	{
+		Version: 3,
+		Name:    "fixed-point quantities and money, unit precision",
+		Up: func(tx *gorm.DB) error {
+			if err := execAll(tx,
+				`CREATE TABLE units (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					name varchar(20) NOT NULL COLLATE NOCASE,
+					decimals integer NOT NULL DEFAULT 0,
+					created_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE UNIQUE INDEX idx_units_name ON units(name)`,
+				// Quantities become thousandths and prices ten-thousandths (see models.Quantity, models.Money)
+				`UPDATE products SET
+					price = CAST(ROUND(COALESCE(price, 0) * 10000) AS INTEGER),
+					current_stock = COALESCE(current_stock, 0) * 1000,
+					critical_limit = COALESCE(critical_limit, 0) * 1000`,
+				`UPDATE stock_movements SET quantity = quantity * 1000`,
+				`UPDATE stock_balances SET quantity = quantity * 1000`,
+			); err != nil {
+				return err
+			}
+
+			now := time.Now()
+			defaultUnits := []struct {
+				Name     string
+				Decimals int
+			}{
+				{"adet", 0}, {"paket", 0}, {"kutu", 0},
+				{"kg", 3}, {"g", 1}, {"litre", 3}, {"ml", 0}, {"m", 2}, {"m²", 2},
+			}
+			for _, unit := range defaultUnits {
+				if err := tx.Exec(`INSERT INTO units (name, decimals, created_at, updated_at) VALUES (?, ?, ?, ?)`,
+					unit.Name, unit.Decimals, now, now).Error; err != nil {
+					return err
+				}
+			}
+
+			// Units already in use stay whole-numbered, as they were
+			return tx.Exec(`INSERT OR IGNORE INTO units (name, decimals, created_at, updated_at)
+				SELECT DISTINCT TRIM(unit), 0, ?, ? FROM products WHERE TRIM(unit) <> ''`, now, now).Error
+		},
+	},
+	{
+		Version: 4,
+		Name:    "unit catalog and unit conversions",
+		Up: func(tx *gorm.DB) error {
+			if err := execAll(tx,
+				// Link products to the unit catalog; units.name is NOCASE, so "Adet" finds "adet"
+				`ALTER TABLE products ADD COLUMN unit_id integer`,
+				`CREATE INDEX idx_products_unit_id ON products(unit_id)`,
+				`UPDATE products SET unit_id = (SELECT id FROM units WHERE units.name = TRIM(products.unit))`,
+				`UPDATE products SET unit = (SELECT name FROM units WHERE units.id = products.unit_id) WHERE unit_id IS NOT NULL`,
+				`CREATE TABLE unit_conversions (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					product_id integer,
+					from_unit_id integer NOT NULL,
+					to_unit_id integer NOT NULL,
+					factor integer NOT NULL,
+					created_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE INDEX idx_unit_conversions_product_id ON unit_conversions(product_id)`,
+				`CREATE INDEX idx_unit_conversions_from_unit_id ON unit_conversions(from_unit_id)`,
+				`CREATE INDEX idx_unit_conversions_to_unit_id ON unit_conversions(to_unit_id)`,
+				// Existing movements were recorded in the product's own unit
+				`ALTER TABLE stock_movements ADD COLUMN entered_unit_id integer`,
+				`ALTER TABLE stock_movements ADD COLUMN entered_quantity integer NOT NULL DEFAULT 0`,
+				`CREATE INDEX idx_stock_movements_entered_unit_id ON stock_movements(entered_unit_id)`,
+				`UPDATE stock_movements SET entered_quantity = quantity,
+					entered_unit_id = (SELECT unit_id FROM products WHERE products.id = stock_movements.product_id)`,
+			); err != nil {
+				return err
+			}
+
+			// Conversions that hold for every product (factors are fixed-point thousandths)
+			now := time.Now()
+			return tx.Exec(`INSERT INTO unit_conversions (product_id, from_unit_id, to_unit_id, factor, created_at, updated_at)
+				SELECT NULL, f.id, t.id, 1000000, ?, ? FROM units f JOIN units t
+				ON (f.name = 'kg' AND t.name = 'g') OR (f.name = 'litre' AND t.name = 'ml')`, now, now).Error
+		},
+	},
+	{
+		Version: 5,
+		Name:    "normalize stored timestamps",
+		Up: func(tx *gorm.DB) error {
+			// Older builds stored time.Time.String(), which SQLite's date
+			// functions cannot read; rewrite them in the format now written
+			columns := map[string][]string{
+				"categories":       {"created_at", "updated_at"},
+				"products":         {"created_at", "updated_at"},
+				"stock_movements":  {"date", "created_at"},
+				"locations":        {"created_at", "updated_at"},
+				"stock_balances":   {"updated_at"},
+				"units":            {"created_at", "updated_at"},
+				"unit_conversions": {"created_at", "updated_at"},
+			}
+			for table, names := range columns {
+				for _, column := range names {
+					if err := normalizeTimestamps(tx, table, column); err != nil {
+						return fmt.Errorf("%s.%s: %w", table, column, err)
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "audit log",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`CREATE TABLE audit_log (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					entity varchar(50) NOT NULL,
+					entity_id integer NOT NULL,
+					action varchar(10) NOT NULL,
+					before text,
+					after text,
+					actor varchar(100),
+					created_at datetime NOT NULL
+				)`,
+				`CREATE INDEX idx_audit_log_entity ON audit_log(entity, entity_id)`,
+				`CREATE INDEX idx_audit_log_action ON audit_log(action)`,
+				`CREATE INDEX idx_audit_log_created_at ON audit_log(created_at)`,
+				// Append-only: rows can be added but never changed or removed
+				`CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+					BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END`,
+				`CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+					BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END`,
+			)
+		},
+	},
+	{
+		Version: 7,
+		Name:    "users and movement authors",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`CREATE TABLE users (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					username varchar(50) NOT NULL COLLATE NOCASE,
+					password_hash varchar(100) NOT NULL,
+					role varchar(10) NOT NULL,
+					active numeric NOT NULL DEFAULT true,
+					created_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE UNIQUE INDEX idx_users_username ON users(username)`,
+				`ALTER TABLE stock_movements ADD COLUMN user_id integer`,
+				`CREATE INDEX idx_stock_movements_user_id ON stock_movements(user_id)`,
+			)
+		},
+	},
+	{
+		Version: 8,
+		Name:    "indexes for paged lists",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				// Movement lists sort and filter by the date as an instant
+				`CREATE INDEX idx_stock_movements_julianday_date ON stock_movements(julianday(date))`,
+				`CREATE INDEX idx_stock_movements_product_julianday_date ON stock_movements(product_id, julianday(date))`,
+				`CREATE INDEX idx_stock_movements_created_at ON stock_movements(created_at)`,
+				`CREATE INDEX idx_products_current_stock ON products(current_stock)`,
+				`CREATE INDEX idx_products_category_name ON products(category_id, name)`,
+			)
+		},
+	},
+	{
+		Version: 9,
+		Name:    "product full-text search",
+		Up: func(tx *gorm.DB) error {
+			// The row ID is the product ID; columns hold FoldSearchText output
+			if err := execAll(tx,
+				`CREATE VIRTUAL TABLE product_search USING fts5(
+					code, name, category, description,
+					tokenize = 'unicode61 remove_diacritics 2',
+					prefix = '2 3'
+				)`,
+			); err != nil {
+				return err
+			}
+
+			var rows []struct {
+				ID          uint
+				Code        string
+				Name        string
+				Category    string
+				Description string
+			}
+			if err := tx.Raw(`SELECT p.id, p.code, p.name,
+					COALESCE(c.name, '') AS category, COALESCE(c.description, '') AS description
+				FROM products p LEFT JOIN categories c ON c.id = p.category_id`).Scan(&rows).Error; err != nil {
+				return err
+			}
+			for _, row := range rows {
+				if err := tx.Exec(`INSERT INTO product_search(rowid, code, name, category, description) VALUES (?, ?, ?, ?, ?)`,
+					row.ID, FoldSearchText(row.Code), FoldSearchText(row.Name),
+					FoldSearchText(row.Category), FoldSearchText(row.Description)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 10,
+		Name:    "product barcodes",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`CREATE TABLE product_barcodes (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					product_id integer NOT NULL,
+					barcode varchar(64) NOT NULL,
+					symbology varchar(10) NOT NULL,
+					created_at datetime
+				)`,
+				`CREATE UNIQUE INDEX idx_product_barcodes_barcode ON product_barcodes(barcode)`,
+				`CREATE INDEX idx_product_barcodes_product_id ON product_barcodes(product_id)`,
+			)
+		},
+	},
+	{
+		Version: 11,
+		Name:    "stock counts",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`CREATE TABLE stock_counts (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					name varchar(100) NOT NULL,
+					note text,
+					status varchar(10) NOT NULL,
+					category_id integer,
+					location_id integer NOT NULL,
+					opened_by integer,
+					closed_by integer,
+					created_at datetime,
+					closed_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE INDEX idx_stock_counts_status ON stock_counts(status)`,
+				`CREATE INDEX idx_stock_counts_category_id ON stock_counts(category_id)`,
+				`CREATE TABLE stock_count_lines (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					count_id integer NOT NULL,
+					product_id integer NOT NULL,
+					expected integer NOT NULL,
+					price integer NOT NULL,
+					counted integer,
+					counted_by integer,
+					counted_at datetime,
+					movement_id integer
+				)`,
+				`CREATE UNIQUE INDEX idx_stock_count_lines_count_product ON stock_count_lines(count_id, product_id)`,
+				`CREATE INDEX idx_stock_count_lines_product_id ON stock_count_lines(product_id)`,
+			)
+		},
+	},
+	{
+		Version: 12,
+		Name:    "suppliers and purchase orders",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`CREATE TABLE suppliers (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					name varchar(100) NOT NULL,
+					phone varchar(50),
+					email varchar(100),
+					note text,
+					created_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE UNIQUE INDEX idx_suppliers_name ON suppliers(name)`,
+				`CREATE TABLE purchase_orders (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					number varchar(20) NOT NULL,
+					supplier_id integer NOT NULL,
+					status varchar(10) NOT NULL,
+					location_id integer NOT NULL,
+					expected_date datetime,
+					note text,
+					created_by integer,
+					sent_at datetime,
+					closed_at datetime,
+					created_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE UNIQUE INDEX idx_purchase_orders_number ON purchase_orders(number)`,
+				`CREATE INDEX idx_purchase_orders_supplier_id ON purchase_orders(supplier_id)`,
+				`CREATE INDEX idx_purchase_orders_status ON purchase_orders(status)`,
+				`CREATE TABLE purchase_order_lines (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					order_id integer NOT NULL,
+					product_id integer NOT NULL,
+					quantity integer NOT NULL,
+					received integer NOT NULL DEFAULT 0,
+					unit_price integer NOT NULL DEFAULT 0,
+					note varchar(500)
+				)`,
+				`CREATE INDEX idx_purchase_order_lines_order_id ON purchase_order_lines(order_id)`,
+				`CREATE INDEX idx_purchase_order_lines_product_id ON purchase_order_lines(product_id)`,
+				`ALTER TABLE stock_movements ADD COLUMN purchase_order_line_id integer`,
+				`CREATE INDEX idx_stock_movements_purchase_order_line_id ON stock_movements(purchase_order_line_id)`,
+			)
+		},
+	},
+	{
+		Version: 13,
+		Name:    "supplier details and product suppliers",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`ALTER TABLE suppliers ADD COLUMN tax_number varchar(20)`,
+				`ALTER TABLE suppliers ADD COLUMN contact varchar(100)`,
+				`ALTER TABLE suppliers ADD COLUMN payment_term_days integer NOT NULL DEFAULT 0`,
+				`CREATE TABLE product_suppliers (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					product_id integer NOT NULL,
+					supplier_id integer NOT NULL,
+					supplier_code varchar(50),
+					last_price integer NOT NULL DEFAULT 0,
+					lead_time_days integer NOT NULL DEFAULT 0,
+					min_order_quantity integer NOT NULL DEFAULT 0,
+					created_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE UNIQUE INDEX idx_product_suppliers_product_supplier ON product_suppliers(product_id, supplier_id)`,
+				`CREATE INDEX idx_product_suppliers_supplier_id ON product_suppliers(supplier_id)`,
+			)
+		},
+	},
+	{
+		Version: 14,
+		Name:    "customers and sales orders",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`CREATE TABLE customers (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					name varchar(100) NOT NULL,
+					kind varchar(20) NOT NULL,
+					tax_number varchar(20),
+					contact varchar(100),
+					phone varchar(50),
+					email varchar(100),
+					note text,
+					created_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE UNIQUE INDEX idx_customers_name ON customers(name)`,
+				`CREATE INDEX idx_customers_kind ON customers(kind)`,
+				`CREATE TABLE sales_orders (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					number varchar(20) NOT NULL,
+					customer_id integer NOT NULL,
+					status varchar(10) NOT NULL,
+					location_id integer NOT NULL,
+					due_date datetime,
+					note text,
+					created_by integer,
+					confirmed_at datetime,
+					closed_at datetime,
+					created_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE UNIQUE INDEX idx_sales_orders_number ON sales_orders(number)`,
+				`CREATE INDEX idx_sales_orders_customer_id ON sales_orders(customer_id)`,
+				`CREATE INDEX idx_sales_orders_status ON sales_orders(status)`,
+				`CREATE TABLE sales_order_lines (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					order_id integer NOT NULL,
+					product_id integer NOT NULL,
+					quantity integer NOT NULL,
+					shipped integer NOT NULL DEFAULT 0,
+					unit_price integer NOT NULL DEFAULT 0,
+					note varchar(500)
+				)`,
+				`CREATE INDEX idx_sales_order_lines_order_id ON sales_order_lines(order_id)`,
+				`CREATE INDEX idx_sales_order_lines_product_id ON sales_order_lines(product_id)`,
+				`ALTER TABLE stock_movements ADD COLUMN customer_id integer`,
+				`ALTER TABLE stock_movements ADD COLUMN sales_order_line_id integer`,
+				`CREATE INDEX idx_stock_movements_customer_id ON stock_movements(customer_id)`,
+				`CREATE INDEX idx_stock_movements_sales_order_line_id ON stock_movements(sales_order_line_id)`,
+			)
+		},
+	},
+	{
+		Version: 15,
+		Name:    "stock reservations",
+		Up: func(tx *gorm.DB) error {
+			return execAll(tx,
+				`CREATE TABLE stock_reservations (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					product_id integer NOT NULL,
+					quantity integer NOT NULL,
+					fulfilled integer NOT NULL DEFAULT 0,
+					status varchar(10) NOT NULL,
+					reason varchar(200),
+					reference varchar(50),
+					expires_at datetime,
+					sales_order_line_id integer,
+					created_by integer,
+					released_at datetime,
+					created_at datetime,
+					updated_at datetime
+				)`,
+				`CREATE INDEX idx_stock_reservations_product_id ON stock_reservations(product_id)`,
+				`CREATE INDEX idx_stock_reservations_status ON stock_reservations(status)`,
+				`CREATE INDEX idx_stock_reservations_expires_at ON stock_reservations(expires_at)`,
+				`CREATE INDEX idx_stock_reservations_sales_order_line_id ON stock_reservations(sales_order_line_id)`,
+				`ALTER TABLE stock_movements ADD COLUMN reservation_id integer`,
+				`CREATE INDEX idx_stock_movements_reservation_id ON stock_movements(reservation_id)`,
+				// Confirmed sales orders reserved stock by their open lines until now
+				`INSERT INTO stock_reservations (product_id, quantity, fulfilled, status, reference, sales_order_line_id,
+					created_by, released_at, created_at, updated_at)
+				SELECT l.product_id, l.quantity, l.shipped,
+					CASE WHEN o.status = 'CANCELLED' THEN 'RELEASED' WHEN l.shipped >= l.quantity THEN 'FULFILLED' ELSE 'ACTIVE' END,
+					o.number, l.id, o.created_by,
+					CASE WHEN o.status = 'CANCELLED' OR l.shipped >= l.quantity THEN COALESCE(o.closed_at, o.updated_at) END,
+					o.confirmed_at, o.updated_at
+				FROM sales_order_lines AS l
+				JOIN sales_orders AS o ON o.id = l.order_id
+				WHERE o.confirmed_at IS NOT NULL`,
+				`UPDATE stock_movements SET reservation_id =
+					(SELECT r.id FROM stock_reservations AS r WHERE r.sales_order_line_id = stock_movements.sales_order_line_id)
+				WHERE sales_order_line_id IS NOT NULL`,
+			)
+		},
+	},
+}
+
+// LatestSchemaVersion returns the schema version this build of the app writes
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// migrate applies all pending migrations, each in its own transaction
+func migrate(db *gorm.DB, backup func(version int) (string, error)) error {
+	// Databases created before versioning have tables but no version table
+	hasData := db.Migrator().HasTable(&models.Product{})
+
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	current, err := currentSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d; please update the application", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	// Keep a copy of the file as it was before upgrading it
+	if hasData && backup != nil {
+		backupPath, err := backup(current)
+		if err != nil {
+			return err
+		}
+		log.Printf("Backed up database before migrating to schema version %d: %s", latest, backupPath)
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+
+		log.Printf("Applied migration %d: %s", m.Version, m.Name)
+	}
+
+	return nil
+}
+
+// execAll runs each statement in order, stopping at the first error
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeTimestamps rewrites every value of a datetime column in the
+// format the connection writes (see buildDSN)
+func normalizeTimestamps(tx *gorm.DB, table, column string) error {
+	var rows []struct {
+		RowID int64
+		Value string
+	}
+	if err := tx.Raw(fmt.Sprintf(`SELECT rowid AS row_id, CAST(%s AS TEXT) AS value FROM %s WHERE %s IS NOT NULL`,
+		column, table, column)).Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		// time.Time.String() may carry a monotonic clock suffix ("m=+0.01")
+		value := row.Value
+		if i := strings.Index(value, " m="); i >= 0 {
+			value = value[:i]
+		}
+
+		t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", value)
+		if err != nil {
+			continue // Already in a format SQLite understands
+		}
+
+		if err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = ? WHERE rowid = ?`, table, column),
+			t.Format(sqliteTimeFormat), row.RowID).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// currentSchemaVersion returns the highest applied migration version, or 0
+func currentSchemaVersion(db *gorm.DB) (int, error) {
+	var version int
+	if err := db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// backupBeforeMigration writes a consistent copy of the database next to it.
+// The .bak extension keeps the copy out of the database list.
+func backupBeforeMigration(db *gorm.DB, dbPath string, version int) (string, error) {
+	base := strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+	backupName := fmt.Sprintf("%s_v%d_%s.db.bak", base, version, time.Now().Format("20060102_150405"))
+	backupPath := filepath.Join(filepath.Dir(dbPath), backupName)
+
+	// VACUUM INTO refuses to overwrite, so clear a leftover from the same second
+	os.Remove(backupPath)
+
+	if err := db.Exec("VACUUM INTO ?", backupPath).Error; err != nil {
+		return "", fmt.Errorf("failed to back up database before migration: %w", err)
+	}
+
+	return backupPath, nil
+}
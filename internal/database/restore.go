@@ -0,0 +1,280 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Snapshot is a private plain copy of a database file, opened for reading
+// and upgraded to the current schema so it can be compared with the live
+// database
+type Snapshot struct {
+	DB *gorm.DB
+
+	sqlDB   *sql.DB
+	workDir string
+}
+
+// Close closes the snapshot and deletes the copy
+func (s *Snapshot) Close() {
+	s.sqlDB.Close()
+	os.RemoveAll(s.workDir)
+}
+
+// OpenSnapshot copies the database file at path and opens the copy. Encrypted
+// files open with the current database's key, or with passphrase when they
+// were encrypted with another one.
+func (cm *ConnectionManager) OpenSnapshot(path, passphrase string) (*Snapshot, error) {
+	cm.mutex.RLock()
+	var key *fileKey
+	if cm.vault != nil {
+		key = cm.vault.currentKey()
+	}
+	cm.mutex.RUnlock()
+
+	workDir, err := os.MkdirTemp("", snapshotDirPrefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create working folder: %w", err)
+	}
+
+	snapshot, err := openSnapshot(path, filepath.Join(workDir, filepath.Base(path)), passphrase, key)
+	if err != nil {
+		os.RemoveAll(workDir)
+		return nil, err
+	}
+	snapshot.workDir = workDir
+
+	return snapshot, nil
+}
+
+// snapshotDirPrefix starts the names of the temporary folders of snapshots
+const snapshotDirPrefix = "stoktakip-snapshot-"
+
+// removeStaleSnapshots deletes the snapshot folders a crashed session left
+// in the temporary folder. Snapshots are closed right after use, so folders
+// older than staleWorkDirAge are no longer in use.
+func removeStaleSnapshots() {
+	tempDir := os.TempDir()
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), snapshotDirPrefix) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) >= staleWorkDirAge {
+			os.RemoveAll(filepath.Join(tempDir, entry.Name()))
+		}
+	}
+}
+
+// openSnapshot writes a plain copy of path to plainPath, checks it and opens it
+func openSnapshot(path, plainPath, passphrase string, key *fileKey) (*Snapshot, error) {
+	encrypted, err := IsEncrypted(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+
+	if encrypted {
+		_, err = decryptFile(path, plainPath, passphrase, key)
+	} else {
+		err = copyFile(path, plainPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckIntegrity(plainPath); err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := sql.Open("sqlite", buildDSN(plainPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db, err := gorm.Open(sqlite.Dialector{Conn: sqlDB}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to initialize GORM: %w", err)
+	}
+
+	// Older backups are upgraded like the live database would be on restore
+	if err := migrate(db, nil); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("migration failed: %w", err)
+	}
+
+	return &Snapshot{DB: db, sqlDB: sqlDB}, nil
+}
+
+// Restore replaces the current database file with the backup at backupPath
+// and reconnects. The current file is kept next to it as a safety copy,
+// whose path is returned. If the restored file cannot be opened, the current
+// file is put back and reopened.
+//
+// A restored encrypted database keeps the current key. An encrypted backup
+// made with another passphrase needs that passphrase.
+func (cm *ConnectionManager) Restore(backupPath, passphrase string) (string, error) {
+	safetyPath, err := cm.restore(backupPath, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	// The restored database is a different database to the hooks
+	cm.mutex.RLock()
+	db := cm.db
+	hooks := append([]ConnectHook(nil), cm.hooks...)
+	cm.mutex.RUnlock()
+
+	for _, hook := range hooks {
+		hook(db)
+	}
+
+	return safetyPath, nil
+}
+
+// restore does the work of Restore under the lock
+func (cm *ConnectionManager) restore(backupPath, passphrase string) (string, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if cm.db == nil {
+		return "", fmt.Errorf("no database connection")
+	}
+
+	dbPath := cm.path
+	var key *fileKey
+	if cm.vault != nil {
+		key = cm.vault.currentKey()
+	}
+
+	backupEncrypted, err := IsEncrypted(backupPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	// Stage the backup next to the database so the swap is a rename
+	stagedPath := dbPath + ".restore"
+	if err := copyFile(backupPath, stagedPath); err != nil {
+		return "", err
+	}
+	defer os.Remove(stagedPath)
+
+	// Never leave a plain copy where an encrypted database was
+	if key != nil && !backupEncrypted {
+		if err := encryptFile(stagedPath, stagedPath, key); err != nil {
+			return "", err
+		}
+	}
+
+	if err := cm.closeLocked(); err != nil {
+		return "", err
+	}
+
+	safetyPath := safetyCopyPath(dbPath)
+	if err := os.Rename(dbPath, safetyPath); err != nil {
+		cm.reopen(dbPath, key)
+		return "", fmt.Errorf("failed to set the current database aside: %w", err)
+	}
+
+	if err := os.Rename(stagedPath, dbPath); err != nil {
+		cm.rollBack(dbPath, safetyPath, key)
+		return "", fmt.Errorf("failed to put the backup in place: %w", err)
+	}
+
+	restoredEncrypted := key != nil || backupEncrypted
+	if restoredEncrypted {
+		err = cm.connectLocked(dbPath, passphrase, key)
+	} else {
+		err = cm.connectLocked(dbPath, "", nil)
+	}
+	if err != nil {
+		cm.rollBack(dbPath, safetyPath, key)
+		return "", fmt.Errorf("restored database failed to open, the previous database was put back: %w", err)
+	}
+
+	log.Printf("Restored database from %s, previous file kept as %s", backupPath, safetyPath)
+	return safetyPath, nil
+}
+
+// rollBack puts the safety copy back in place and reopens it
+func (cm *ConnectionManager) rollBack(dbPath, safetyPath string, key *fileKey) {
+	os.Remove(dbPath)
+	if err := os.Rename(safetyPath, dbPath); err != nil {
+		log.Printf("Warning: failed to put back %s: %v", safetyPath, err)
+		return
+	}
+	cm.reopen(dbPath, key)
+}
+
+// reopen reconnects to the database that was open before a failed restore
+func (cm *ConnectionManager) reopen(dbPath string, key *fileKey) {
+	if err := cm.connectLocked(dbPath, "", key); err != nil {
+		log.Printf("Warning: failed to reopen database: %v", err)
+	}
+}
+
+// safetyCopyPath returns an unused name for the copy of dbPath kept by a restore
+func safetyCopyPath(dbPath string) string {
+	base := strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+	name := fmt.Sprintf("%s_before_restore_%s", base, time.Now().Format("20060102_150405"))
+
+	path := filepath.Join(filepath.Dir(dbPath), name+".db.bak")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(filepath.Dir(dbPath), fmt.Sprintf("%s_%d.db.bak", name, i))
+	}
+}
+
+// copyFile copies src to dst through a temporary file
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(src), err)
+	}
+	defer in.Close()
+
+	tmpPath := dst + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Base(dst), err)
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to copy %s: %w", filepath.Base(src), err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to copy %s: %w", filepath.Base(src), err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to copy %s: %w", filepath.Base(src), err)
+	}
+
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to copy %s: %w", filepath.Base(src), err)
+	}
+
+	return nil
+}
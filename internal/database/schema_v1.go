@@ -0,0 +1,57 @@
+package database
+
+import (
+	"time"
+)
+
+// Snapshot of the models as they were at schema version 1. Migration 1 must
+// keep creating exactly this schema, whatever the live models look like now.
+
+type v1Category struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"size:100;not null;index"`
+	Description string `gorm:"size:500"`
+	Color       string `gorm:"size:7;default:#6B7280"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (v1Category) TableName() string {
+	return "categories"
+}
+
+type v1Product struct {
+	ID            uint    `gorm:"primaryKey"`
+	Code          string  `gorm:"size:50;uniqueIndex;not null"`
+	Name          string  `gorm:"size:200;not null;index"`
+	CategoryID    uint    `gorm:"not null;index"`
+	Unit          string  `gorm:"size:20;not null"`
+	CriticalLimit int     `gorm:"default:0"`
+	Price         float64 `gorm:"type:decimal(10,2);default:0"`
+	CurrentStock  int     `gorm:"default:0"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+
+	Category  v1Category        `gorm:"foreignKey:CategoryID"`
+	Movements []v1StockMovement `gorm:"foreignKey:ProductID"`
+}
+
+func (v1Product) TableName() string {
+	return "products"
+}
+
+type v1StockMovement struct {
+	ID        uint      `gorm:"primaryKey"`
+	ProductID uint      `gorm:"not null;index"`
+	Type      string    `gorm:"type:varchar(3);not null;index"`
+	Quantity  int       `gorm:"not null"`
+	Date      time.Time `gorm:"not null;index"`
+	Note      string    `gorm:"type:text"`
+	CreatedAt time.Time
+
+	Product v1Product `gorm:"foreignKey:ProductID"`
+}
+
+func (v1StockMovement) TableName() string {
+	return "stock_movements"
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FoldSearchText lower-cases text and folds Turkish letters to their Latin
+// base letter, so "ŞIŞE", "şişe" and "sise" are indexed and searched alike.
+// The product_search table stores folded text only.
+func FoldSearchText(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case 'I', 'İ', 'ı':
+			return 'i'
+		case 'Ş', 'ş':
+			return 's'
+		case 'Ğ', 'ğ':
+			return 'g'
+		case 'Ç', 'ç':
+			return 'c'
+		case 'Ö', 'ö':
+			return 'o'
+		case 'Ü', 'ü':
+			return 'u'
+		}
+		return unicode.ToLower(r)
+	}, text)
+}
@@ -0,0 +1,26 @@
+package database
+
+import "testing"
+
+func TestFoldSearchText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"ŞİŞE", "sise"},
+		{"şişe", "sise"},
+		{"Işık", "isik"},
+		{"ILIK", "ilik"},
+		{"iı İI", "ii ii"},
+		{"Dağ Ğ", "dag g"},
+		{"Çörek ÖÜÇ", "corek ouc"},
+		{"Ürün-12 \"A*\"", "urun-12 \"a*\""},
+		{"ÄÉ", "äé"}, // Other letters are only lower-cased
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := FoldSearchText(tt.in); got != tt.want {
+			t.Errorf("FoldSearchText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditAction is the kind of change an audit entry records
+type AuditAction string
+
+const (
+	AuditActionCreate AuditAction = "CREATE"
+	AuditActionUpdate AuditAction = "UPDATE"
+	AuditActionDelete AuditAction = "DELETE"
+)
+
+// IsValid checks if the audit action is valid
+func (a AuditAction) IsValid() bool {
+	return a == AuditActionCreate || a == AuditActionUpdate || a == AuditActionDelete
+}
+
+// AuditEntry records one change to an entity. The table is append-only.
+type AuditEntry struct {
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	Entity    string      `gorm:"size:50;not null;index:idx_audit_log_entity" json:"entity"`
+	EntityID  uint        `gorm:"not null;index:idx_audit_log_entity" json:"entity_id"`
+	Action    AuditAction `gorm:"size:10;not null;index" json:"action"`
+	Before    string      `gorm:"type:text" json:"before"` // JSON snapshot, empty on CREATE
+	After     string      `gorm:"type:text" json:"after"`  // JSON snapshot, empty on DELETE
+	Actor     string      `gorm:"size:100" json:"actor"`
+	CreatedAt time.Time   `gorm:"not null;index" json:"created_at"`
+}
+
+// TableName specifies the table name for AuditEntry model
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
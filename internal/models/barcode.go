@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// BarcodeSymbology is the encoding of a barcode
+type BarcodeSymbology string
+
+const (
+	BarcodeEAN13   BarcodeSymbology = "EAN13"
+	BarcodeEAN8    BarcodeSymbology = "EAN8"
+	BarcodeUPCA    BarcodeSymbology = "UPCA"
+	BarcodeCode128 BarcodeSymbology = "CODE128" // Any printable ASCII text
+)
+
+// IsValid checks if the symbology is valid
+func (b BarcodeSymbology) IsValid() bool {
+	return b == BarcodeEAN13 || b == BarcodeEAN8 || b == BarcodeUPCA || b == BarcodeCode128
+}
+
+// ProductBarcode is one of the barcodes a product can be scanned by
+type ProductBarcode struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	ProductID uint             `gorm:"not null;index" json:"product_id"`
+	Barcode   string           `gorm:"size:64;not null;uniqueIndex" json:"barcode"`
+	Symbology BarcodeSymbology `gorm:"type:varchar(10);not null" json:"symbology"`
+	CreatedAt time.Time        `json:"created_at"`
+
+	// Relations
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+// TableName specifies the table name for ProductBarcode model
+func (ProductBarcode) TableName() string {
+	return "product_barcodes"
+}
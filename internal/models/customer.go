@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// CustomerKind tells what an issue destination is
+type CustomerKind string
+
+const (
+	CustomerKindCustomer   CustomerKind = "CUSTOMER"    // A company or person goods are sold to
+	CustomerKindCostCenter CustomerKind = "COST_CENTER" // An internal department consuming stock
+	CustomerKindProject    CustomerKind = "PROJECT"     // A job stock is issued to
+)
+
+// IsValid checks if the kind is valid
+func (k CustomerKind) IsValid() bool {
+	return k == CustomerKindCustomer || k == CustomerKindCostCenter || k == CustomerKindProject
+}
+
+// Customer is a destination OUT movements and sales orders issue stock to
+type Customer struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	Name      string       `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Kind      CustomerKind `gorm:"type:varchar(20);not null;index" json:"kind"`
+	TaxNumber string       `gorm:"size:20" json:"tax_number"`
+	Contact   string       `gorm:"size:100" json:"contact"` // Contact person
+	Phone     string       `gorm:"size:50" json:"phone"`
+	Email     string       `gorm:"size:100" json:"email"`
+	Note      string       `gorm:"type:text" json:"note"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+
+	// Relations
+	SalesOrders []SalesOrder `gorm:"foreignKey:CustomerID" json:"-"`
+}
+
+// TableName specifies the table name for Customer model
+func (Customer) TableName() string {
+	return "customers"
+}
@@ -0,0 +1,296 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Quantities and money are fixed-point integers so that sums and stock
+// values are exact. They are stored as INTEGER columns and travel as plain
+// JSON numbers ("12.5"), so the frontend keeps working with numbers.
+
+const (
+	// QuantityDecimals is the largest number of decimals any unit may use
+	QuantityDecimals = 3
+	// MoneyDecimals is the precision of prices and stock values
+	MoneyDecimals = 4
+
+	quantityScale = 1000
+	moneyScale    = 10000
+)
+
+// Quantity is an amount of stock in thousandths of the product's unit
+type Quantity int64
+
+// Money is an amount of currency in ten-thousandths
+type Money int64
+
+// NewQuantity returns a whole-unit quantity
+func NewQuantity(units int64) Quantity {
+	return Quantity(units * quantityScale)
+}
+
+// ParseQuantity parses a decimal string such as "12.5" exactly
+func ParseQuantity(s string) (Quantity, error) {
+	v, err := parseScaled(s, QuantityDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity '%s': %w", s, err)
+	}
+	return Quantity(v), nil
+}
+
+// Decimals returns the number of significant decimal places
+func (q Quantity) Decimals() int {
+	return significantDecimals(int64(q), QuantityDecimals)
+}
+
+// Float64 returns the quantity as a float, for display only
+func (q Quantity) Float64() float64 {
+	return float64(q) / quantityScale
+}
+
+// String formats the quantity without trailing zeros
+func (q Quantity) String() string {
+	return formatScaled(int64(q), QuantityDecimals)
+}
+
+// MulPrice returns the value of q units at unit price p, rounded half away from zero
+func (q Quantity) MulPrice(p Money) Money {
+	product := new(big.Int).Mul(big.NewInt(int64(q)), big.NewInt(int64(p)))
+	return Money(roundDiv(product, quantityScale))
+}
+
+// MulExact returns q multiplied by factor, and false if the result cannot be
+// represented exactly or does not fit
+func (q Quantity) MulExact(factor Quantity) (Quantity, bool) {
+	product := new(big.Int).Mul(big.NewInt(int64(q)), big.NewInt(int64(factor)))
+	return exactQuotient(product, big.NewInt(quantityScale))
+}
+
+// DivExact returns q divided by divisor, and false if the result cannot be
+// represented exactly or does not fit
+func (q Quantity) DivExact(divisor Quantity) (Quantity, bool) {
+	if divisor == 0 {
+		return 0, false
+	}
+	numerator := new(big.Int).Mul(big.NewInt(int64(q)), big.NewInt(quantityScale))
+	return exactQuotient(numerator, big.NewInt(int64(divisor)))
+}
+
+// MarshalJSON encodes the quantity as a JSON number
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or numeric string
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalScaled(data, QuantityDecimals)
+	if err != nil {
+		return fmt.Errorf("invalid quantity: %w", err)
+	}
+	*q = Quantity(v)
+	return nil
+}
+
+// Value implements driver.Valuer
+func (q Quantity) Value() (driver.Value, error) {
+	return int64(q), nil
+}
+
+// Scan implements sql.Scanner
+func (q *Quantity) Scan(src interface{}) error {
+	v, err := scanScaled(src)
+	if err != nil {
+		return fmt.Errorf("cannot scan quantity: %w", err)
+	}
+	*q = Quantity(v)
+	return nil
+}
+
+// ParseMoney parses a decimal string such as "19.99" exactly
+func ParseMoney(s string) (Money, error) {
+	v, err := parseScaled(s, MoneyDecimals)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount '%s': %w", s, err)
+	}
+	return Money(v), nil
+}
+
+// Float64 returns the amount as a float, for display only
+func (m Money) Float64() float64 {
+	return float64(m) / moneyScale
+}
+
+// String formats the amount without trailing zeros
+func (m Money) String() string {
+	return formatScaled(int64(m), MoneyDecimals)
+}
+
+// Round2 rounds the amount to whole cents, half away from zero
+func (m Money) Round2() Money {
+	return Money(roundDiv(big.NewInt(int64(m)), 100) * 100)
+}
+
+// MarshalJSON encodes the amount as a JSON number
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or numeric string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	v, err := unmarshalScaled(data, MoneyDecimals)
+	if err != nil {
+		return fmt.Errorf("invalid amount: %w", err)
+	}
+	*m = Money(v)
+	return nil
+}
+
+// Value implements driver.Valuer
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+// Scan implements sql.Scanner
+func (m *Money) Scan(src interface{}) error {
+	v, err := scanScaled(src)
+	if err != nil {
+		return fmt.Errorf("cannot scan amount: %w", err)
+	}
+	*m = Money(v)
+	return nil
+}
+
+// parseScaled parses a plain decimal string into an integer with the given
+// number of implied decimals. Extra decimals are an error, never rounded.
+func parseScaled(s string, decimals int) (int64, error) {
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("empty number")
+	}
+	if len(frac) > decimals {
+		if strings.TrimRight(frac[decimals:], "0") != "" {
+			return 0, fmt.Errorf("more than %d decimal places", decimals)
+		}
+		frac = frac[:decimals]
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("not a decimal number")
+		}
+	}
+
+	digits := strings.TrimLeft(whole+frac+strings.Repeat("0", decimals-len(frac)), "0")
+	if digits == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("number out of range")
+	}
+
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// formatScaled formats an integer with the given number of implied decimals
+func formatScaled(v int64, decimals int) string {
+	sign := ""
+	u := uint64(v)
+	if v < 0 {
+		sign = "-"
+		u = uint64(-v)
+	}
+
+	scale := uint64(math.Pow10(decimals))
+	whole, frac := u/scale, u%scale
+	if frac == 0 {
+		return sign + strconv.FormatUint(whole, 10)
+	}
+
+	fracText := fmt.Sprintf("%0*d", decimals, frac)
+	return sign + strconv.FormatUint(whole, 10) + "." + strings.TrimRight(fracText, "0")
+}
+
+// significantDecimals counts the decimals of v that are not trailing zeros
+func significantDecimals(v int64, decimals int) int {
+	if v < 0 {
+		v = -v
+	}
+	for decimals > 0 && v%10 == 0 {
+		v /= 10
+		decimals--
+	}
+	return decimals
+}
+
+// roundDiv divides n by d, rounding half away from zero
+func roundDiv(n *big.Int, d int64) int64 {
+	divisor := big.NewInt(d)
+	quotient, remainder := new(big.Int).QuoRem(n, divisor, new(big.Int))
+	twiceRemainder := new(big.Int).Abs(remainder)
+	twiceRemainder.Lsh(twiceRemainder, 1)
+	if twiceRemainder.Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(n.Sign())))
+	}
+	return quotient.Int64()
+}
+
+// exactQuotient divides n by d when the division leaves no remainder
+func exactQuotient(n, d *big.Int) (Quantity, bool) {
+	quotient, remainder := new(big.Int).QuoRem(n, d, new(big.Int))
+	if remainder.Sign() != 0 || !quotient.IsInt64() {
+		return 0, false
+	}
+	return Quantity(quotient.Int64()), true
+}
+
+// unmarshalScaled decodes a JSON number, numeric string or null
+func unmarshalScaled(data []byte, decimals int) (int64, error) {
+	text := string(data)
+	if text == "null" {
+		return 0, nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return 0, err
+		}
+		if text == "" {
+			return 0, nil
+		}
+	}
+	return parseScaled(text, decimals)
+}
+
+// scanScaled reads a stored fixed-point integer
+func scanScaled(src interface{}) (int64, error) {
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return v, nil
+	case float64:
+		// Only produced by hand-edited rows; the stored value is already scaled
+		return int64(math.Round(v)), nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", src)
+	}
+}
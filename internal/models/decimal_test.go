@@ -0,0 +1,193 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Quantity
+		wantErr bool
+	}{
+		{in: "12", want: 12000},
+		{in: "12.5", want: 12500},
+		{in: " 0.001 ", want: 1},
+		{in: ".5", want: 500},
+		{in: "5.", want: 5000},
+		{in: "-1.25", want: -1250},
+		{in: "+2", want: 2000},
+		{in: "-0", want: 0},
+		{in: "1.2340", want: 1234},
+		{in: "9223372036854775.807", want: math.MaxInt64},
+		{in: "-9223372036854775.807", want: -math.MaxInt64},
+		{in: "1.2345", wantErr: true},
+		{in: "0.0001", wantErr: true},
+		{in: "9223372036854775.808", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "1,5", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseQuantity(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseQuantity(%q) = %d, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseQuantity(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseQuantity(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: "19.99", want: 199900},
+		{in: "0.0001", want: 1},
+		{in: "-3.5", want: -35000},
+		{in: "+0.25", want: 2500},
+		{in: "7.12340", want: 71234},
+		{in: "922337203685477.5807", want: math.MaxInt64},
+		{in: "0.00001", wantErr: true},
+		{in: "922337203685477.5808", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q) = %d, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	quantities := []struct {
+		in   Quantity
+		want string
+	}{
+		{0, "0"},
+		{12000, "12"},
+		{12500, "12.5"},
+		{1, "0.001"},
+		{-500, "-0.5"},
+		{-1250, "-1.25"},
+		{math.MaxInt64, "9223372036854775.807"},
+	}
+	for _, tt := range quantities {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Quantity(%d).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	amounts := []struct {
+		in   Money
+		want string
+	}{
+		{0, "0"},
+		{199900, "19.99"},
+		{1, "0.0001"},
+		{-35000, "-3.5"},
+		{10000, "1"},
+	}
+	for _, tt := range amounts {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuantityMulPrice(t *testing.T) {
+	tests := []struct {
+		q    Quantity
+		p    Money
+		want Money
+	}{
+		{NewQuantity(3), 199900, 599700},    // 3 * 19.99
+		{1500, 3333, 5000},                  // 1.5 * 0.3333 = 0.49995, half rounds up
+		{-1500, 3333, -5000},                // and away from zero when negative
+		{1499, 3333, 4996},                  // 1.499 * 0.3333 = 0.4996167
+		{1, 4, 0},                           // 0.001 * 0.0004 = 0.0000004
+		{1, 5000, 5},                        // 0.001 * 0.5 = 0.0005
+		{1, 500, 1},                         // 0.001 * 0.05 = 0.00005, half rounds up
+		{-1, 500, -1},                       // -0.00005
+		{0, 199900, 0},                      // nothing in stock
+		{NewQuantity(1000000), 10000, 1e10}, // 1000000 * 1
+	}
+	for _, tt := range tests {
+		if got := tt.q.MulPrice(tt.p); got != tt.want {
+			t.Errorf("Quantity(%d).MulPrice(%d) = %d, want %d", tt.q, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestQuantityMulExact(t *testing.T) {
+	tests := []struct {
+		q, factor Quantity
+		want      Quantity
+		wantOK    bool
+	}{
+		{2500, 1500, 3750, true}, // 2.5 * 1.5
+		{NewQuantity(12), NewQuantity(24), NewQuantity(288), true},
+		{-2000, 500, -1000, true},                 // -2 * 0.5
+		{1, 1, 0, false},                          // 0.001 * 0.001 needs six decimals
+		{1500, 1001, 0, false},                    // 1.5 * 1.001 = 1.5015
+		{math.MaxInt64, NewQuantity(2), 0, false}, // does not fit
+		{0, 1, 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := tt.q.MulExact(tt.factor)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("Quantity(%d).MulExact(%d) = %d, %v, want %d, %v", tt.q, tt.factor, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestQuantityDivExact(t *testing.T) {
+	tests := []struct {
+		q, divisor Quantity
+		want       Quantity
+		wantOK     bool
+	}{
+		{NewQuantity(3), 1500, NewQuantity(2), true}, // 3 / 1.5
+		{NewQuantity(288), NewQuantity(12), NewQuantity(24), true},
+		{1, NewQuantity(1), 1, true},                  // 0.001 / 1
+		{-NewQuantity(1), 250, -NewQuantity(4), true}, // -1 / 0.25
+		{NewQuantity(1), NewQuantity(3), 0, false},    // 0.333...
+		{1, NewQuantity(1000), 0, false},              // 0.000001
+		{NewQuantity(1), 0, 0, false},                 // division by zero
+		{math.MaxInt64, 1, 0, false},                  // does not fit
+	}
+	for _, tt := range tests {
+		got, ok := tt.q.DivExact(tt.divisor)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("Quantity(%d).DivExact(%d) = %d, %v, want %d, %v", tt.q, tt.divisor, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
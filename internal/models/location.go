@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// Location represents a place where stock is kept (depot, workshop, van, etc.)
+type Location struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Description string    `gorm:"size:500" json:"description"`
+	IsDefault   bool      `gorm:"default:false" json:"is_default"` // Used when a movement has no location
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Relations
+	Balances []StockBalance `gorm:"foreignKey:LocationID" json:"-"`
+}
+
+// TableName specifies the table name for Location model
+func (Location) TableName() string {
+	return "locations"
+}
+
+// StockBalance holds the stock of one product at one location
+type StockBalance struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ProductID  uint      `gorm:"not null;uniqueIndex:idx_stock_balance_product_location" json:"product_id"`
+	LocationID uint      `gorm:"not null;uniqueIndex:idx_stock_balance_product_location;index" json:"location_id"`
+	Quantity   Quantity  `gorm:"not null;default:0" json:"quantity"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Relations
+	Product  Product  `gorm:"foreignKey:ProductID" json:"-"`
+	Location Location `gorm:"foreignKey:LocationID" json:"location"`
+}
+
+// TableName specifies the table name for StockBalance model
+func (StockBalance) TableName() string {
+	return "stock_balances"
+}
@@ -8,22 +8,38 @@ import (
 type MovementType string
 
 const (
-	MovementTypeIn  MovementType = "IN"
-	MovementTypeOut MovementType = "OUT"
+	MovementTypeIn         MovementType = "IN"
+	MovementTypeOut        MovementType = "OUT"
+	MovementTypeTransfer   MovementType = "TRANSFER"   // Between two locations, total stock unchanged
+	MovementTypeAdjustment MovementType = "ADJUSTMENT" // Stock count correction, the only signed quantity
 )
 
-// StockMovement represents a stock movement (in or out)
+// StockMovement represents a stock movement (in, out, transfer or adjustment)
 type StockMovement struct {
-	ID        uint         `gorm:"primaryKey" json:"id"`
-	ProductID uint         `gorm:"not null;index" json:"product_id"`
-	Type      MovementType `gorm:"type:varchar(3);not null;index" json:"type"`
-	Quantity  int          `gorm:"not null" json:"quantity"` // Always positive
-	Date      time.Time    `gorm:"not null;index" json:"date"`
-	Note      string       `gorm:"type:text" json:"note"`
-	CreatedAt time.Time    `json:"created_at"`
+	ID                  uint         `gorm:"primaryKey" json:"id"`
+	ProductID           uint         `gorm:"not null;index" json:"product_id"`
+	LocationID          uint         `gorm:"not null;index" json:"location_id"` // Source location for OUT and TRANSFER
+	ToLocationID        *uint        `gorm:"index" json:"to_location_id"`       // Destination, TRANSFER only
+	Type                MovementType `gorm:"type:varchar(10);not null;index" json:"type"`
+	Quantity            Quantity     `gorm:"not null" json:"quantity"`     // Positive, in the product's base unit; negative ADJUSTMENT removes stock
+	EnteredUnitID       *uint        `gorm:"index" json:"entered_unit_id"` // Unit the quantity was recorded in
+	EnteredQuantity     Quantity     `gorm:"not null;default:0" json:"entered_quantity"`
+	Date                time.Time    `gorm:"not null;index" json:"date"`
+	Note                string       `gorm:"type:text" json:"note"`
+	UserID              *uint        `gorm:"index" json:"user_id"`                // Who recorded the movement
+	PurchaseOrderLineID *uint        `gorm:"index" json:"purchase_order_line_id"` // Set on IN movements receiving an order line
+	CustomerID          *uint        `gorm:"index" json:"customer_id"`            // Destination of an OUT movement
+	SalesOrderLineID    *uint        `gorm:"index" json:"sales_order_line_id"`    // Set on OUT movements shipping an order line
+	ReservationID       *uint        `gorm:"index" json:"reservation_id"`         // Reservation an OUT movement fulfils
+	CreatedAt           time.Time    `gorm:"index" json:"created_at"`
 
 	// Relations
-	Product Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Product     Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Location    Location  `gorm:"foreignKey:LocationID" json:"-"`
+	EnteredUnit *Unit     `gorm:"foreignKey:EnteredUnitID" json:"-"`
+	ToLocation  *Location `gorm:"foreignKey:ToLocationID" json:"-"`
+	User        *User     `gorm:"foreignKey:UserID" json:"-"`
+	Customer    *Customer `gorm:"foreignKey:CustomerID" json:"-"`
 }
 
 // TableName specifies the table name for StockMovement model
@@ -33,5 +49,5 @@ func (StockMovement) TableName() string {
 
 // IsValid checks if the movement type is valid
 func (m MovementType) IsValid() bool {
-	return m == MovementTypeIn || m == MovementTypeOut
+	return m == MovementTypeIn || m == MovementTypeOut || m == MovementTypeTransfer || m == MovementTypeAdjustment
 }
@@ -10,15 +10,17 @@ type Product struct {
 	Code          string    `gorm:"size:50;uniqueIndex;not null" json:"code"`
 	Name          string    `gorm:"size:200;not null;index" json:"name"`
 	CategoryID    uint      `gorm:"not null;index" json:"category_id"`
-	Unit          string    `gorm:"size:20;not null" json:"unit"` // adet, kg, litre, etc.
-	CriticalLimit int       `gorm:"default:0" json:"critical_limit"`
-	Price         float64   `gorm:"type:decimal(10,2);default:0" json:"price"`
-	CurrentStock  int       `gorm:"default:0" json:"current_stock"` // Computed from movements
+	UnitID        *uint     `gorm:"index" json:"unit_id"`         // Base unit stock is kept in
+	Unit          string    `gorm:"size:20;not null" json:"unit"` // Name of the base unit: adet, kg, litre, etc.
+	CriticalLimit Quantity  `gorm:"default:0" json:"critical_limit"`
+	Price         Money     `gorm:"default:0" json:"price"`
+	CurrentStock  Quantity  `gorm:"default:0;index" json:"current_stock"` // Cached sum of movements, verified by ReconciliationService
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 
 	// Relations
 	Category  Category        `gorm:"foreignKey:CategoryID" json:"category"`
+	BaseUnit  *Unit           `gorm:"foreignKey:UnitID" json:"-"`
 	Movements []StockMovement `gorm:"foreignKey:ProductID" json:"-"`
 }
 
@@ -30,7 +32,7 @@ func (Product) TableName() string {
 // ProductWithStock is a computed view that includes current stock information
 type ProductWithStock struct {
 	Product
-	CurrentStock int     `json:"current_stock"`
-	StockValue   float64 `json:"stock_value"` // CurrentStock * Price
-	IsLowStock   bool    `json:"is_low_stock"`
+	CurrentStock Quantity `json:"current_stock"`
+	StockValue   Money    `json:"stock_value"` // CurrentStock * Price
+	IsLowStock   bool     `json:"is_low_stock"`
 }
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+)
+
+// PurchaseOrderStatus is the state of a purchase order
+type PurchaseOrderStatus string
+
+const (
+	PurchaseOrderDraft     PurchaseOrderStatus = "DRAFT"     // Being prepared, can be edited
+	PurchaseOrderSent      PurchaseOrderStatus = "SENT"      // Ordered, nothing received yet
+	PurchaseOrderPartial   PurchaseOrderStatus = "PARTIAL"   // Some lines still outstanding
+	PurchaseOrderReceived  PurchaseOrderStatus = "RECEIVED"  // Every line received in full
+	PurchaseOrderCancelled PurchaseOrderStatus = "CANCELLED" // Nothing more will be received
+)
+
+// IsValid checks if the status is valid
+func (s PurchaseOrderStatus) IsValid() bool {
+	switch s {
+	case PurchaseOrderDraft, PurchaseOrderSent, PurchaseOrderPartial, PurchaseOrderReceived, PurchaseOrderCancelled:
+		return true
+	}
+	return false
+}
+
+// IsOpen reports whether more goods are expected on an order in this state
+func (s PurchaseOrderStatus) IsOpen() bool {
+	return s == PurchaseOrderSent || s == PurchaseOrderPartial
+}
+
+// PurchaseOrder is an order placed with a supplier
+type PurchaseOrder struct {
+	ID           uint                `gorm:"primaryKey" json:"id"`
+	Number       string              `gorm:"size:20;not null;uniqueIndex" json:"number"` // PO-000001, assigned on create
+	SupplierID   uint                `gorm:"not null;index" json:"supplier_id"`
+	Status       PurchaseOrderStatus `gorm:"type:varchar(10);not null;index" json:"status"`
+	LocationID   uint                `gorm:"not null" json:"location_id"` // Where goods are received unless given otherwise
+	ExpectedDate *time.Time          `json:"expected_date"`
+	Note         string              `gorm:"type:text" json:"note"`
+	CreatedBy    *uint               `json:"created_by"`
+	SentAt       *time.Time          `json:"sent_at"`
+	ClosedAt     *time.Time          `json:"closed_at"` // When it was received in full or cancelled
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+
+	// Relations
+	Supplier Supplier            `gorm:"foreignKey:SupplierID" json:"-"`
+	Location Location            `gorm:"foreignKey:LocationID" json:"-"`
+	Lines    []PurchaseOrderLine `gorm:"foreignKey:OrderID" json:"-"`
+	Creator  *User               `gorm:"foreignKey:CreatedBy" json:"-"`
+}
+
+// TableName specifies the table name for PurchaseOrder model
+func (PurchaseOrder) TableName() string {
+	return "purchase_orders"
+}
+
+// PurchaseOrderLine is one product of a purchase order. Received may exceed
+// Quantity when the supplier delivers more than ordered.
+type PurchaseOrderLine struct {
+	ID        uint     `gorm:"primaryKey" json:"id"`
+	OrderID   uint     `gorm:"not null;index" json:"order_id"`
+	ProductID uint     `gorm:"not null;index" json:"product_id"`
+	Quantity  Quantity `gorm:"not null" json:"quantity"`           // Ordered, in the product's base unit
+	Received  Quantity `gorm:"not null;default:0" json:"received"` // Sum of the IN movements of the line
+	UnitPrice Money    `gorm:"not null;default:0" json:"unit_price"`
+	Note      string   `gorm:"size:500" json:"note"`
+
+	// Relations
+	Order   PurchaseOrder `gorm:"foreignKey:OrderID" json:"-"`
+	Product Product       `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+// TableName specifies the table name for PurchaseOrderLine model
+func (PurchaseOrderLine) TableName() string {
+	return "purchase_order_lines"
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+)
+
+// ReservationStatus is the state of a stock reservation
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "ACTIVE"    // Holds stock until fulfilled, released or expired
+	ReservationFulfilled ReservationStatus = "FULFILLED" // Shipped in full by OUT movements
+	ReservationReleased  ReservationStatus = "RELEASED"  // Given up before it was fulfilled
+	ReservationExpired   ReservationStatus = "EXPIRED"   // Released when its expiry passed
+)
+
+// IsValid checks if the status is valid
+func (s ReservationStatus) IsValid() bool {
+	switch s {
+	case ReservationActive, ReservationFulfilled, ReservationReleased, ReservationExpired:
+		return true
+	}
+	return false
+}
+
+// StockReservation holds stock of a product for a purpose so other OUT
+// movements cannot take it. What it still holds is Quantity - Fulfilled.
+type StockReservation struct {
+	ID               uint              `gorm:"primaryKey" json:"id"`
+	ProductID        uint              `gorm:"not null;index" json:"product_id"`
+	Quantity         Quantity          `gorm:"not null" json:"quantity"`            // Reserved, in the product's base unit
+	Fulfilled        Quantity          `gorm:"not null;default:0" json:"fulfilled"` // Sum of the OUT movements against it
+	Status           ReservationStatus `gorm:"type:varchar(10);not null;index" json:"status"`
+	Reason           string            `gorm:"size:200" json:"reason"`
+	Reference        string            `gorm:"size:50" json:"reference"`         // Order or document number
+	ExpiresAt        *time.Time        `gorm:"index" json:"expires_at"`          // Nil holds the stock until released
+	SalesOrderLineID *uint             `gorm:"index" json:"sales_order_line_id"` // Set when confirming a sales order reserved it
+	CreatedBy        *uint             `json:"created_by"`
+	ReleasedAt       *time.Time        `json:"released_at"` // When it was fulfilled, released or expired
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+
+	// Relations
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+	Creator *User   `gorm:"foreignKey:CreatedBy" json:"-"`
+}
+
+// TableName specifies the table name for StockReservation model
+func (StockReservation) TableName() string {
+	return "stock_reservations"
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+)
+
+// SalesOrderStatus is the state of a sales order
+type SalesOrderStatus string
+
+const (
+	SalesOrderDraft     SalesOrderStatus = "DRAFT"     // Being prepared, can be edited
+	SalesOrderConfirmed SalesOrderStatus = "CONFIRMED" // Stock reserved, nothing shipped yet
+	SalesOrderPartial   SalesOrderStatus = "PARTIAL"   // Some lines still to ship
+	SalesOrderShipped   SalesOrderStatus = "SHIPPED"   // Every line shipped in full
+	SalesOrderCancelled SalesOrderStatus = "CANCELLED" // Nothing more will be shipped
+)
+
+// IsValid checks if the status is valid
+func (s SalesOrderStatus) IsValid() bool {
+	switch s {
+	case SalesOrderDraft, SalesOrderConfirmed, SalesOrderPartial, SalesOrderShipped, SalesOrderCancelled:
+		return true
+	}
+	return false
+}
+
+// IsOpen reports whether an order in this state holds reserved stock
+func (s SalesOrderStatus) IsOpen() bool {
+	return s == SalesOrderConfirmed || s == SalesOrderPartial
+}
+
+// SalesOrder is an order to issue stock to a customer, cost center or project
+type SalesOrder struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	Number      string           `gorm:"size:20;not null;uniqueIndex" json:"number"` // SO-000001, assigned on create
+	CustomerID  uint             `gorm:"not null;index" json:"customer_id"`
+	Status      SalesOrderStatus `gorm:"type:varchar(10);not null;index" json:"status"`
+	LocationID  uint             `gorm:"not null" json:"location_id"` // Where goods ship from unless given otherwise
+	DueDate     *time.Time       `json:"due_date"`
+	Note        string           `gorm:"type:text" json:"note"`
+	CreatedBy   *uint            `json:"created_by"`
+	ConfirmedAt *time.Time       `json:"confirmed_at"`
+	ClosedAt    *time.Time       `json:"closed_at"` // When it was shipped in full or cancelled
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+
+	// Relations
+	Customer Customer         `gorm:"foreignKey:CustomerID" json:"-"`
+	Location Location         `gorm:"foreignKey:LocationID" json:"-"`
+	Lines    []SalesOrderLine `gorm:"foreignKey:OrderID" json:"-"`
+	Creator  *User            `gorm:"foreignKey:CreatedBy" json:"-"`
+}
+
+// TableName specifies the table name for SalesOrder model
+func (SalesOrder) TableName() string {
+	return "sales_orders"
+}
+
+// SalesOrderLine is one product of a sales order. Shipped never exceeds Quantity.
+type SalesOrderLine struct {
+	ID        uint     `gorm:"primaryKey" json:"id"`
+	OrderID   uint     `gorm:"not null;index" json:"order_id"`
+	ProductID uint     `gorm:"not null;index" json:"product_id"`
+	Quantity  Quantity `gorm:"not null" json:"quantity"`          // Ordered, in the product's base unit
+	Shipped   Quantity `gorm:"not null;default:0" json:"shipped"` // Sum of the OUT movements of the line
+	UnitPrice Money    `gorm:"not null;default:0" json:"unit_price"`
+	Note      string   `gorm:"size:500" json:"note"`
+
+	// Relations
+	Order   SalesOrder `gorm:"foreignKey:OrderID" json:"-"`
+	Product Product    `gorm:"foreignKey:ProductID" json:"-"`
+}
+
+// TableName specifies the table name for SalesOrderLine model
+func (SalesOrderLine) TableName() string {
+	return "sales_order_lines"
+}
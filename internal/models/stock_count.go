@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+)
+
+// StockCountStatus is the state of a stock count session
+type StockCountStatus string
+
+const (
+	StockCountOpen      StockCountStatus = "OPEN"      // Counting in progress
+	StockCountPosted    StockCountStatus = "POSTED"    // Differences booked as ADJUSTMENT movements
+	StockCountCancelled StockCountStatus = "CANCELLED" // Closed without changing stock
+)
+
+// IsValid checks if the status is valid
+func (s StockCountStatus) IsValid() bool {
+	return s == StockCountOpen || s == StockCountPosted || s == StockCountCancelled
+}
+
+// StockCount is a physical count session at one location. Opening it
+// snapshots the stock there of every product in scope; posting it books the
+// differences at LocationID.
+type StockCount struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	Name       string           `gorm:"size:100;not null" json:"name"`
+	Note       string           `gorm:"type:text" json:"note"`
+	Status     StockCountStatus `gorm:"type:varchar(10);not null;index" json:"status"`
+	CategoryID *uint            `gorm:"index" json:"category_id"` // Only this category's products; nil counts all
+	LocationID uint             `gorm:"not null" json:"location_id"`
+	OpenedBy   *uint            `json:"opened_by"`
+	ClosedBy   *uint            `json:"closed_by"` // Who posted or cancelled the count
+	CreatedAt  time.Time        `json:"created_at"`
+	ClosedAt   *time.Time       `json:"closed_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+
+	// Relations
+	Lines    []StockCountLine `gorm:"foreignKey:CountID" json:"-"`
+	Category *Category        `gorm:"foreignKey:CategoryID" json:"-"`
+	Location Location         `gorm:"foreignKey:LocationID" json:"-"`
+	Opener   *User            `gorm:"foreignKey:OpenedBy" json:"-"`
+	Closer   *User            `gorm:"foreignKey:ClosedBy" json:"-"`
+}
+
+// TableName specifies the table name for StockCount model
+func (StockCount) TableName() string {
+	return "stock_counts"
+}
+
+// StockCountLine is one product of a count with the stock and price when the
+// count was opened and the quantity counted
+type StockCountLine struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CountID    uint       `gorm:"not null;uniqueIndex:idx_stock_count_lines_count_product" json:"count_id"`
+	ProductID  uint       `gorm:"not null;uniqueIndex:idx_stock_count_lines_count_product;index" json:"product_id"`
+	Expected   Quantity   `gorm:"not null" json:"expected"` // Stock at the count's location when it was opened
+	Price      Money      `gorm:"not null" json:"price"`    // Price when the count was opened
+	Counted    *Quantity  `json:"counted"`                  // nil until counted
+	CountedBy  *uint      `json:"counted_by"`
+	CountedAt  *time.Time `json:"counted_at"`
+	MovementID *uint      `json:"movement_id"` // ADJUSTMENT booked when the count was posted
+
+	// Relations
+	Product Product `gorm:"foreignKey:ProductID" json:"-"`
+	Counter *User   `gorm:"foreignKey:CountedBy" json:"-"`
+}
+
+// TableName specifies the table name for StockCountLine model
+func (StockCountLine) TableName() string {
+	return "stock_count_lines"
+}
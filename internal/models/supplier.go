@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// Supplier is a company stock is bought from
+type Supplier struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Name            string    `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	TaxNumber       string    `gorm:"size:20" json:"tax_number"`
+	Contact         string    `gorm:"size:100" json:"contact"`                     // Contact person
+	PaymentTermDays int       `gorm:"not null;default:0" json:"payment_term_days"` // Days to pay an invoice, 0 for cash
+	Phone           string    `gorm:"size:50" json:"phone"`
+	Email           string    `gorm:"size:100" json:"email"`
+	Note            string    `gorm:"type:text" json:"note"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+
+	// Relations
+	PurchaseOrders []PurchaseOrder   `gorm:"foreignKey:SupplierID" json:"-"`
+	Products       []ProductSupplier `gorm:"foreignKey:SupplierID" json:"-"`
+}
+
+// TableName specifies the table name for Supplier model
+func (Supplier) TableName() string {
+	return "suppliers"
+}
+
+// ProductSupplier holds the terms a supplier sells a product on
+type ProductSupplier struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ProductID        uint      `gorm:"not null;uniqueIndex:idx_product_suppliers_product_supplier" json:"product_id"`
+	SupplierID       uint      `gorm:"not null;uniqueIndex:idx_product_suppliers_product_supplier;index" json:"supplier_id"`
+	SupplierCode     string    `gorm:"size:50" json:"supplier_code"` // The supplier's item code
+	LastPrice        Money     `gorm:"not null;default:0" json:"last_price"`
+	LeadTimeDays     int       `gorm:"not null;default:0" json:"lead_time_days"`
+	MinOrderQuantity Quantity  `gorm:"not null;default:0" json:"min_order_quantity"` // In the product's base unit
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+
+	// Relations
+	Product  Product  `gorm:"foreignKey:ProductID" json:"-"`
+	Supplier Supplier `gorm:"foreignKey:SupplierID" json:"-"`
+}
+
+// TableName specifies the table name for ProductSupplier model
+func (ProductSupplier) TableName() string {
+	return "product_suppliers"
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Unit is a unit of measure and the number of decimals its quantities may use
+type Unit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"size:20;not null;uniqueIndex" json:"name"` // Matched case-insensitively
+	Decimals  int       `gorm:"not null;default:0" json:"decimals"`       // 0 to QuantityDecimals
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Unit model
+func (Unit) TableName() string {
+	return "units"
+}
+
+// UnitConversion says how many ToUnit make one FromUnit, e.g. 1 kutu = 50 adet.
+// A conversion without a product applies to every product (kg -> g).
+type UnitConversion struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ProductID  *uint     `gorm:"index" json:"product_id"`
+	FromUnitID uint      `gorm:"not null;index" json:"from_unit_id"`
+	ToUnitID   uint      `gorm:"not null;index" json:"to_unit_id"`
+	Factor     Quantity  `gorm:"not null" json:"factor"` // ToUnit per one FromUnit
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	// Relations
+	FromUnit Unit `gorm:"foreignKey:FromUnitID" json:"-"`
+	ToUnit   Unit `gorm:"foreignKey:ToUnitID" json:"-"`
+}
+
+// TableName specifies the table name for UnitConversion model
+func (UnitConversion) TableName() string {
+	return "unit_conversions"
+}
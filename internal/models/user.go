@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// Role decides what a user may do
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"  // Everything, including deletes and user management
+	RoleClerk  Role = "clerk"  // Create and update records
+	RoleViewer Role = "viewer" // Read only
+)
+
+// roleRanks orders roles from least to most privileged
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleClerk:  2,
+	RoleAdmin:  3,
+}
+
+// IsValid checks if the role is valid
+func (r Role) IsValid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Includes reports whether r grants everything other grants
+func (r Role) Includes(other Role) bool {
+	return r.IsValid() && roleRanks[r] >= roleRanks[other]
+}
+
+// User is an account stored in each database
+type User struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Username     string    `gorm:"size:50;not null;uniqueIndex" json:"username"`
+	PasswordHash string    `gorm:"size:100;not null" json:"-"` // bcrypt, includes the salt
+	Role         Role      `gorm:"type:varchar(10);not null" json:"role"`
+	Active       bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for User model
+func (User) TableName() string {
+	return "users"
+}
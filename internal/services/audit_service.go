@@ -0,0 +1,192 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/user"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audited entity names
+const (
+	auditEntityProduct         = "product"
+	auditEntityCategory        = "category"
+	auditEntityMovement        = "movement"
+	auditEntityBarcode         = "barcode"
+	auditEntityStockCount      = "stock_count"
+	auditEntitySupplier        = "supplier"
+	auditEntityPurchaseOrder   = "purchase_order"
+	auditEntityProductSupplier = "product_supplier"
+	auditEntityCustomer        = "customer"
+	auditEntitySalesOrder      = "sales_order"
+	auditEntityReservation     = "reservation"
+)
+
+// AuditEntryDTO is the data transfer object for audit log entries
+type AuditEntryDTO struct {
+	ID        uint            `json:"id"`
+	Entity    string          `json:"entity"`
+	EntityID  uint            `json:"entity_id"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before"` // null on CREATE
+	After     json.RawMessage `json:"after"`  // null on DELETE
+	Actor     string          `json:"actor"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditQuery filters the audit log. Zero values match everything.
+type AuditQuery struct {
+	Entity   string    `json:"entity"`
+	EntityID uint      `json:"entity_id"`
+	Action   string    `json:"action"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Limit    int       `json:"limit"` // Defaults to 500
+}
+
+// AuditService records and queries the audit log
+type AuditService struct {
+	dbManager *database.ConnectionManager
+
+	mu    sync.RWMutex
+	actor func() string
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(dbManager *database.ConnectionManager) *AuditService {
+	return &AuditService{
+		dbManager: dbManager,
+		actor:     systemActor,
+	}
+}
+
+// systemActor names the operating system user, used when nobody is logged in
+func systemActor() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "system"
+}
+
+// SetActor sets the function that names the user making changes
+func (s *AuditService) SetActor(actor func() string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actor = actor
+}
+
+// currentActor returns the name of the user making changes
+func (s *AuditService) currentActor() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.actor()
+}
+
+// record writes an audit entry inside tx, so it commits or rolls back with the
+// change it describes. before and after are marshalled to JSON; pass nil for
+// the side that does not exist.
+func (s *AuditService) record(tx *gorm.DB, entity string, entityID uint, action models.AuditAction, before, after interface{}) error {
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	entry := &models.AuditEntry{
+		Entity:    entity,
+		EntityID:  entityID,
+		Action:    action,
+		Before:    beforeJSON,
+		After:     afterJSON,
+		Actor:     s.currentActor(),
+		CreatedAt: time.Now(),
+	}
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	return nil
+}
+
+// snapshot marshals v to JSON, or returns "" for nil
+func snapshot(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot for audit log: %w", err)
+	}
+	return string(data), nil
+}
+
+// Query returns audit entries matching q, newest first
+func (s *AuditService) Query(q AuditQuery) ([]AuditEntryDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	query := db.Model(&models.AuditEntry{})
+	if q.Entity != "" {
+		query = query.Where("entity = ?", q.Entity)
+	}
+	if q.EntityID != 0 {
+		query = query.Where("entity_id = ?", q.EntityID)
+	}
+	if q.Action != "" {
+		if !models.AuditAction(q.Action).IsValid() {
+			return nil, fmt.Errorf("invalid audit action: %s", q.Action)
+		}
+		query = query.Where("action = ?", q.Action)
+	}
+	// Compare as instants: stored times carry their own offset
+	if !q.From.IsZero() {
+		query = query.Where("julianday(created_at) >= julianday(?)", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("julianday(created_at) <= julianday(?)", q.To)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 500
+	}
+
+	var entries []models.AuditEntry
+	if err := query.Order("id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch audit log: %w", err)
+	}
+
+	dtos := make([]AuditEntryDTO, len(entries))
+	for i, entry := range entries {
+		dtos[i] = AuditEntryDTO{
+			ID:        entry.ID,
+			Entity:    entry.Entity,
+			EntityID:  entry.EntityID,
+			Action:    string(entry.Action),
+			Before:    rawJSON(entry.Before),
+			After:     rawJSON(entry.After),
+			Actor:     entry.Actor,
+			CreatedAt: entry.CreatedAt,
+		}
+	}
+
+	return dtos, nil
+}
+
+// rawJSON passes a stored snapshot through unchanged, or null when empty
+func rawJSON(s string) json.RawMessage {
+	if s == "" {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(s)
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	auditEntityUser = "user"
+
+	minPasswordLength = 6
+
+	// dummyPasswordHash is checked against when no user has the name given
+	// at login, so an unknown name takes as long as a wrong password
+	dummyPasswordHash = "$2a$10$m3yabBq7l56M2C0xapndfu/WmOeSuKinZ5IpA1K5Eh2GEs/YU1U.."
+)
+
+var (
+	// ErrNotAuthenticated is returned when nobody is logged in
+	ErrNotAuthenticated = errors.New("not logged in")
+	// ErrPermissionDenied is returned when the current user's role is too low
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// UserDTO is the data transfer object for users
+type UserDTO struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Active    bool      `json:"active"`
+	Password  string    `json:"password,omitempty"` // Input only: sets or resets the password
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AuthService handles users, login and permission checks.
+// Users live in each database, so switching databases logs the user out.
+type AuthService struct {
+	dbManager *database.ConnectionManager
+	audit     *AuditService
+
+	mu      sync.RWMutex
+	current *models.User
+}
+
+// NewAuthService creates a new auth service
+func NewAuthService(dbManager *database.ConnectionManager, audit *AuditService) *AuthService {
+	return &AuthService{
+		dbManager: dbManager,
+		audit:     audit,
+	}
+}
+
+// Helper function to convert model to DTO
+func (s *AuthService) toDTO(user *models.User) UserDTO {
+	return UserDTO{
+		ID:        user.ID,
+		Username:  user.Username,
+		Role:      string(user.Role),
+		Active:    user.Active,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+// OnConnect ends the session when another database is opened
+func (s *AuthService) OnConnect(db *gorm.DB) {
+	s.Logout()
+}
+
+// NeedsSetup reports whether the database has no users yet
+func (s *AuthService) NeedsSetup() (bool, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return false, fmt.Errorf("no database connection")
+	}
+
+	var count int64
+	if err := db.Model(&models.User{}).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	return count == 0, nil
+}
+
+// SetupAdmin creates the first admin of a database without users and logs in
+func (s *AuthService) SetupAdmin(username, password string) (*UserDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	user := &models.User{Username: strings.TrimSpace(username), Role: models.RoleAdmin, Active: true}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count users: %w", err)
+		}
+		if count > 0 {
+			return fmt.Errorf("database already has users, log in instead")
+		}
+
+		return s.createUser(tx, user, password)
+	}); err != nil {
+		return nil, err
+	}
+
+	s.setCurrent(user)
+	dto := s.toDTO(user)
+	return &dto, nil
+}
+
+// Login checks the credentials and starts a session
+func (s *AuthService) Login(username, password string) (*UserDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var user models.User
+	if err := db.Where("username = ?", strings.TrimSpace(username)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+			return nil, fmt.Errorf("invalid username or password")
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, fmt.Errorf("invalid username or password")
+	}
+	if !user.Active {
+		return nil, fmt.Errorf("user '%s' is disabled", user.Username)
+	}
+
+	s.setCurrent(&user)
+	dto := s.toDTO(&user)
+	return &dto, nil
+}
+
+// Logout ends the current session
+func (s *AuthService) Logout() {
+	s.setCurrent(nil)
+}
+
+// CurrentUser returns the logged in user, or nil
+func (s *AuthService) CurrentUser() *UserDTO {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.current == nil {
+		return nil
+	}
+	dto := s.toDTO(s.current)
+	return &dto
+}
+
+// CurrentUserID returns the ID of the logged in user, or nil
+func (s *AuthService) CurrentUserID() *uint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.current == nil {
+		return nil
+	}
+	id := s.current.ID
+	return &id
+}
+
+// ActorName names the logged in user for the audit log, falling back to the
+// operating system user
+func (s *AuthService) ActorName() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.current == nil {
+		return systemActor()
+	}
+	return s.current.Username
+}
+
+// Require returns an error unless the logged in user has at least role
+func (s *AuthService) Require(role models.Role) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.current == nil {
+		return ErrNotAuthenticated
+	}
+	if !s.current.Role.Includes(role) {
+		return fmt.Errorf("%w: requires %s role", ErrPermissionDenied, role)
+	}
+	return nil
+}
+
+// setCurrent replaces the session user
+func (s *AuthService) setCurrent(user *models.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user == nil {
+		s.current = nil
+		return
+	}
+	copied := *user
+	s.current = &copied
+}
+
+// GetAllUsers returns all users
+func (s *AuthService) GetAllUsers() ([]UserDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var users []models.User
+	if err := db.Order("username ASC").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
+	}
+
+	dtos := make([]UserDTO, len(users))
+	for i, user := range users {
+		dtos[i] = s.toDTO(&user)
+	}
+
+	return dtos, nil
+}
+
+// CreateUser creates a new user from DTO
+func (s *AuthService) CreateUser(dto UserDTO) (*UserDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	user := &models.User{
+		Username: strings.TrimSpace(dto.Username),
+		Role:     models.Role(dto.Role),
+		Active:   true,
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return s.createUser(tx, user, dto.Password)
+	}); err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(user)
+	return &resultDTO, nil
+}
+
+// createUser validates, hashes the password and stores user inside tx
+func (s *AuthService) createUser(tx *gorm.DB, user *models.User, password string) error {
+	if err := s.validate(tx, 0, user.Username, user.Role); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+
+	if err := tx.Create(user).Error; err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return s.audit.record(tx, auditEntityUser, user.ID, models.AuditActionCreate, nil, s.toDTO(user))
+}
+
+// UpdateUser updates a user's name, role, active flag and, when given, password
+func (s *AuthService) UpdateUser(id uint, dto UserDTO) (*UserDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var user models.User
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, id).Error; err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
+		before := s.toDTO(&user)
+
+		username := strings.TrimSpace(dto.Username)
+		role := models.Role(dto.Role)
+		if err := s.validate(tx, id, username, role); err != nil {
+			return err
+		}
+
+		// The database must always keep an active admin
+		if user.Role == models.RoleAdmin && user.Active && (role != models.RoleAdmin || !dto.Active) {
+			if err := s.checkNotLastAdmin(tx, id); err != nil {
+				return err
+			}
+		}
+
+		user.Username = username
+		user.Role = role
+		user.Active = dto.Active
+		if dto.Password != "" {
+			hash, err := hashPassword(dto.Password)
+			if err != nil {
+				return err
+			}
+			user.PasswordHash = hash
+		}
+
+		if err := tx.Save(&user).Error; err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntityUser, user.ID, models.AuditActionUpdate, before, s.toDTO(&user))
+	}); err != nil {
+		return nil, err
+	}
+
+	s.refreshCurrent(&user)
+	resultDTO := s.toDTO(&user)
+	return &resultDTO, nil
+}
+
+// DeleteUser deletes a user by ID. Movements keep the ID of their author.
+func (s *AuthService) DeleteUser(id uint) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	if current := s.CurrentUserID(); current != nil && *current == id {
+		return conflictf("cannot delete the logged in user")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
+
+		if user.Role == models.RoleAdmin && user.Active {
+			if err := s.checkNotLastAdmin(tx, id); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(&models.User{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntityUser, user.ID, models.AuditActionDelete, s.toDTO(&user), nil)
+	})
+}
+
+// ChangePassword changes the logged in user's password
+func (s *AuthService) ChangePassword(oldPassword, newPassword string) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	id := s.CurrentUserID()
+	if id == nil {
+		return ErrNotAuthenticated
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, *id).Error; err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword)) != nil {
+			return fmt.Errorf("current password is wrong")
+		}
+
+		hash, err := hashPassword(newPassword)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&user).Update("password_hash", hash).Error; err != nil {
+			return fmt.Errorf("failed to change password: %w", err)
+		}
+
+		// Snapshots never contain the hash; the entry records that it changed
+		return s.audit.record(tx, auditEntityUser, user.ID, models.AuditActionUpdate, s.toDTO(&user), s.toDTO(&user))
+	})
+}
+
+// validate checks the username and role of a user
+func (s *AuthService) validate(tx *gorm.DB, id uint, username string, role models.Role) error {
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if !role.IsValid() {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+
+	var existing models.User
+	if err := tx.Where("username = ? AND id != ?", username, id).First(&existing).Error; err == nil {
+		return conflictf("user '%s' already exists", username)
+	}
+
+	return nil
+}
+
+// checkNotLastAdmin refuses to demote, disable or delete the last active admin
+func (s *AuthService) checkNotLastAdmin(tx *gorm.DB, id uint) error {
+	var admins int64
+	if err := tx.Model(&models.User{}).
+		Where("role = ? AND active = ? AND id != ?", models.RoleAdmin, true, id).
+		Count(&admins).Error; err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if admins == 0 {
+		return fmt.Errorf("the last active admin cannot be removed")
+	}
+	return nil
+}
+
+// refreshCurrent updates the session after the logged in user was changed
+func (s *AuthService) refreshCurrent(user *models.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == nil || s.current.ID != user.ID {
+		return
+	}
+	if !user.Active {
+		s.current = nil
+		return
+	}
+	copied := *user
+	s.current = &copied
+}
+
+// hashPassword returns a salted bcrypt hash of password
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
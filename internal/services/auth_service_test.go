@@ -0,0 +1,41 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"stoktakip/internal/database"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginUnknownUser(t *testing.T) {
+	// The dummy comparison only hides unknown names if it costs as much as a real one
+	if cost, err := bcrypt.Cost([]byte(dummyPasswordHash)); err != nil || cost != bcrypt.DefaultCost {
+		t.Fatalf("dummy hash cost = %d, %v, want %d", cost, err, bcrypt.DefaultCost)
+	}
+
+	dbManager := database.GetConnectionManager()
+	if err := dbManager.Connect(filepath.Join(t.TempDir(), "auth.db")); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer dbManager.Close()
+
+	authService := NewAuthService(dbManager, NewAuditService(dbManager))
+	if _, err := authService.SetupAdmin("admin", "secret1"); err != nil {
+		t.Fatalf("setup admin: %v", err)
+	}
+	authService.Logout()
+
+	_, wrongPassword := authService.Login("admin", "secret2")
+	_, unknownUser := authService.Login("nobody", "secret1")
+	if wrongPassword == nil || unknownUser == nil {
+		t.Fatalf("login errors = %v, %v, want both refused", wrongPassword, unknownUser)
+	}
+	if wrongPassword.Error() != unknownUser.Error() {
+		t.Errorf("unknown user error %q differs from wrong password error %q", unknownUser, wrongPassword)
+	}
+	if authService.CurrentUser() != nil {
+		t.Errorf("refused login started a session")
+	}
+}
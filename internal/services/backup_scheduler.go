@@ -0,0 +1,319 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"stoktakip/internal/config"
+	"stoktakip/internal/database"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BackupStatus tells the UI how automatic backups are doing
+type BackupStatus struct {
+	Enabled        bool       `json:"enabled"`
+	LastBackupAt   *time.Time `json:"last_backup_at"` // Newest backup of the current database
+	LastBackupPath string     `json:"last_backup_path"`
+	LastError      string     `json:"last_error"` // Error of the last failed attempt, cleared by a success
+	LastErrorAt    *time.Time `json:"last_error_at"`
+	NextBackupAt   *time.Time `json:"next_backup_at"` // Nil when no timed backup is due
+}
+
+// BackupScheduler backs up the active database on an interval and prunes
+// old backups by the retention settings
+type BackupScheduler struct {
+	databaseService *DatabaseService
+	dbManager       *database.ConnectionManager
+
+	mu          sync.Mutex
+	settings    config.BackupConfig
+	lastError   string
+	lastErrorAt time.Time
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// retryInterval is how long to wait after a failed automatic backup
+const retryInterval = 15 * time.Minute
+
+// NewBackupScheduler creates a new backup scheduler
+func NewBackupScheduler(databaseService *DatabaseService, dbManager *database.ConnectionManager, settings config.BackupConfig) *BackupScheduler {
+	return &BackupScheduler{
+		databaseService: databaseService,
+		dbManager:       dbManager,
+		settings:        settings,
+		wake:            make(chan struct{}, 1),
+	}
+}
+
+// Start runs the scheduler in the background until Stop
+func (s *BackupScheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+}
+
+// Stop ends the scheduler and waits for a running backup to finish
+func (s *BackupScheduler) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Configure replaces the settings and reschedules
+func (s *BackupScheduler) Configure(settings config.BackupConfig) {
+	s.mu.Lock()
+	s.settings = settings
+	s.mu.Unlock()
+	s.poke()
+}
+
+// OnConnect reschedules for the newly opened database
+func (s *BackupScheduler) OnConnect(db *gorm.DB) {
+	s.poke()
+}
+
+// poke wakes the scheduler loop without blocking
+func (s *BackupScheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns the last and next backup of the current database
+func (s *BackupScheduler) Status() BackupStatus {
+	s.mu.Lock()
+	settings := s.settings
+	status := BackupStatus{Enabled: settings.Enabled, LastError: s.lastError}
+	if !s.lastErrorAt.IsZero() {
+		lastErrorAt := s.lastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+	s.mu.Unlock()
+
+	if backups, err := s.databaseService.ListBackups(); err == nil && len(backups) > 0 {
+		status.LastBackupAt = &backups[0].CreatedAt
+		status.LastBackupPath = backups[0].Path
+	}
+
+	if next, ok := s.nextBackup(); ok {
+		status.NextBackupAt = &next
+	}
+
+	return status
+}
+
+// BackupNow backs up the current database and prunes old timed backups.
+// The backup itself is never pruned.
+func (s *BackupScheduler) BackupNow() (string, error) {
+	return s.backup(false)
+}
+
+// backup writes a backup, records the outcome and prunes old timed backups
+func (s *BackupScheduler) backup(automatic bool) (string, error) {
+	path, err := s.databaseService.backup(automatic)
+
+	s.mu.Lock()
+	if err != nil {
+		s.lastError = err.Error()
+		s.lastErrorAt = time.Now()
+	} else {
+		s.lastError = ""
+		s.lastErrorAt = time.Time{}
+	}
+	settings := s.settings
+	s.mu.Unlock()
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.prune(settings); err != nil {
+		log.Printf("Warning: failed to prune old backups: %v", err)
+	}
+
+	return path, nil
+}
+
+// nextBackup returns when the next timed backup of the current database is due
+func (s *BackupScheduler) nextBackup() (time.Time, bool) {
+	s.mu.Lock()
+	settings, lastErrorAt := s.settings, s.lastErrorAt
+	s.mu.Unlock()
+
+	if !settings.Enabled || settings.IntervalHours == 0 || !s.dbManager.IsConnected() {
+		return time.Time{}, false
+	}
+
+	backups, err := s.databaseService.ListBackups()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// A database that was never backed up is due now
+	next := time.Now()
+	if len(backups) > 0 {
+		next = backups[0].CreatedAt.Add(time.Duration(settings.IntervalHours) * time.Hour)
+	}
+
+	// Do not retry a failing backup in a tight loop
+	if !lastErrorAt.IsZero() && next.Before(lastErrorAt.Add(retryInterval)) {
+		next = lastErrorAt.Add(retryInterval)
+	}
+
+	return next, true
+}
+
+// run is the scheduler loop
+func (s *BackupScheduler) run(stop, done chan struct{}) {
+	defer close(done)
+
+	for {
+		// Without a due backup, wait for new settings or another database
+		wait := time.Duration(-1)
+		if next, ok := s.nextBackup(); ok {
+			wait = time.Until(next)
+			if wait < 0 {
+				wait = 0
+			}
+		}
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if wait >= 0 {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+
+		select {
+		case <-stop:
+		case <-s.wake:
+		case <-due:
+			if path, err := s.backup(true); err != nil {
+				log.Printf("Warning: automatic backup failed: %v", err)
+			} else {
+				log.Printf("Automatic backup written: %s", path)
+			}
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+
+		select {
+		case <-stop:
+			return
+		default:
+		}
+	}
+}
+
+// prune deletes the current database's timed backups that the retention
+// settings do not keep. Other backups are never deleted.
+func (s *BackupScheduler) prune(settings config.BackupConfig) error {
+	backups, err := s.databaseService.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	for _, backup := range backupsToPrune(backups, settings, time.Now()) {
+		if err := os.Remove(backup.Path); err != nil {
+			return fmt.Errorf("failed to delete backup %s: %w", backup.Name, err)
+		}
+		log.Printf("Pruned old backup: %s", backup.Name)
+	}
+
+	return nil
+}
+
+// backupsToPrune returns the timed backups outside the retention settings.
+// The newest timed backup in each of the last KeepDaily calendar days,
+// KeepWeekly ISO weeks and KeepMonthly months up to now is kept, and so is
+// the newest timed backup overall; all zero settings keep everything.
+// backups are newest first.
+func backupsToPrune(backups []BackupInfo, settings config.BackupConfig, now time.Time) []BackupInfo {
+	if settings.KeepDaily == 0 && settings.KeepWeekly == 0 && settings.KeepMonthly == 0 {
+		return nil
+	}
+
+	var automatic []BackupInfo
+	for _, backup := range backups {
+		if backup.Automatic {
+			automatic = append(automatic, backup)
+		}
+	}
+	if len(automatic) == 0 {
+		return nil
+	}
+
+	keep := map[string]bool{automatic[0].Path: true}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	periods := []struct {
+		count int
+		key   func(t time.Time) string
+		back  func(i int) time.Time // A time in the i-th period before now
+	}{
+		{
+			count: settings.KeepDaily,
+			key:   func(t time.Time) string { return t.Format("2006-01-02") },
+			back:  func(i int) time.Time { return today.AddDate(0, 0, -i) },
+		},
+		{
+			count: settings.KeepWeekly,
+			key: func(t time.Time) string {
+				year, week := t.ISOWeek()
+				return fmt.Sprintf("%d-W%02d", year, week)
+			},
+			back: func(i int) time.Time { return today.AddDate(0, 0, -7*i) },
+		},
+		{
+			count: settings.KeepMonthly,
+			key:   func(t time.Time) string { return t.Format("2006-01") },
+			back: func(i int) time.Time {
+				return time.Date(today.Year(), today.Month()-time.Month(i), 1, 0, 0, 0, 0, today.Location())
+			},
+		},
+	}
+
+	for _, period := range periods {
+		// Periods without a backup still count
+		kept := make(map[string]bool, period.count)
+		for i := 0; i < period.count; i++ {
+			kept[period.key(period.back(i))] = false
+		}
+		for _, backup := range automatic {
+			key := period.key(backup.CreatedAt.In(now.Location()))
+			if done, ok := kept[key]; ok && !done {
+				kept[key] = true
+				keep[backup.Path] = true
+			}
+		}
+	}
+
+	var prune []BackupInfo
+	for _, backup := range automatic {
+		if !keep[backup.Path] {
+			prune = append(prune, backup)
+		}
+	}
+	return prune
+}
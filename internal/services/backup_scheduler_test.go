@@ -0,0 +1,133 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"stoktakip/internal/config"
+)
+
+// backupAt describes a backup for the retention tests; names ending in
+// "manual" are not timed backups
+func backupAt(name string) BackupInfo {
+	createdAt, err := time.ParseInLocation("2006-01-02 15:04", strings.TrimSuffix(name, " manual"), time.UTC)
+	if err != nil {
+		panic(err)
+	}
+	return BackupInfo{Name: name, Path: name, CreatedAt: createdAt, Automatic: !strings.HasSuffix(name, " manual")}
+}
+
+func TestBackupsToPrune(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      string
+		settings config.BackupConfig
+		backups  []string // Newest first
+		want     []string // Pruned
+	}{
+		{
+			name:     "days without a backup count",
+			now:      "2026-03-04 12:00",
+			settings: config.BackupConfig{KeepDaily: 3},
+			backups:  []string{"2026-03-04 10:00", "2026-03-04 08:00", "2026-03-02 09:00", "2026-03-01 09:00"},
+			want:     []string{"2026-03-04 08:00", "2026-03-01 09:00"},
+		},
+		{
+			name:     "daily across midnight",
+			now:      "2026-03-04 00:30",
+			settings: config.BackupConfig{KeepDaily: 2},
+			backups:  []string{"2026-03-04 00:10", "2026-03-03 23:50", "2026-03-03 12:00", "2026-03-02 23:59"},
+			want:     []string{"2026-03-03 12:00", "2026-03-02 23:59"},
+		},
+		{
+			name:     "ISO weeks start on Monday",
+			now:      "2026-03-04 12:00", // Wednesday of week 10
+			settings: config.BackupConfig{KeepWeekly: 2},
+			backups:  []string{"2026-03-03 09:00", "2026-03-01 09:00", "2026-02-23 09:00", "2026-02-22 09:00", "2026-02-10 09:00"},
+			want:     []string{"2026-02-23 09:00", "2026-02-22 09:00", "2026-02-10 09:00"},
+		},
+		{
+			name:     "weeks without a backup count",
+			now:      "2026-03-04 12:00",
+			settings: config.BackupConfig{KeepWeekly: 3},
+			backups:  []string{"2026-03-02 09:00", "2026-02-16 09:00", "2026-02-09 09:00"},
+			want:     []string{"2026-02-09 09:00"},
+		},
+		{
+			name:     "months",
+			now:      "2026-03-04 12:00",
+			settings: config.BackupConfig{KeepMonthly: 2},
+			backups:  []string{"2026-03-01 09:00", "2026-02-28 09:00", "2026-02-01 09:00", "2026-01-31 09:00"},
+			want:     []string{"2026-02-01 09:00", "2026-01-31 09:00"},
+		},
+		{
+			name:     "months across the new year",
+			now:      "2026-01-15 12:00",
+			settings: config.BackupConfig{KeepMonthly: 2},
+			backups:  []string{"2026-01-02 09:00", "2025-12-31 23:00", "2025-12-01 09:00", "2025-11-30 09:00"},
+			want:     []string{"2025-12-01 09:00", "2025-11-30 09:00"},
+		},
+		{
+			name:     "months without a backup count",
+			now:      "2026-03-31 12:00",
+			settings: config.BackupConfig{KeepMonthly: 3},
+			backups:  []string{"2026-03-30 09:00", "2026-01-15 09:00", "2025-12-15 09:00"},
+			want:     []string{"2025-12-15 09:00"},
+		},
+		{
+			name:     "periods overlap",
+			now:      "2026-03-04 12:00",
+			settings: config.BackupConfig{KeepDaily: 2, KeepWeekly: 2, KeepMonthly: 2},
+			backups: []string{"2026-03-04 09:00", "2026-03-03 09:00", "2026-03-02 09:00",
+				"2026-02-27 09:00", "2026-02-20 09:00", "2026-01-30 09:00"},
+			want: []string{"2026-03-02 09:00", "2026-02-20 09:00", "2026-01-30 09:00"},
+		},
+		{
+			name:     "newest is kept when outside every period",
+			now:      "2026-03-04 12:00",
+			settings: config.BackupConfig{KeepDaily: 1},
+			backups:  []string{"2026-01-10 09:00", "2026-01-09 09:00"},
+			want:     []string{"2026-01-09 09:00"},
+		},
+		{
+			name:     "manual backups are never pruned",
+			now:      "2026-03-04 12:00",
+			settings: config.BackupConfig{KeepDaily: 1},
+			backups: []string{"2026-03-04 11:00 manual", "2026-03-04 10:00", "2026-03-04 09:00 manual",
+				"2026-03-03 09:00", "2025-01-01 09:00 manual"},
+			want: []string{"2026-03-03 09:00"},
+		},
+		{
+			name:     "a newer manual backup does not stand in for timed ones",
+			now:      "2026-03-04 12:00",
+			settings: config.BackupConfig{KeepDaily: 1},
+			backups:  []string{"2026-03-04 11:00 manual", "2026-03-04 10:00", "2026-03-04 09:00"},
+			want:     []string{"2026-03-04 09:00"},
+		},
+		{
+			name:     "all zero keeps everything",
+			now:      "2026-03-04 12:00",
+			settings: config.BackupConfig{},
+			backups:  []string{"2026-03-04 10:00", "2025-01-01 09:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		backups := make([]BackupInfo, len(tt.backups))
+		for i, name := range tt.backups {
+			backups[i] = backupAt(name)
+		}
+
+		var got []string
+		for _, backup := range backupsToPrune(backups, tt.settings, backupAt(tt.now).CreatedAt) {
+			got = append(got, backup.Name)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(got)))
+
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("%s: pruned [%s], want [%s]", tt.name, strings.Join(got, ", "), strings.Join(tt.want, ", "))
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxCode128Length keeps Code128 barcodes short enough to fit on a label
+const maxCode128Length = 48
+
+// BarcodeDTO is the data transfer object for product barcodes
+type BarcodeDTO struct {
+	ID        uint                    `json:"id"`
+	ProductID uint                    `json:"product_id"`
+	Barcode   string                  `json:"barcode"`
+	Symbology models.BarcodeSymbology `json:"symbology"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+// BarcodeService handles the barcodes products are scanned by
+type BarcodeService struct {
+	dbManager *database.ConnectionManager
+	audit     *AuditService
+	products  *ProductService
+}
+
+// NewBarcodeService creates a new barcode service
+func NewBarcodeService(dbManager *database.ConnectionManager, audit *AuditService, products *ProductService) *BarcodeService {
+	return &BarcodeService{
+		dbManager: dbManager,
+		audit:     audit,
+		products:  products,
+	}
+}
+
+// Helper function to convert model to DTO
+func (s *BarcodeService) toDTO(barcode *models.ProductBarcode) BarcodeDTO {
+	return BarcodeDTO{
+		ID:        barcode.ID,
+		ProductID: barcode.ProductID,
+		Barcode:   barcode.Barcode,
+		Symbology: barcode.Symbology,
+		CreatedAt: barcode.CreatedAt,
+	}
+}
+
+// GetByProduct returns the barcodes of a product, oldest first
+func (s *BarcodeService) GetByProduct(productID uint) ([]BarcodeDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var barcodes []models.ProductBarcode
+	if err := db.Where("product_id = ?", productID).Order("id").Find(&barcodes).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch barcodes: %w", err)
+	}
+
+	dtos := make([]BarcodeDTO, len(barcodes))
+	for i := range barcodes {
+		dtos[i] = s.toDTO(&barcodes[i])
+	}
+	return dtos, nil
+}
+
+// Add assigns a barcode to a product. An empty symbology is detected from
+// the code: 13, 12 and 8 digits are EAN-13, UPC-A and EAN-8, anything else
+// is Code128. Check digits are verified.
+func (s *BarcodeService) Add(productID uint, code string, symbology models.BarcodeSymbology) (*BarcodeDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	code, symbology, err := ParseBarcode(code, symbology)
+	if err != nil {
+		return nil, err
+	}
+
+	barcode := &models.ProductBarcode{
+		ProductID: productID,
+		Barcode:   code,
+		Symbology: symbology,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.First(&product, productID).Error; err != nil {
+			return fmt.Errorf("product not found: %w", err)
+		}
+
+		var existing models.ProductBarcode
+		if err := tx.Where("barcode = ?", code).First(&existing).Error; err == nil {
+			return conflictf("barcode '%s' already exists", code)
+		}
+		// A barcode must not scan as another product's code either
+		var other models.Product
+		if err := tx.Where("code = ? AND id <> ?", code, productID).First(&other).Error; err == nil {
+			return conflictf("barcode '%s' already exists as the code of product '%s'", code, other.Name)
+		}
+
+		if err := tx.Create(barcode).Error; err != nil {
+			return fmt.Errorf("failed to create barcode: %w", err)
+		}
+		return s.audit.record(tx, auditEntityBarcode, barcode.ID, models.AuditActionCreate, nil, s.toDTO(barcode))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dto := s.toDTO(barcode)
+	return &dto, nil
+}
+
+// Delete removes a barcode by ID
+func (s *BarcodeService) Delete(id uint) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var barcode models.ProductBarcode
+		if err := tx.First(&barcode, id).Error; err != nil {
+			return fmt.Errorf("barcode not found: %w", err)
+		}
+
+		if err := tx.Delete(&models.ProductBarcode{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete barcode: %w", err)
+		}
+		return s.audit.record(tx, auditEntityBarcode, barcode.ID, models.AuditActionDelete, s.toDTO(&barcode), nil)
+	})
+}
+
+// FindProductByBarcode returns the product a scanned code belongs to. A
+// UPC-A code also matches its EAN-13 form with a leading zero and the other
+// way round, and a code no barcode matches is tried as a product code.
+func (s *BarcodeService) FindProductByBarcode(code string) (*ProductDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, fmt.Errorf("barcode cannot be empty")
+	}
+
+	candidates := []string{code}
+	if isDigits(code) {
+		switch {
+		case len(code) == 12:
+			candidates = append(candidates, "0"+code)
+		case len(code) == 13 && code[0] == '0':
+			candidates = append(candidates, code[1:])
+		}
+	}
+
+	var productIDs []uint
+	if err := db.Model(&models.ProductBarcode{}).Where("barcode IN ?", candidates).
+		Limit(1).Pluck("product_id", &productIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up barcode: %w", err)
+	}
+	if len(productIDs) > 0 {
+		return s.products.GetByID(productIDs[0])
+	}
+
+	return s.products.GetByCode(code)
+}
+
+// ParseBarcode trims code and checks it against symbology, or detects the
+// symbology when it is empty
+func ParseBarcode(code string, symbology models.BarcodeSymbology) (string, models.BarcodeSymbology, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", "", fmt.Errorf("barcode cannot be empty")
+	}
+
+	if symbology == "" {
+		symbology = detectSymbology(code)
+	}
+
+	switch symbology {
+	case models.BarcodeEAN13:
+		return code, symbology, checkGTIN(code, 13, "EAN-13")
+	case models.BarcodeEAN8:
+		return code, symbology, checkGTIN(code, 8, "EAN-8")
+	case models.BarcodeUPCA:
+		return code, symbology, checkGTIN(code, 12, "UPC-A")
+	case models.BarcodeCode128:
+		if len(code) > maxCode128Length {
+			return "", "", fmt.Errorf("barcode cannot be longer than %d characters", maxCode128Length)
+		}
+		for _, r := range code {
+			if r < ' ' || r > '~' {
+				return "", "", fmt.Errorf("barcode '%s' contains characters Code128 cannot encode", code)
+			}
+		}
+		return code, symbology, nil
+	default:
+		return "", "", fmt.Errorf("invalid barcode symbology: %s", symbology)
+	}
+}
+
+// detectSymbology picks the symbology of a code by its length
+func detectSymbology(code string) models.BarcodeSymbology {
+	if isDigits(code) {
+		switch len(code) {
+		case 13:
+			return models.BarcodeEAN13
+		case 12:
+			return models.BarcodeUPCA
+		case 8:
+			return models.BarcodeEAN8
+		}
+	}
+	return models.BarcodeCode128
+}
+
+// checkGTIN checks the length and check digit of an EAN or UPC code
+func checkGTIN(code string, length int, name string) error {
+	if len(code) != length || !isDigits(code) {
+		return fmt.Errorf("%s barcode must have %d digits", name, length)
+	}
+	if want := gtinCheckDigit(code[:length-1]); code[length-1] != want {
+		return fmt.Errorf("invalid %s check digit in '%s': expected %c", name, code, want)
+	}
+	return nil
+}
+
+// gtinCheckDigit computes the check digit of the digits before it: from the
+// right, digits are weighted 3, 1, 3, ...
+func gtinCheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		digit := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// isDigits reports whether s is made of ASCII digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+)
+
+func TestGTINCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   byte
+	}{
+		{"400638133393", '1'}, // EAN-13
+		{"590123412345", '7'}, // EAN-13
+		{"9638507", '4'},      // EAN-8
+		{"03600029145", '2'},  // UPC-A
+		{"01234567890", '5'},  // UPC-A
+		{"00000000000", '0'},
+	}
+	for _, tt := range tests {
+		if got := gtinCheckDigit(tt.digits); got != tt.want {
+			t.Errorf("gtinCheckDigit(%q) = %c, want %c", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestParseBarcode(t *testing.T) {
+	tests := []struct {
+		name          string
+		code          string
+		symbology     models.BarcodeSymbology
+		wantCode      string
+		wantSymbology models.BarcodeSymbology
+		wantErr       bool
+	}{
+		{name: "EAN-13", code: "4006381333931", wantCode: "4006381333931", wantSymbology: models.BarcodeEAN13},
+		{name: "EAN-13 trimmed", code: " 5901234123457\n", wantCode: "5901234123457", wantSymbology: models.BarcodeEAN13},
+		{name: "EAN-13 wrong check digit", code: "4006381333932", wantErr: true},
+		{name: "EAN-13 too short", code: "400638133393", symbology: models.BarcodeEAN13, wantErr: true},
+		{name: "EAN-13 with a letter", code: "400638133393A", symbology: models.BarcodeEAN13, wantErr: true},
+		{name: "EAN-8", code: "96385074", wantCode: "96385074", wantSymbology: models.BarcodeEAN8},
+		{name: "EAN-8 wrong check digit", code: "96385075", wantErr: true},
+		{name: "UPC-A", code: "036000291452", wantCode: "036000291452", wantSymbology: models.BarcodeUPCA},
+		{name: "UPC-A wrong check digit", code: "036000291453", wantErr: true},
+		{name: "UPC-A given as EAN-13", code: "036000291452", symbology: models.BarcodeEAN13, wantErr: true},
+		{name: "digits of another length are Code128", code: "1234567", wantCode: "1234567", wantSymbology: models.BarcodeCode128},
+		{name: "Code128 text", code: "ABC-123/x y~", wantCode: "ABC-123/x y~", wantSymbology: models.BarcodeCode128},
+		{name: "Code128 at the length limit", code: strings.Repeat("A", maxCode128Length), wantCode: strings.Repeat("A", maxCode128Length), wantSymbology: models.BarcodeCode128},
+		{name: "Code128 over the length limit", code: strings.Repeat("A", maxCode128Length+1), wantErr: true},
+		{name: "Code128 with a tab", code: "AB\tCD", wantErr: true},
+		{name: "Code128 with a control character", code: "AB\x1dCD", wantErr: true},
+		{name: "Code128 with DEL", code: "AB\x7fCD", wantErr: true},
+		{name: "Code128 with a non-ASCII letter", code: "ŞİŞE-1", wantErr: true},
+		{name: "empty", code: "  ", wantErr: true},
+		{name: "unknown symbology", code: "123", symbology: "QR", wantErr: true},
+	}
+	for _, tt := range tests {
+		code, symbology, err := ParseBarcode(tt.code, tt.symbology)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: ParseBarcode(%q) = %q, %s, want error", tt.name, tt.code, code, symbology)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ParseBarcode(%q) error: %v", tt.name, tt.code, err)
+			continue
+		}
+		if code != tt.wantCode || symbology != tt.wantSymbology {
+			t.Errorf("%s: ParseBarcode(%q) = %q, %s, want %q, %s", tt.name, tt.code, code, symbology, tt.wantCode, tt.wantSymbology)
+		}
+	}
+}
+
+func TestFindProductByBarcode(t *testing.T) {
+	dbManager := database.GetConnectionManager()
+	if err := dbManager.Connect(filepath.Join(t.TempDir(), "barcodes.db")); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer dbManager.Close()
+
+	auditService := NewAuditService(dbManager)
+	productService := NewProductService(dbManager, auditService)
+	barcodeService := NewBarcodeService(dbManager, auditService, productService)
+
+	upc, err := productService.Create(ProductDTO{Code: "UPC", Name: "Stored as UPC-A", CategoryID: 1, Unit: "adet"})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	ean, err := productService.Create(ProductDTO{Code: "EAN", Name: "Stored as EAN-13", CategoryID: 1, Unit: "adet"})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	if _, err := barcodeService.Add(upc.ID, "036000291452", ""); err != nil {
+		t.Fatalf("add UPC-A: %v", err)
+	}
+	if _, err := barcodeService.Add(ean.ID, "0012345678905", ""); err != nil {
+		t.Fatalf("add EAN-13: %v", err)
+	}
+
+	tests := []struct {
+		code string
+		want uint // 0 when nothing matches
+	}{
+		{"036000291452", upc.ID},
+		{"0036000291452", upc.ID}, // EAN-13 form of a stored UPC-A
+		{"0012345678905", ean.ID},
+		{"012345678905", ean.ID}, // UPC-A form of a stored EAN-13
+		{" EAN ", ean.ID},        // falls back to the product code
+		{"1036000291452", 0},     // only a leading zero is dropped
+		{"36000291452", 0},
+	}
+	for _, tt := range tests {
+		product, err := barcodeService.FindProductByBarcode(tt.code)
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("FindProductByBarcode(%q) = %s, want not found", tt.code, product.Code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FindProductByBarcode(%q) error: %v", tt.code, err)
+			continue
+		}
+		if product.ID != tt.want {
+			t.Errorf("FindProductByBarcode(%q) = product %d, want %d", tt.code, product.ID, tt.want)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"stoktakip/internal/database"
 	"stoktakip/internal/models"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // CategoryDTO is the data transfer object for categories
@@ -17,15 +19,20 @@ type CategoryDTO struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// defaultCategoryColor is used for categories created without a color
+const defaultCategoryColor = "#6B7280"
+
 // CategoryService handles category-related operations
 type CategoryService struct {
 	dbManager *database.ConnectionManager
+	audit     *AuditService
 }
 
 // NewCategoryService creates a new category service
-func NewCategoryService(dbManager *database.ConnectionManager) *CategoryService {
+func NewCategoryService(dbManager *database.ConnectionManager, audit *AuditService) *CategoryService {
 	return &CategoryService{
 		dbManager: dbManager,
+		audit:     audit,
 	}
 }
 
@@ -61,6 +68,11 @@ func (s *CategoryService) GetCategoryByID(id uint) (*models.Category, error) {
 
 // CreateCategory creates a new category
 func (s *CategoryService) CreateCategory(name, color string) (*models.Category, error) {
+	return s.create(name, "", color)
+}
+
+// create validates and stores a new category
+func (s *CategoryService) create(name, description, color string) (*models.Category, error) {
 	db := s.dbManager.GetDB()
 	if db == nil {
 		return nil, fmt.Errorf("no database connection")
@@ -71,26 +83,44 @@ func (s *CategoryService) CreateCategory(name, color string) (*models.Category,
 		return nil, fmt.Errorf("category name cannot be empty")
 	}
 
-	// Check if category with same name already exists
-	var existing models.Category
-	if err := db.Where("name = ?", name).First(&existing).Error; err == nil {
-		return nil, fmt.Errorf("category with name '%s' already exists", name)
-	}
-
 	// Set default color if not provided
 	if color == "" {
-		color = "#6B7280"
+		color = defaultCategoryColor
+	}
+
+	var category *models.Category
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		category, err = s.insert(tx, name, description, color)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// insert stores a new category inside tx, refusing duplicate names
+func (s *CategoryService) insert(tx *gorm.DB, name, description, color string) (*models.Category, error) {
+	// Check if category with same name already exists
+	var existing models.Category
+	if err := tx.Where("name = ?", name).First(&existing).Error; err == nil {
+		return nil, conflictf("category with name '%s' already exists", name)
 	}
 
 	category := &models.Category{
-		Name:  name,
-		Color: color,
+		Name:        name,
+		Description: description,
+		Color:       color,
 	}
 
-	if err := db.Create(category).Error; err != nil {
+	if err := tx.Create(category).Error; err != nil {
 		return nil, fmt.Errorf("failed to create category: %w", err)
 	}
 
+	if err := s.audit.record(tx, auditEntityCategory, category.ID, models.AuditActionCreate, nil, s.toDTO(category)); err != nil {
+		return nil, err
+	}
 	return category, nil
 }
 
@@ -101,34 +131,40 @@ func (s *CategoryService) UpdateCategory(id uint, name, color string) error {
 		return fmt.Errorf("no database connection")
 	}
 
-	// Find category
-	var category models.Category
-	if err := db.First(&category, id).Error; err != nil {
-		return fmt.Errorf("category not found: %w", err)
-	}
-
 	// Validate
 	if name == "" {
 		return fmt.Errorf("category name cannot be empty")
 	}
 
-	// Check if another category with same name exists
-	var existing models.Category
-	if err := db.Where("name = ? AND id != ?", name, id).First(&existing).Error; err == nil {
-		return fmt.Errorf("category with name '%s' already exists", name)
-	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Find category
+		var category models.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			return fmt.Errorf("category not found: %w", err)
+		}
+		before := s.toDTO(&category)
 
-	// Update fields
-	category.Name = name
-	if color != "" {
-		category.Color = color
-	}
+		// Check if another category with same name exists
+		var existing models.Category
+		if err := tx.Where("name = ? AND id != ?", name, id).First(&existing).Error; err == nil {
+			return conflictf("category with name '%s' already exists", name)
+		}
 
-	if err := db.Save(&category).Error; err != nil {
-		return fmt.Errorf("failed to update category: %w", err)
-	}
+		// Update fields
+		category.Name = name
+		if color != "" {
+			category.Color = color
+		}
 
-	return nil
+		if err := tx.Save(&category).Error; err != nil {
+			return fmt.Errorf("failed to update category: %w", err)
+		}
+		if err := indexCategoryProducts(tx, category.ID); err != nil {
+			return err
+		}
+
+		return s.audit.record(tx, auditEntityCategory, category.ID, models.AuditActionUpdate, before, s.toDTO(&category))
+	})
 }
 
 // DeleteCategory deletes a category
@@ -138,22 +174,29 @@ func (s *CategoryService) DeleteCategory(id uint) error {
 		return fmt.Errorf("no database connection")
 	}
 
-	// Check if category has products
-	var productCount int64
-	if err := db.Model(&models.Product{}).Where("category_id = ?", id).Count(&productCount).Error; err != nil {
-		return fmt.Errorf("failed to check products: %w", err)
-	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			return fmt.Errorf("category not found: %w", err)
+		}
 
-	if productCount > 0 {
-		return fmt.Errorf("cannot delete category with %d products. Please reassign or delete the products first", productCount)
-	}
+		// Check if category has products
+		var productCount int64
+		if err := tx.Model(&models.Product{}).Where("category_id = ?", id).Count(&productCount).Error; err != nil {
+			return fmt.Errorf("failed to check products: %w", err)
+		}
 
-	// Delete category
-	if err := db.Delete(&models.Category{}, id).Error; err != nil {
-		return fmt.Errorf("failed to delete category: %w", err)
-	}
+		if productCount > 0 {
+			return conflictf("cannot delete category with %d products. Please reassign or delete the products first", productCount)
+		}
+
+		// Delete category
+		if err := tx.Delete(&models.Category{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete category: %w", err)
+		}
 
-	return nil
+		return s.audit.record(tx, auditEntityCategory, category.ID, models.AuditActionDelete, s.toDTO(&category), nil)
+	})
 }
 
 // GetCategoryCount returns the total number of categories
@@ -211,20 +254,11 @@ func (s *CategoryService) GetByID(id uint) (*CategoryDTO, error) {
 
 // Create creates a new category from DTO
 func (s *CategoryService) Create(dto CategoryDTO) (*CategoryDTO, error) {
-	category, err := s.CreateCategory(dto.Name, dto.Color)
+	category, err := s.create(dto.Name, dto.Description, dto.Color)
 	if err != nil {
 		return nil, err
 	}
 
-	// Update description if provided
-	if dto.Description != "" {
-		db := s.dbManager.GetDB()
-		category.Description = dto.Description
-		if err := db.Save(category).Error; err != nil {
-			return nil, fmt.Errorf("failed to update description: %w", err)
-		}
-	}
-
 	resultDTO := s.toDTO(category)
 	return &resultDTO, nil
 }
@@ -237,17 +271,27 @@ func (s *CategoryService) Update(id uint, dto CategoryDTO) (*CategoryDTO, error)
 	}
 
 	var category models.Category
-	if err := db.First(&category, id).Error; err != nil {
-		return nil, fmt.Errorf("category not found: %w", err)
-	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&category, id).Error; err != nil {
+			return fmt.Errorf("category not found: %w", err)
+		}
+		before := s.toDTO(&category)
+
+		// Update fields
+		category.Name = dto.Name
+		category.Description = dto.Description
+		category.Color = dto.Color
 
-	// Update fields
-	category.Name = dto.Name
-	category.Description = dto.Description
-	category.Color = dto.Color
+		if err := tx.Save(&category).Error; err != nil {
+			return fmt.Errorf("failed to update category: %w", err)
+		}
+		if err := indexCategoryProducts(tx, category.ID); err != nil {
+			return err
+		}
 
-	if err := db.Save(&category).Error; err != nil {
-		return nil, fmt.Errorf("failed to update category: %w", err)
+		return s.audit.record(tx, auditEntityCategory, category.ID, models.AuditActionUpdate, before, s.toDTO(&category))
+	}); err != nil {
+		return nil, err
 	}
 
 	resultDTO := s.toDTO(&category)
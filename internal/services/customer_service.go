@@ -0,0 +1,337 @@
+package services
+
+import (
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CustomerDTO is the data transfer object for customers, cost centers and projects
+type CustomerDTO struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"` // "CUSTOMER", "COST_CENTER" or "PROJECT"; empty means CUSTOMER on create
+	TaxNumber string    `json:"tax_number"`
+	Contact   string    `json:"contact"`
+	Phone     string    `json:"phone"`
+	Email     string    `json:"email"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConsumptionQuery selects the OUT movements a consumption report sums. Zero
+// filter values match everything.
+type ConsumptionQuery struct {
+	From       time.Time `json:"from"` // Movement date range, inclusive
+	To         time.Time `json:"to"`
+	CustomerID uint      `json:"customer_id"`
+	Kind       string    `json:"kind"` // Kind of customer
+}
+
+// ConsumptionLineDTO is the quantity of one product issued to a destination
+type ConsumptionLineDTO struct {
+	ProductID uint            `json:"product_id"`
+	Code      string          `json:"code"`
+	Name      string          `json:"name"`
+	Unit      string          `json:"unit"`
+	Quantity  models.Quantity `json:"quantity"`
+	Movements int             `json:"movements"`
+	Value     models.Money    `json:"value"` // Quantity at the product's current price
+}
+
+// DestinationConsumptionDTO is everything issued to one customer, cost center or project
+type DestinationConsumptionDTO struct {
+	CustomerID uint                 `json:"customer_id"`
+	Customer   string               `json:"customer"`
+	Kind       string               `json:"kind"`
+	Lines      []ConsumptionLineDTO `json:"lines"`
+	Value      models.Money         `json:"value"`
+}
+
+// ConsumptionReport sums the OUT movements with a destination over a date
+// range, by destination and product. OUT movements without a destination are
+// left out.
+type ConsumptionReport struct {
+	From         time.Time                   `json:"from"`
+	To           time.Time                   `json:"to"`
+	Destinations []DestinationConsumptionDTO `json:"destinations"`
+	Value        models.Money                `json:"value"`
+}
+
+// CustomerService handles customers, cost centers and projects stock is issued to
+type CustomerService struct {
+	dbManager *database.ConnectionManager
+	audit     *AuditService
+}
+
+// NewCustomerService creates a new customer service
+func NewCustomerService(dbManager *database.ConnectionManager, audit *AuditService) *CustomerService {
+	return &CustomerService{
+		dbManager: dbManager,
+		audit:     audit,
+	}
+}
+
+// Helper function to convert model to DTO
+func (s *CustomerService) toDTO(customer *models.Customer) CustomerDTO {
+	return CustomerDTO{
+		ID:        customer.ID,
+		Name:      customer.Name,
+		Kind:      string(customer.Kind),
+		TaxNumber: customer.TaxNumber,
+		Contact:   customer.Contact,
+		Phone:     customer.Phone,
+		Email:     customer.Email,
+		Note:      customer.Note,
+		CreatedAt: customer.CreatedAt,
+		UpdatedAt: customer.UpdatedAt,
+	}
+}
+
+// GetAll returns customers by name; a kind other than "" limits them to that kind
+func (s *CustomerService) GetAll(kind string) ([]CustomerDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	query := db.Order("name ASC")
+	if kind != "" {
+		if !models.CustomerKind(kind).IsValid() {
+			return nil, fmt.Errorf("invalid customer kind: %s", kind)
+		}
+		query = query.Where("kind = ?", kind)
+	}
+
+	var customers []models.Customer
+	if err := query.Find(&customers).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch customers: %w", err)
+	}
+
+	dtos := make([]CustomerDTO, len(customers))
+	for i := range customers {
+		dtos[i] = s.toDTO(&customers[i])
+	}
+	return dtos, nil
+}
+
+// GetByID returns a customer by ID as DTO
+func (s *CustomerService) GetByID(id uint) (*CustomerDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var customer models.Customer
+	if err := db.First(&customer, id).Error; err != nil {
+		return nil, fmt.Errorf("customer not found: %w", err)
+	}
+
+	dto := s.toDTO(&customer)
+	return &dto, nil
+}
+
+// Create creates a new customer from DTO
+func (s *CustomerService) Create(dto CustomerDTO) (*CustomerDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	if dto.Kind == "" {
+		dto.Kind = string(models.CustomerKindCustomer)
+	}
+
+	customer := &models.Customer{}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := s.assign(tx, customer, dto); err != nil {
+			return err
+		}
+		if err := tx.Create(customer).Error; err != nil {
+			return fmt.Errorf("failed to create customer: %w", err)
+		}
+		return s.audit.record(tx, auditEntityCustomer, customer.ID, models.AuditActionCreate, nil, s.toDTO(customer))
+	}); err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(customer)
+	return &resultDTO, nil
+}
+
+// Update updates an existing customer
+func (s *CustomerService) Update(id uint, dto CustomerDTO) (*CustomerDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var customer models.Customer
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&customer, id).Error; err != nil {
+			return fmt.Errorf("customer not found: %w", err)
+		}
+		before := s.toDTO(&customer)
+
+		if err := s.assign(tx, &customer, dto); err != nil {
+			return err
+		}
+		if err := tx.Save(&customer).Error; err != nil {
+			return fmt.Errorf("failed to update customer: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntityCustomer, customer.ID, models.AuditActionUpdate, before, s.toDTO(&customer))
+	}); err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(&customer)
+	return &resultDTO, nil
+}
+
+// assign validates dto and copies its fields to customer
+func (s *CustomerService) assign(tx *gorm.DB, customer *models.Customer, dto CustomerDTO) error {
+	name := strings.TrimSpace(dto.Name)
+	if name == "" {
+		return fmt.Errorf("customer name cannot be empty")
+	}
+	if !models.CustomerKind(dto.Kind).IsValid() {
+		return fmt.Errorf("invalid customer kind: %s", dto.Kind)
+	}
+
+	var existing models.Customer
+	if err := tx.Where("name = ? AND id <> ?", name, customer.ID).First(&existing).Error; err == nil {
+		return conflictf("customer with name '%s' already exists", name)
+	}
+
+	customer.Name = name
+	customer.Kind = models.CustomerKind(dto.Kind)
+	customer.TaxNumber = strings.TrimSpace(dto.TaxNumber)
+	customer.Contact = strings.TrimSpace(dto.Contact)
+	customer.Phone = strings.TrimSpace(dto.Phone)
+	customer.Email = strings.TrimSpace(dto.Email)
+	customer.Note = dto.Note
+	return nil
+}
+
+// Delete deletes a customer that has no sales orders or movements
+func (s *CustomerService) Delete(id uint) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var customer models.Customer
+		if err := tx.First(&customer, id).Error; err != nil {
+			return fmt.Errorf("customer not found: %w", err)
+		}
+
+		var orderCount int64
+		if err := tx.Model(&models.SalesOrder{}).Where("customer_id = ?", id).Count(&orderCount).Error; err != nil {
+			return fmt.Errorf("failed to check sales orders: %w", err)
+		}
+		if orderCount > 0 {
+			return conflictf("cannot delete customer with %d sales orders", orderCount)
+		}
+
+		var movementCount int64
+		if err := tx.Model(&models.StockMovement{}).Where("customer_id = ?", id).Count(&movementCount).Error; err != nil {
+			return fmt.Errorf("failed to check movements: %w", err)
+		}
+		if movementCount > 0 {
+			return conflictf("cannot delete customer with %d movements", movementCount)
+		}
+
+		if err := tx.Delete(&models.Customer{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete customer: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntityCustomer, customer.ID, models.AuditActionDelete, s.toDTO(&customer), nil)
+	})
+}
+
+// Consumption sums what was issued to each destination matching q, by
+// product. Destinations are sorted by name and their products by code.
+func (s *CustomerService) Consumption(q ConsumptionQuery) (*ConsumptionReport, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+	if q.Kind != "" && !models.CustomerKind(q.Kind).IsValid() {
+		return nil, fmt.Errorf("invalid customer kind: %s", q.Kind)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return nil, fmt.Errorf("date range ends before it starts")
+	}
+
+	// Compare as instants: stored times carry their own offset
+	query := db.Table("stock_movements AS m").
+		Select(`c.id AS customer_id, c.name AS customer, c.kind, p.id AS product_id, p.code, p.name, p.unit, p.price,
+			SUM(m.quantity) AS quantity, COUNT(*) AS movements`).
+		Joins("JOIN customers AS c ON c.id = m.customer_id").
+		Joins("JOIN products AS p ON p.id = m.product_id").
+		Where("m.type = ?", models.MovementTypeOut)
+	if !q.From.IsZero() {
+		query = query.Where("julianday(m.date) >= julianday(?)", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("julianday(m.date) <= julianday(?)", q.To)
+	}
+	if q.CustomerID != 0 {
+		query = query.Where("c.id = ?", q.CustomerID)
+	}
+	if q.Kind != "" {
+		query = query.Where("c.kind = ?", q.Kind)
+	}
+
+	var rows []struct {
+		CustomerID uint
+		Customer   string
+		Kind       string
+		ProductID  uint
+		Code       string
+		Name       string
+		Unit       string
+		Price      models.Money
+		Quantity   models.Quantity
+		Movements  int
+	}
+	if err := query.Group("c.id, p.id").Order("c.name ASC, p.code ASC").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch consumption: %w", err)
+	}
+
+	report := &ConsumptionReport{From: q.From, To: q.To, Destinations: []DestinationConsumptionDTO{}}
+	for _, row := range rows {
+		n := len(report.Destinations)
+		if n == 0 || report.Destinations[n-1].CustomerID != row.CustomerID {
+			report.Destinations = append(report.Destinations, DestinationConsumptionDTO{
+				CustomerID: row.CustomerID,
+				Customer:   row.Customer,
+				Kind:       row.Kind,
+				Lines:      []ConsumptionLineDTO{},
+			})
+			n++
+		}
+
+		line := ConsumptionLineDTO{
+			ProductID: row.ProductID,
+			Code:      row.Code,
+			Name:      row.Name,
+			Unit:      row.Unit,
+			Quantity:  row.Quantity,
+			Movements: row.Movements,
+			Value:     row.Quantity.MulPrice(row.Price),
+		}
+		destination := &report.Destinations[n-1]
+		destination.Lines = append(destination.Lines, line)
+		destination.Value += line.Value
+		report.Value += line.Value
+	}
+	return report, nil
+}
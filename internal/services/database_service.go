@@ -2,28 +2,92 @@ package services
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"stoktakip/internal/config"
 	"stoktakip/internal/database"
+	"stoktakip/internal/models"
 	"stoktakip/internal/utils"
+	"strings"
+	"sync"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // DatabaseInfo contains information about a database file
 type DatabaseInfo struct {
-	Name     string  `json:"name"`
-	Path     string  `json:"path"`
-	Size     float64 `json:"size"`      // Size in MB
-	Modified string  `json:"modified"`  // Last modified date
-	IsActive bool    `json:"is_active"` // Currently connected
+	Name      string  `json:"name"`
+	Path      string  `json:"path"`
+	Size      float64 `json:"size"`      // Size in MB
+	Modified  string  `json:"modified"`  // Last modified date
+	IsActive  bool    `json:"is_active"` // Currently connected
+	Encrypted bool    `json:"encrypted"` // Needs a passphrase to open
+}
+
+// BackupInfo describes a backup file of a database
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Size      float64   `json:"size"` // Size in MB
+	CreatedAt time.Time `json:"created_at"`
+	Encrypted bool      `json:"encrypted"`
+	Automatic bool      `json:"automatic"` // Written on the scheduler's timer, the only kind pruned
+}
+
+// DatabaseSummary holds the row counts and stock totals of a database
+type DatabaseSummary struct {
+	Products   int64           `json:"products"`
+	Categories int64           `json:"categories"`
+	Movements  int64           `json:"movements"`
+	TotalStock models.Quantity `json:"total_stock"` // Sum across products, whatever their unit
+	StockValue models.Money    `json:"stock_value"`
 }
 
+// ProductStockChange compares one product in the live database and a backup
+type ProductStockChange struct {
+	Code          string          `json:"code"`
+	Name          string          `json:"name"`
+	CurrentStock  models.Quantity `json:"current_stock"`
+	RestoredStock models.Quantity `json:"restored_stock"`
+	CurrentPrice  models.Money    `json:"current_price"`
+	RestoredPrice models.Money    `json:"restored_price"`
+}
+
+// RestorePreview shows what restoring a backup would change
+type RestorePreview struct {
+	Backup          BackupInfo           `json:"backup"`
+	Current         DatabaseSummary      `json:"current"`
+	Restored        DatabaseSummary      `json:"restored"`
+	ProductsAdded   []ProductStockChange `json:"products_added"`   // Only in the backup
+	ProductsRemoved []ProductStockChange `json:"products_removed"` // Only in the live database
+	ProductsChanged []ProductStockChange `json:"products_changed"` // Stock or price differs
+}
+
+// backupTimeFormat is the timestamp in backup file names
+const backupTimeFormat = "20060102_150405"
+
+// automaticBackupSuffix follows the timestamp in the names of timed backups
+const automaticBackupSuffix = "_auto"
+
 // DatabaseService handles database-related operations
 type DatabaseService struct {
 	dbManager     *database.ConnectionManager
 	pathManager   *utils.PathManager
 	configManager *config.Manager
+
+	// Backup summaries by path, kept while the file's size and time are unchanged
+	summariesMu sync.Mutex
+	summaries   map[string]cachedSummary
+}
+
+// cachedSummary is a backup summary and the file state it was computed from
+type cachedSummary struct {
+	size    int64
+	modTime time.Time
+	summary DatabaseSummary
 }
 
 // NewDatabaseService creates a new database service
@@ -59,20 +123,28 @@ func (s *DatabaseService) ListDatabases() ([]DatabaseInfo, error) {
 		// Calculate size in MB
 		sizeMB := float64(info.Size()) / (1024 * 1024)
 
+		// Only the header is read, the file is not opened as a database
+		encrypted, err := database.IsEncrypted(fullPath)
+		if err != nil {
+			continue
+		}
+
 		databases = append(databases, DatabaseInfo{
-			Name:     filename,
-			Path:     fullPath,
-			Size:     sizeMB,
-			Modified: info.ModTime().Format("2006-01-02 15:04:05"),
-			IsActive: false, // Will be set below
+			Name:      filename,
+			Path:      fullPath,
+			Size:      sizeMB,
+			Modified:  info.ModTime().Format("2006-01-02 15:04:05"),
+			IsActive:  false, // Will be set below
+			Encrypted: encrypted,
 		})
 	}
 
 	return databases, nil
 }
 
-// CreateDatabase creates a new database file with the given name
-func (s *DatabaseService) CreateDatabase(name string) error {
+// CreateDatabase creates a new database file with the given name.
+// A non-empty passphrase creates an encrypted database.
+func (s *DatabaseService) CreateDatabase(name, passphrase string) error {
 	// Step 1: Validate name
 	if name == "" {
 		return fmt.Errorf("veritabanı adı boş olamaz")
@@ -85,13 +157,13 @@ func (s *DatabaseService) CreateDatabase(name string) error {
 
 	// Step 3: Get and log paths
 	dataFolder := s.pathManager.GetDataFolder()
-	fmt.Printf("Data folder path: %s\n", dataFolder)
+	log.Printf("Data folder path: %s", dataFolder)
 
 	// Step 4: Ensure Data folder exists
 	if err := s.pathManager.EnsureDataFolder(); err != nil {
 		return fmt.Errorf("Data klasörü oluşturulamadı (%s): %w", dataFolder, err)
 	}
-	fmt.Printf("Data folder ensured\n")
+	log.Printf("Data folder ensured")
 
 	// Step 5: Check folder is writable by trying to create a temp file
 	testFile := filepath.Join(dataFolder, ".test_write")
@@ -99,11 +171,11 @@ func (s *DatabaseService) CreateDatabase(name string) error {
 		return fmt.Errorf("Data klasörüne yazma izni yok (%s): %w", dataFolder, err)
 	}
 	os.Remove(testFile)
-	fmt.Printf("Write permission OK\n")
+	log.Printf("Write permission OK")
 
 	// Step 6: Get database path
 	dbPath := s.pathManager.GetDatabasePath(name)
-	fmt.Printf("Database path: %s\n", dbPath)
+	log.Printf("Database path: %s", dbPath)
 
 	// Step 7: Check if file already exists
 	if s.pathManager.FileExists(dbPath) {
@@ -111,30 +183,48 @@ func (s *DatabaseService) CreateDatabase(name string) error {
 	}
 
 	// Step 8: Create and connect to the new database
-	fmt.Printf("Connecting to database...\n")
-	if err := s.dbManager.Connect(dbPath); err != nil {
+	log.Printf("Connecting to database...")
+	connect := s.dbManager.Connect
+	if passphrase != "" {
+		connect = func(path string) error { return s.dbManager.ConnectEncrypted(path, passphrase) }
+	}
+	if err := connect(dbPath); err != nil {
 		return fmt.Errorf("veritabanı bağlantısı başarısız: %w", err)
 	}
-	fmt.Printf("Database connected successfully\n")
+	log.Printf("Database connected successfully")
 
 	// Step 9: Update config with new database (save only filename for portability)
 	if err := s.configManager.SetLastDatabase(name); err != nil {
 		return fmt.Errorf("yapılandırma kaydedilemedi: %w", err)
 	}
-	fmt.Printf("Config saved\n")
+	log.Printf("Config saved")
 
 	return nil
 }
 
-// SwitchDatabase switches to a different database
-func (s *DatabaseService) SwitchDatabase(path string) error {
+// SwitchDatabase switches to a different database. The passphrase is
+// required for encrypted databases and ignored otherwise.
+func (s *DatabaseService) SwitchDatabase(path, passphrase string) error {
 	// Check if file exists
 	if !s.pathManager.FileExists(path) {
 		return fmt.Errorf("database file not found: %s", path)
 	}
 
+	encrypted, err := database.IsEncrypted(path)
+	if err != nil {
+		return fmt.Errorf("failed to read database: %w", err)
+	}
+
 	// Connect to the database
-	if err := s.dbManager.Connect(path); err != nil {
+	if encrypted {
+		if passphrase == "" {
+			return fmt.Errorf("database is encrypted, enter its passphrase")
+		}
+		err = s.dbManager.ConnectEncrypted(path, passphrase)
+	} else {
+		err = s.dbManager.Connect(path)
+	}
+	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
@@ -153,13 +243,11 @@ func (s *DatabaseService) GetCurrentDatabase() (*DatabaseInfo, error) {
 		return nil, fmt.Errorf("no database connected")
 	}
 
-	lastDB := s.configManager.GetLastDatabase()
-	if lastDB == "" {
-		return nil, fmt.Errorf("no database path in config")
-	}
+	// The config only keeps the file name, so use the connected path
+	dbPath := s.dbManager.GetPath()
 
 	// Get file info
-	info, err := os.Stat(lastDB)
+	info, err := os.Stat(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database info: %w", err)
 	}
@@ -167,20 +255,38 @@ func (s *DatabaseService) GetCurrentDatabase() (*DatabaseInfo, error) {
 	sizeMB := float64(info.Size()) / (1024 * 1024)
 
 	return &DatabaseInfo{
-		Name:     filepath.Base(lastDB),
-		Path:     lastDB,
-		Size:     sizeMB,
-		Modified: info.ModTime().Format("2006-01-02 15:04:05"),
-		IsActive: true,
+		Name:      filepath.Base(dbPath),
+		Path:      dbPath,
+		Size:      sizeMB,
+		Modified:  info.ModTime().Format("2006-01-02 15:04:05"),
+		IsActive:  true,
+		Encrypted: s.dbManager.IsEncrypted(),
 	}, nil
 }
 
+// EncryptDatabase converts the current plain database into an encrypted one.
+// Pre-migration, pre-restore and Backups copies made before stay unencrypted.
+func (s *DatabaseService) EncryptDatabase(passphrase string) error {
+	if err := s.dbManager.Encrypt(passphrase); err != nil {
+		return fmt.Errorf("failed to encrypt database: %w", err)
+	}
+	return nil
+}
+
+// ChangePassphrase changes the passphrase of the current encrypted database
+func (s *DatabaseService) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if err := s.dbManager.ChangePassphrase(oldPassphrase, newPassphrase); err != nil {
+		return fmt.Errorf("failed to change passphrase: %w", err)
+	}
+	return nil
+}
+
 // DeleteDatabase deletes a database file
 func (s *DatabaseService) DeleteDatabase(path string) error {
 	// Check if it's the currently connected database
 	currentDB := s.configManager.GetLastDatabase()
 	if currentDB == path {
-		return fmt.Errorf("cannot delete currently connected database")
+		return conflictf("cannot delete currently connected database")
 	}
 
 	// Check if file exists
@@ -196,38 +302,298 @@ func (s *DatabaseService) DeleteDatabase(path string) error {
 	return nil
 }
 
-// BackupDatabase creates a backup of the current database
+// BackupDatabase writes a verified snapshot of the current database into the
+// Backups folder and returns its path
 func (s *DatabaseService) BackupDatabase() (string, error) {
-	if !s.dbManager.IsConnected() {
+	return s.backup(false)
+}
+
+// backup writes a backup; automatic ones are named so the scheduler can tell
+// them from the rest and prune only those
+func (s *DatabaseService) backup(automatic bool) (string, error) {
+	currentDB := s.dbManager.GetPath()
+	if currentDB == "" {
 		return "", fmt.Errorf("no database connected")
 	}
 
-	currentDB := s.configManager.GetLastDatabase()
-	if currentDB == "" {
-		return "", fmt.Errorf("no database path in config")
+	if err := s.pathManager.EnsureBackupFolder(); err != nil {
+		return "", fmt.Errorf("failed to create Backups folder: %w", err)
 	}
 
 	// Create backup filename with timestamp
-	timestamp := time.Now().Format("20060102_150405")
-	backupName := fmt.Sprintf("%s_backup_%s.db",
-		filepath.Base(currentDB[:len(currentDB)-3]), // Remove .db extension
-		timestamp,
-	)
+	backupName := backupPrefix(currentDB) + time.Now().Format(backupTimeFormat)
+	if automatic {
+		backupName += automaticBackupSuffix
+	}
+	backupName += ".db"
+
+	backupPath := s.pathManager.GetBackupPath(backupName)
+	if s.pathManager.FileExists(backupPath) {
+		return "", conflictf("backup already exists: %s", backupName)
+	}
 
-	backupPath := s.pathManager.GetDatabasePath(backupName)
+	if err := s.dbManager.Backup(backupPath); err != nil {
+		return "", fmt.Errorf("failed to back up database: %w", err)
+	}
 
-	// Read source file
-	data, err := os.ReadFile(currentDB)
+	return backupPath, nil
+}
+
+// ListBackups returns the backups of the current database, newest first
+func (s *DatabaseService) ListBackups() ([]BackupInfo, error) {
+	currentDB := s.dbManager.GetPath()
+	if currentDB == "" {
+		return nil, fmt.Errorf("no database connected")
+	}
+
+	files, err := os.ReadDir(s.pathManager.GetBackupFolder())
+	if os.IsNotExist(err) {
+		return []BackupInfo{}, nil
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to read database: %w", err)
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	prefix := backupPrefix(currentDB)
+	backups := []BackupInfo{}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasPrefix(name, prefix) || filepath.Ext(name) != ".db" {
+			continue
+		}
+
+		// Names of other databases may share the prefix; the rest must be the timestamp
+		stamp, automatic := strings.CutSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".db"), automaticBackupSuffix)
+		createdAt, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		path := s.pathManager.GetBackupPath(name)
+		encrypted, err := database.IsEncrypted(path)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, BackupInfo{
+			Name:      name,
+			Path:      path,
+			Size:      float64(info.Size()) / (1024 * 1024),
+			CreatedAt: createdAt,
+			Encrypted: encrypted,
+			Automatic: automatic,
+		})
 	}
 
-	// Write backup file
-	if err := os.WriteFile(backupPath, data, 0644); err != nil {
-		return "", fmt.Errorf("failed to write backup: %w", err)
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+
+	return backups, nil
+}
+
+// BackupSummary returns the row counts of a backup of the current database.
+// Opening a backup means copying, checking and migrating it, so summaries
+// are cached until the file changes. passphrase is only needed for backups
+// encrypted with an older passphrase.
+func (s *DatabaseService) BackupSummary(backupPath, passphrase string) (*DatabaseSummary, error) {
+	backup, err := s.findBackup(backupPath)
+	if err != nil {
+		return nil, err
 	}
 
-	return backupPath, nil
+	stat, err := os.Stat(backup.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	s.summariesMu.Lock()
+	cached, ok := s.summaries[backup.Path]
+	s.summariesMu.Unlock()
+	if ok && cached.size == stat.Size() && cached.modTime.Equal(stat.ModTime()) {
+		summary := cached.summary
+		return &summary, nil
+	}
+
+	snapshot, err := s.dbManager.OpenSnapshot(backup.Path, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer snapshot.Close()
+
+	products, err := loadProductStates(snapshot.DB)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := summarize(snapshot.DB, products)
+	if err != nil {
+		return nil, err
+	}
+
+	s.summariesMu.Lock()
+	if s.summaries == nil {
+		s.summaries = make(map[string]cachedSummary)
+	}
+	s.summaries[backup.Path] = cachedSummary{size: stat.Size(), modTime: stat.ModTime(), summary: *summary}
+	s.summariesMu.Unlock()
+
+	return summary, nil
+}
+
+// PreviewRestore compares a backup of the current database with the live data.
+// passphrase is only needed for backups encrypted with an older passphrase.
+func (s *DatabaseService) PreviewRestore(backupPath, passphrase string) (*RestorePreview, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	backup, err := s.findBackup(backupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := s.dbManager.OpenSnapshot(backup.Path, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer snapshot.Close()
+
+	current, err := loadProductStates(db)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := loadProductStates(snapshot.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &RestorePreview{
+		Backup:          *backup,
+		ProductsAdded:   []ProductStockChange{},
+		ProductsRemoved: []ProductStockChange{},
+		ProductsChanged: []ProductStockChange{},
+	}
+
+	currentSummary, err := summarize(db, current)
+	if err != nil {
+		return nil, err
+	}
+	restoredSummary, err := summarize(snapshot.DB, restored)
+	if err != nil {
+		return nil, err
+	}
+	preview.Current, preview.Restored = *currentSummary, *restoredSummary
+
+	for code, live := range current {
+		old, ok := restored[code]
+		switch {
+		case !ok:
+			preview.ProductsRemoved = append(preview.ProductsRemoved, ProductStockChange{
+				Code: code, Name: live.Name, CurrentStock: live.CurrentStock, CurrentPrice: live.Price,
+			})
+		case old.CurrentStock != live.CurrentStock || old.Price != live.Price:
+			preview.ProductsChanged = append(preview.ProductsChanged, ProductStockChange{
+				Code: code, Name: live.Name,
+				CurrentStock: live.CurrentStock, RestoredStock: old.CurrentStock,
+				CurrentPrice: live.Price, RestoredPrice: old.Price,
+			})
+		}
+	}
+	for code, old := range restored {
+		if _, ok := current[code]; !ok {
+			preview.ProductsAdded = append(preview.ProductsAdded, ProductStockChange{
+				Code: code, Name: old.Name, RestoredStock: old.CurrentStock, RestoredPrice: old.Price,
+			})
+		}
+	}
+
+	for _, changes := range [][]ProductStockChange{preview.ProductsAdded, preview.ProductsRemoved, preview.ProductsChanged} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Code < changes[j].Code })
+	}
+
+	return preview, nil
+}
+
+// RestoreBackup replaces the current database with one of its backups and
+// returns the path of the safety copy of the replaced file. The backup is
+// opened and checked first; the session ends, as with switching databases.
+func (s *DatabaseService) RestoreBackup(backupPath, passphrase string) (string, error) {
+	backup, err := s.findBackup(backupPath)
+	if err != nil {
+		return "", err
+	}
+
+	snapshot, err := s.dbManager.OpenSnapshot(backup.Path, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to open backup: %w", err)
+	}
+	snapshot.Close()
+
+	safetyPath, err := s.dbManager.Restore(backup.Path, passphrase)
+	if err != nil {
+		return "", fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	return safetyPath, nil
+}
+
+// findBackup returns the backup of the current database at path
+func (s *DatabaseService) findBackup(path string) (*BackupInfo, error) {
+	backups, err := s.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, backup := range backups {
+		if backup.Path == filepath.Clean(path) {
+			return &backup, nil
+		}
+	}
+
+	return nil, fmt.Errorf("not a backup of the current database: %s", filepath.Base(path))
+}
+
+// loadProductStates returns the stock and price of each product by code
+func loadProductStates(db *gorm.DB) (map[string]models.Product, error) {
+	var products []models.Product
+	if err := db.Select("code", "name", "current_stock", "price").Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	states := make(map[string]models.Product, len(products))
+	for _, product := range products {
+		states[product.Code] = product
+	}
+	return states, nil
+}
+
+// summarize counts the rows of db and totals the stock of products
+func summarize(db *gorm.DB, products map[string]models.Product) (*DatabaseSummary, error) {
+	summary := &DatabaseSummary{Products: int64(len(products))}
+	for _, product := range products {
+		summary.TotalStock += product.CurrentStock
+		summary.StockValue += product.CurrentStock.MulPrice(product.Price)
+	}
+
+	if err := db.Model(&models.Category{}).Count(&summary.Categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to count categories: %w", err)
+	}
+	if err := db.Model(&models.StockMovement{}).Count(&summary.Movements).Error; err != nil {
+		return nil, fmt.Errorf("failed to count movements: %w", err)
+	}
+
+	return summary, nil
+}
+
+// backupPrefix returns the start of the backup file names of a database
+func backupPrefix(dbPath string) string {
+	return strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath)) + "_backup_"
 }
 
 // IsConnected checks if there's an active database connection
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stoktakip/internal/config"
+	"stoktakip/internal/database"
+	"stoktakip/internal/utils"
+)
+
+func TestBackupSummaryIsCachedUntilTheFileChanges(t *testing.T) {
+	// Backups go next to the test binary
+	pathManager, err := utils.NewPathManager()
+	if err != nil {
+		t.Fatalf("path manager: %v", err)
+	}
+	dbManager := database.GetConnectionManager()
+	if err := dbManager.Connect(filepath.Join(t.TempDir(), "summary.db")); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer dbManager.Close()
+
+	databaseService := NewDatabaseService(dbManager, pathManager, config.NewManager(pathManager))
+	productService := NewProductService(dbManager, NewAuditService(dbManager))
+	if _, err := productService.Create(ProductDTO{Code: "P-1", Name: "Bolt", CategoryID: 1, Unit: "adet"}); err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+
+	backupPath, err := databaseService.BackupDatabase()
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	defer os.Remove(backupPath)
+
+	summary, err := databaseService.BackupSummary(backupPath, "")
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if summary.Products != 1 {
+		t.Errorf("products = %d, want 1", summary.Products)
+	}
+
+	// Same size and time: the cached summary is returned without opening the file
+	stat, err := os.Stat(backupPath)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if err := os.WriteFile(backupPath, make([]byte, stat.Size()), 0600); err != nil {
+		t.Fatalf("overwrite backup: %v", err)
+	}
+	if err := os.Chtimes(backupPath, stat.ModTime(), stat.ModTime()); err != nil {
+		t.Fatalf("restore time: %v", err)
+	}
+	if cached, err := databaseService.BackupSummary(backupPath, ""); err != nil || cached.Products != 1 {
+		t.Errorf("unchanged backup: summary = %+v, %v, want the cached one", cached, err)
+	}
+
+	// Another time: the file is opened again and found damaged
+	later := stat.ModTime().Add(time.Minute)
+	if err := os.Chtimes(backupPath, later, later); err != nil {
+		t.Fatalf("change time: %v", err)
+	}
+	if _, err := databaseService.BackupSummary(backupPath, ""); err == nil {
+		t.Errorf("changed backup: summary came from the cache")
+	}
+}
@@ -0,0 +1,16 @@
+package services
+
+import "fmt"
+
+// ConflictError reports a change the current state of the data does not
+// allow, such as a duplicate name, a record still in use or too little stock
+type ConflictError struct {
+	message string
+}
+
+func (e *ConflictError) Error() string { return e.message }
+
+// conflictf formats a ConflictError
+func conflictf(format string, args ...interface{}) error {
+	return &ConflictError{message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,467 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"stoktakip/internal/config"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ExportQuery selects the movements of an exported workbook. Zero values
+// leave the range open on that side.
+type ExportQuery struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// exportLabels are the sheet names and headers of exported workbooks and reports
+type exportLabels struct {
+	productsSheet, movementsSheet, valuationSheet string
+
+	code, name, category, unit, criticalLimit, price, stock, stockValue string
+	date, movementType, quantity, location, toLocation, user, note      string
+	customer                                                            string
+	productCount, share, total                                          string
+
+	movementTypes map[models.MovementType]string
+	dateFormat    string // Excel number format
+
+	// PDF reports
+	countSheetTitle, valuationTitle, lowStockTitle string
+	counted, difference, shortfall, subtotal       string
+	database, page, noProducts                     string
+	timeLayout                                     string // Go layout of the printed date
+	decimalMark, thousandsMark                     string
+}
+
+// exportLanguages holds the labels for each interface language. Unknown
+// languages fall back to the default, Turkish.
+var exportLanguages = map[string]exportLabels{
+	"tr": {
+		productsSheet: "Ürünler", movementsSheet: "Hareketler", valuationSheet: "Değerleme",
+		code: "Kod", name: "Ürün Adı", category: "Kategori", unit: "Birim", criticalLimit: "Kritik Limit",
+		price: "Birim Fiyat", stock: "Stok", stockValue: "Stok Değeri",
+		date: "Tarih", movementType: "Hareket", quantity: "Miktar", location: "Depo", toLocation: "Hedef Depo",
+		user: "Kullanıcı", note: "Not", customer: "Alıcı",
+		productCount: "Ürün Sayısı", share: "Pay", total: "Toplam",
+		movementTypes: map[models.MovementType]string{
+			models.MovementTypeIn: "Giriş", models.MovementTypeOut: "Çıkış", models.MovementTypeTransfer: "Transfer",
+			models.MovementTypeAdjustment: "Sayım Düzeltmesi",
+		},
+		dateFormat: "dd.mm.yyyy hh:mm",
+
+		countSheetTitle: "Sayım Formu", valuationTitle: "Stok Değerleme Raporu", lowStockTitle: "Kritik Stok Listesi",
+		counted: "Sayılan", difference: "Fark", shortfall: "Eksik", subtotal: "Ara Toplam",
+		database: "Veritabanı", page: "Sayfa %d / {nb}", noProducts: "Listelenecek ürün yok.",
+		timeLayout: "02.01.2006 15:04", decimalMark: ",", thousandsMark: ".",
+	},
+	"en": {
+		productsSheet: "Products", movementsSheet: "Movements", valuationSheet: "Valuation",
+		code: "Code", name: "Name", category: "Category", unit: "Unit", criticalLimit: "Critical Limit",
+		price: "Unit Price", stock: "Stock", stockValue: "Stock Value",
+		date: "Date", movementType: "Type", quantity: "Quantity", location: "Location", toLocation: "To Location",
+		user: "User", note: "Note", customer: "Issued To",
+		productCount: "Products", share: "Share", total: "Total",
+		movementTypes: map[models.MovementType]string{
+			models.MovementTypeIn: "In", models.MovementTypeOut: "Out", models.MovementTypeTransfer: "Transfer",
+			models.MovementTypeAdjustment: "Adjustment",
+		},
+		dateFormat: "yyyy-mm-dd hh:mm",
+
+		countSheetTitle: "Stock Count Sheet", valuationTitle: "Stock Valuation Report", lowStockTitle: "Low Stock List",
+		counted: "Counted", difference: "Difference", shortfall: "Shortfall", subtotal: "Subtotal",
+		database: "Database", page: "Page %d / {nb}", noProducts: "No products to list.",
+		timeLayout: "2006-01-02 15:04", decimalMark: ".", thousandsMark: ",",
+	},
+}
+
+// defaultExportLanguage is used for languages without labels
+const defaultExportLanguage = "tr"
+
+// labelsFor returns the export labels of a language
+func labelsFor(language string) exportLabels {
+	if labels, ok := exportLanguages[language]; ok {
+		return labels
+	}
+	return exportLanguages[defaultExportLanguage]
+}
+
+// moneyFormat shows amounts in Turkish lira with two decimals
+const moneyFormat = `#,##0.00 [$₺-41F]`
+
+// ExportService writes Excel workbooks of products, movements and stock value
+type ExportService struct {
+	dbManager     *database.ConnectionManager
+	configManager *config.Manager
+}
+
+// NewExportService creates a new export service
+func NewExportService(dbManager *database.ConnectionManager, configManager *config.Manager) *ExportService {
+	return &ExportService{
+		dbManager:     dbManager,
+		configManager: configManager,
+	}
+}
+
+// exportStyles are the cell styles of a workbook
+type exportStyles struct {
+	header, date, money, moneyTotal, percent, count, countTotal int
+	quantity                                                    [models.QuantityDecimals + 1]int
+}
+
+// ExportWorkbook writes an .xlsx workbook to path with a products sheet, the
+// movements in the range of q and a stock valuation by category
+func (s *ExportService) ExportWorkbook(path string, q ExportQuery) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	labels := labelsFor(s.configManager.GetLanguage())
+
+	var products []models.Product
+	if err := db.Preload("Category").Order("code ASC").Find(&products).Error; err != nil {
+		return fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	decimals, err := loadUnitDecimals(db)
+	if err != nil {
+		return err
+	}
+
+	// Compare as instants: stored times carry their own offset
+	query := db.Preload("Product").Preload("Location").Preload("ToLocation").Preload("User").Preload("Customer")
+	if !q.From.IsZero() {
+		query = query.Where("julianday(date) >= julianday(?)", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("julianday(date) <= julianday(?)", q.To)
+	}
+	var movements []models.StockMovement
+	if err := query.Order("date ASC, id ASC").Find(&movements).Error; err != nil {
+		return fmt.Errorf("failed to fetch movements: %w", err)
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newExportStyles(f, labels)
+	if err != nil {
+		return fmt.Errorf("failed to create styles: %w", err)
+	}
+
+	if err := f.SetSheetName(f.GetSheetName(0), labels.productsSheet); err != nil {
+		return fmt.Errorf("failed to create sheet: %w", err)
+	}
+	for _, sheet := range []string{labels.movementsSheet, labels.valuationSheet} {
+		if _, err := f.NewSheet(sheet); err != nil {
+			return fmt.Errorf("failed to create sheet: %w", err)
+		}
+	}
+
+	if err := writeProductsSheet(f, labels, styles, products, decimals); err != nil {
+		return fmt.Errorf("failed to write products: %w", err)
+	}
+	if err := writeMovementsSheet(f, labels, styles, movements, decimals); err != nil {
+		return fmt.Errorf("failed to write movements: %w", err)
+	}
+	if err := writeValuationSheet(f, labels, styles, products); err != nil {
+		return fmt.Errorf("failed to write valuation: %w", err)
+	}
+
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("failed to save workbook: %w", err)
+	}
+
+	return nil
+}
+
+// newExportStyles registers the cell styles used by the sheets
+func newExportStyles(f *excelize.File, labels exportLabels) (*exportStyles, error) {
+	var (
+		styles exportStyles
+		err    error
+	)
+
+	bold := &excelize.Font{Bold: true}
+	if styles.header, err = f.NewStyle(&excelize.Style{
+		Font:      bold,
+		Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"E5E7EB"}},
+		Border:    []excelize.Border{{Type: "bottom", Color: "9CA3AF", Style: 1}},
+		Alignment: &excelize.Alignment{Vertical: "center"},
+	}); err != nil {
+		return nil, err
+	}
+
+	dateFormat, currencyFormat := labels.dateFormat, moneyFormat
+	if styles.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat}); err != nil {
+		return nil, err
+	}
+	if styles.money, err = f.NewStyle(&excelize.Style{CustomNumFmt: &currencyFormat}); err != nil {
+		return nil, err
+	}
+	if styles.moneyTotal, err = f.NewStyle(&excelize.Style{CustomNumFmt: &currencyFormat, Font: bold}); err != nil {
+		return nil, err
+	}
+	if styles.percent, err = f.NewStyle(&excelize.Style{NumFmt: 10}); err != nil {
+		return nil, err
+	}
+	if styles.count, err = f.NewStyle(&excelize.Style{NumFmt: 3}); err != nil {
+		return nil, err
+	}
+	if styles.countTotal, err = f.NewStyle(&excelize.Style{NumFmt: 3, Font: bold}); err != nil {
+		return nil, err
+	}
+
+	// One quantity style per unit precision so whole units show no decimals
+	for d := range styles.quantity {
+		format := "#,##0"
+		if d > 0 {
+			format += "." + strings.Repeat("0", d)
+		}
+		if styles.quantity[d], err = f.NewStyle(&excelize.Style{CustomNumFmt: &format}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &styles, nil
+}
+
+// quantityStyle returns the style for quantities of a unit
+func (s *exportStyles) quantityStyle(decimals map[string]int, unit string) int {
+	d := decimals[strings.ToLower(unit)]
+	if d < 0 || d >= len(s.quantity) {
+		d = 0
+	}
+	return s.quantity[d]
+}
+
+// writeProductsSheet lists every product with its category and stock value
+func writeProductsSheet(f *excelize.File, labels exportLabels, styles *exportStyles, products []models.Product, decimals map[string]int) error {
+	sheet := labels.productsSheet
+	headers := []string{labels.code, labels.name, labels.category, labels.unit, labels.criticalLimit, labels.price, labels.stock, labels.stockValue}
+	if err := writeHeader(f, sheet, styles, headers, []float64{14, 36, 20, 10, 14, 16, 14, 18}); err != nil {
+		return err
+	}
+
+	for i, product := range products {
+		row := i + 2
+		quantityStyle := styles.quantityStyle(decimals, product.Unit)
+		cells := []exportCell{
+			{value: product.Code},
+			{value: product.Name},
+			{value: product.Category.Name},
+			{value: product.Unit},
+			{value: product.CriticalLimit.Float64(), style: quantityStyle},
+			{value: product.Price.Float64(), style: styles.money},
+			{value: product.CurrentStock.Float64(), style: quantityStyle},
+			{value: product.CurrentStock.MulPrice(product.Price).Float64(), style: styles.money},
+		}
+		if err := writeRow(f, sheet, row, cells); err != nil {
+			return err
+		}
+	}
+
+	// Summed exactly here rather than as a formula, which viewers may not compute
+	var total models.Money
+	for _, product := range products {
+		total += product.CurrentStock.MulPrice(product.Price)
+	}
+	totalCells := make([]exportCell, len(headers))
+	totalCells[0] = exportCell{value: labels.total, style: styles.header}
+	totalCells[len(headers)-1] = exportCell{value: total.Float64(), style: styles.moneyTotal}
+	if err := writeRow(f, sheet, len(products)+2, totalCells); err != nil {
+		return err
+	}
+
+	return finishTable(f, sheet, len(headers), len(products)+1)
+}
+
+// writeMovementsSheet lists the movements oldest first
+func writeMovementsSheet(f *excelize.File, labels exportLabels, styles *exportStyles, movements []models.StockMovement, decimals map[string]int) error {
+	sheet := labels.movementsSheet
+	headers := []string{labels.date, labels.movementType, labels.code, labels.name, labels.quantity, labels.unit, labels.location, labels.toLocation, labels.customer, labels.user, labels.note}
+	if err := writeHeader(f, sheet, styles, headers, []float64{18, 12, 14, 36, 14, 10, 18, 18, 24, 16, 40}); err != nil {
+		return err
+	}
+
+	for i, movement := range movements {
+		movementType, ok := labels.movementTypes[movement.Type]
+		if !ok {
+			movementType = string(movement.Type)
+		}
+
+		cells := []exportCell{
+			{value: excelTime(movement.Date), style: styles.date},
+			{value: movementType},
+			{value: movement.Product.Code},
+			{value: movement.Product.Name},
+			{value: movement.Quantity.Float64(), style: styles.quantityStyle(decimals, movement.Product.Unit)},
+			{value: movement.Product.Unit},
+			{value: movement.Location.Name},
+			{value: ""},
+			{value: ""},
+			{value: ""},
+			{value: movement.Note},
+		}
+		if movement.ToLocation != nil {
+			cells[7].value = movement.ToLocation.Name
+		}
+		if movement.Customer != nil {
+			cells[8].value = movement.Customer.Name
+		}
+		if movement.User != nil {
+			cells[9].value = movement.User.Username
+		}
+		if err := writeRow(f, sheet, i+2, cells); err != nil {
+			return err
+		}
+	}
+
+	return finishTable(f, sheet, len(headers), len(movements)+1)
+}
+
+// writeValuationSheet sums the stock value of each category
+func writeValuationSheet(f *excelize.File, labels exportLabels, styles *exportStyles, products []models.Product) error {
+	type categoryValue struct {
+		name     string
+		products int
+		value    models.Money
+	}
+
+	byCategory := make(map[uint]*categoryValue)
+	var total models.Money
+	for _, product := range products {
+		category, ok := byCategory[product.CategoryID]
+		if !ok {
+			category = &categoryValue{name: product.Category.Name}
+			byCategory[product.CategoryID] = category
+		}
+		value := product.CurrentStock.MulPrice(product.Price)
+		category.products++
+		category.value += value
+		total += value
+	}
+
+	categories := make([]*categoryValue, 0, len(byCategory))
+	for _, category := range byCategory {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].value != categories[j].value {
+			return categories[i].value > categories[j].value
+		}
+		return categories[i].name < categories[j].name
+	})
+
+	sheet := labels.valuationSheet
+	headers := []string{labels.category, labels.productCount, labels.stockValue, labels.share}
+	if err := writeHeader(f, sheet, styles, headers, []float64{24, 14, 20, 10}); err != nil {
+		return err
+	}
+
+	for i, category := range categories {
+		share := 0.0
+		if total != 0 {
+			share = float64(category.value) / float64(total)
+		}
+		cells := []exportCell{
+			{value: category.name},
+			{value: category.products, style: styles.count},
+			{value: category.value.Float64(), style: styles.money},
+			{value: share, style: styles.percent},
+		}
+		if err := writeRow(f, sheet, i+2, cells); err != nil {
+			return err
+		}
+	}
+
+	// Totals are computed here so they match the products sheet exactly
+	totalCells := []exportCell{
+		{value: labels.total, style: styles.header},
+		{value: len(products), style: styles.countTotal},
+		{value: total.Float64(), style: styles.moneyTotal},
+	}
+	if err := writeRow(f, sheet, len(categories)+2, totalCells); err != nil {
+		return err
+	}
+
+	return finishTable(f, sheet, len(headers), len(categories)+1)
+}
+
+// exportCell is a typed cell value with an optional style
+type exportCell struct {
+	value interface{}
+	style int
+}
+
+// writeHeader writes the header row and sets the column widths
+func writeHeader(f *excelize.File, sheet string, styles *exportStyles, headers []string, widths []float64) error {
+	cells := make([]exportCell, len(headers))
+	for i, header := range headers {
+		cells[i] = exportCell{value: header, style: styles.header}
+	}
+	if err := writeRow(f, sheet, 1, cells); err != nil {
+		return err
+	}
+
+	for i, width := range widths {
+		column, err := excelize.ColumnNumberToName(i + 1)
+		if err != nil {
+			return err
+		}
+		if err := f.SetColWidth(sheet, column, column, width); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRow writes cells from column A of row
+func writeRow(f *excelize.File, sheet string, row int, cells []exportCell) error {
+	for i, cell := range cells {
+		name, err := excelize.CoordinatesToCellName(i+1, row)
+		if err != nil {
+			return err
+		}
+		if err := f.SetCellValue(sheet, name, cell.value); err != nil {
+			return err
+		}
+		if cell.style != 0 {
+			if err := f.SetCellStyle(sheet, name, name, cell.style); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// finishTable freezes the header row and adds a filter over the data rows
+func finishTable(f *excelize.File, sheet string, columns, lastRow int) error {
+	if err := f.SetPanes(sheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+
+	lastCell, err := excelize.CoordinatesToCellName(columns, lastRow)
+	if err != nil {
+		return err
+	}
+	return f.AutoFilter(sheet, "A1:"+lastCell, nil)
+}
+
+// excelTime returns t as a local wall-clock time. Excel dates have no time
+// zone, and excelize converts times as UTC.
+func excelTime(t time.Time) time.Time {
+	local := t.Local()
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+}
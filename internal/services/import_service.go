@@ -0,0 +1,466 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// Fields of a product CSV row, used as keys of ProductImportOptions.Columns
+const (
+	ImportFieldCode          = "code"
+	ImportFieldName          = "name"
+	ImportFieldCategory      = "category"
+	ImportFieldUnit          = "unit"
+	ImportFieldCriticalLimit = "critical_limit"
+	ImportFieldPrice         = "price"
+	ImportFieldOpeningStock  = "opening_stock"
+)
+
+// importFields lists the fields with the header names recognized when no
+// column mapping is given. Header names compare case-insensitively.
+var importFields = []struct {
+	name     string
+	required bool
+	headers  []string
+}{
+	{ImportFieldCode, true, []string{"code", "kod", "ürün kodu", "stok kodu"}},
+	{ImportFieldName, true, []string{"name", "ad", "ürün adı", "stok adı"}},
+	{ImportFieldCategory, true, []string{"category", "kategori"}},
+	{ImportFieldUnit, true, []string{"unit", "birim"}},
+	{ImportFieldCriticalLimit, false, []string{"critical limit", "critical_limit", "kritik limit", "kritik stok"}},
+	{ImportFieldPrice, false, []string{"price", "fiyat", "birim fiyat"}},
+	{ImportFieldOpeningStock, false, []string{"opening stock", "opening_stock", "açılış stoğu", "stok", "miktar"}},
+}
+
+// ProductImportOptions describes the layout of a product CSV file
+type ProductImportOptions struct {
+	Delimiter    string            `json:"delimiter"`     // One character; defaults to ";" with DecimalComma, else ","
+	DecimalComma bool              `json:"decimal_comma"` // Numbers are written like "1.234,5"
+	NoHeader     bool              `json:"no_header"`     // The first line is data; map columns by number
+	Columns      map[string]string `json:"columns"`       // Field to header name or 1-based column number
+	LocationID   uint              `json:"location_id"`   // Where opening stock goes; 0 means the default location
+	DryRun       bool              `json:"dry_run"`       // Validate every row but write nothing
+}
+
+// ImportRowResult is the outcome of one CSV row
+type ImportRowResult struct {
+	Line            int             `json:"line"` // Line in the file where the row starts
+	Code            string          `json:"code"`
+	Name            string          `json:"name"`
+	Category        string          `json:"category"`
+	OpeningStock    models.Quantity `json:"opening_stock"`
+	NewCategory     bool            `json:"new_category"` // The category is created by the import
+	Errors          []string        `json:"errors"`
+	ProductID       uint            `json:"product_id"` // Zero in a dry run
+	OpeningMovement uint            `json:"opening_movement_id"`
+}
+
+// ImportReport summarizes an import or dry run. Nothing is written unless
+// every row is valid; Imported tells whether the rows were saved.
+type ImportReport struct {
+	DryRun            bool              `json:"dry_run"`
+	Imported          bool              `json:"imported"`
+	TotalRows         int               `json:"total_rows"`
+	ValidRows         int               `json:"valid_rows"`
+	InvalidRows       int               `json:"invalid_rows"`
+	CategoriesCreated []string          `json:"categories_created"` // Or that would be, when not imported
+	Rows              []ImportRowResult `json:"rows"`
+}
+
+// ImportService imports products from CSV files
+type ImportService struct {
+	dbManager  *database.ConnectionManager
+	products   *ProductService
+	categories *CategoryService
+	movements  *MovementService
+}
+
+// NewImportService creates a new import service
+func NewImportService(dbManager *database.ConnectionManager, products *ProductService, categories *CategoryService, movements *MovementService) *ImportService {
+	return &ImportService{
+		dbManager:  dbManager,
+		products:   products,
+		categories: categories,
+		movements:  movements,
+	}
+}
+
+// errImportRolledBack ends the import transaction without saving
+var errImportRolledBack = errors.New("import rolled back")
+
+// importRow is a parsed CSV row waiting to be written
+type importRow struct {
+	result  *ImportRowResult
+	product ProductDTO
+}
+
+// ImportProducts reads products with their opening stock from a CSV file.
+// Missing categories are created and opening stock becomes an IN movement.
+// All rows are written in one transaction, and only if every row is valid;
+// a dry run checks every row the same way and rolls back.
+func (s *ImportService) ImportProducts(r io.Reader, options ProductImportOptions) (*ImportReport, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	rows, err := readImportRows(r, options)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{
+		DryRun:            options.DryRun,
+		TotalRows:         len(rows),
+		CategoriesCreated: []string{},
+		Rows:              make([]ImportRowResult, len(rows)),
+	}
+	for i := range rows {
+		report.Rows[i] = *rows[i].result
+		rows[i].result = &report.Rows[i]
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.Category
+		if err := tx.Find(&existing).Error; err != nil {
+			return fmt.Errorf("failed to fetch categories: %w", err)
+		}
+		categoryIDs := make(map[string]uint, len(existing))
+		for _, category := range existing {
+			categoryIDs[strings.ToLower(category.Name)] = category.ID
+		}
+
+		for _, row := range rows {
+			if len(row.result.Errors) > 0 {
+				continue
+			}
+
+			// Each row is a savepoint so a failed row leaves nothing behind
+			var created *models.Category
+			if err := tx.Transaction(func(rowTx *gorm.DB) error {
+				var err error
+				created, err = s.writeRow(rowTx, row, categoryIDs, options.LocationID)
+				return err
+			}); err != nil {
+				row.result.Errors = append(row.result.Errors, err.Error())
+				row.result.NewCategory = false
+				row.result.ProductID, row.result.OpeningMovement = 0, 0
+				continue
+			}
+			if created != nil {
+				categoryIDs[strings.ToLower(created.Name)] = created.ID
+				report.CategoriesCreated = append(report.CategoriesCreated, created.Name)
+			}
+		}
+
+		for _, row := range rows {
+			if len(row.result.Errors) > 0 {
+				report.InvalidRows++
+			}
+		}
+		report.ValidRows = report.TotalRows - report.InvalidRows
+
+		if options.DryRun || report.InvalidRows > 0 {
+			return errImportRolledBack
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRolledBack) {
+		return nil, err
+	}
+
+	report.Imported = err == nil
+	if !report.Imported {
+		for i := range report.Rows {
+			report.Rows[i].ProductID, report.Rows[i].OpeningMovement = 0, 0
+		}
+	}
+
+	return report, nil
+}
+
+// writeRow creates the product of one row, its category when missing and
+// its opening stock movement. It returns the category it created, if any.
+func (s *ImportService) writeRow(tx *gorm.DB, row importRow, categoryIDs map[string]uint, locationID uint) (*models.Category, error) {
+	var created *models.Category
+	categoryID, ok := categoryIDs[strings.ToLower(row.result.Category)]
+	if !ok {
+		var err error
+		if created, err = s.categories.insert(tx, row.result.Category, "", defaultCategoryColor); err != nil {
+			return nil, err
+		}
+		categoryID = created.ID
+		row.result.NewCategory = true
+	}
+
+	dto := row.product
+	dto.CategoryID = categoryID
+	product, err := s.products.insert(tx, dto)
+	if err != nil {
+		return nil, err
+	}
+	row.result.ProductID = product.ID
+
+	if row.result.OpeningStock > 0 {
+		movement, err := s.movements.insert(tx, MovementDTO{
+			ProductID:  product.ID,
+			LocationID: locationID,
+			Type:       string(models.MovementTypeIn),
+			Quantity:   row.result.OpeningStock,
+			Note:       "Opening stock (CSV import)",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("opening stock: %w", err)
+		}
+		row.result.OpeningMovement = movement.ID
+	}
+
+	return created, nil
+}
+
+// readImportRows parses the CSV file into rows, recording the errors that can
+// be found without the database: missing values, bad numbers, duplicate codes
+func readImportRows(r io.Reader, options ProductImportOptions) ([]importRow, error) {
+	delimiter, err := options.delimiter()
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var (
+		columns map[string]int
+		rows    []importRow
+		seen    = make(map[string]int)
+	)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if columns == nil {
+			if len(record) > 0 {
+				record[0] = strings.TrimPrefix(record[0], "\ufeff")
+			}
+			if columns, err = options.mapColumns(record); err != nil {
+				return nil, err
+			}
+			if !options.NoHeader {
+				continue
+			}
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+
+		row := parseImportRow(record, columns, options.DecimalComma)
+		row.result.Line = line
+		if first, ok := seen[row.result.Code]; ok && row.result.Code != "" {
+			row.result.Errors = append(row.result.Errors, fmt.Sprintf("duplicate code, first used on line %d", first))
+		} else {
+			seen[row.result.Code] = line
+		}
+		rows = append(rows, row)
+	}
+
+	if columns == nil {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	return rows, nil
+}
+
+// parseImportRow reads the fields of one record
+func parseImportRow(record []string, columns map[string]int, decimalComma bool) importRow {
+	result := &ImportRowResult{Errors: []string{}}
+	field := func(name string) string {
+		index, ok := columns[name]
+		if !ok || index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	result.Code = field(ImportFieldCode)
+	result.Name = field(ImportFieldName)
+	result.Category = field(ImportFieldCategory)
+	product := ProductDTO{Code: result.Code, Name: result.Name, Unit: field(ImportFieldUnit)}
+
+	for _, required := range []struct{ name, value string }{
+		{"code", result.Code}, {"name", result.Name}, {"category", result.Category}, {"unit", product.Unit},
+	} {
+		if required.value == "" {
+			result.Errors = append(result.Errors, required.name+" is required")
+		}
+	}
+
+	if text := field(ImportFieldPrice); text != "" {
+		price, err := parseImportNumber(text, decimalComma, models.ParseMoney)
+		if err != nil {
+			result.Errors = append(result.Errors, "price: "+err.Error())
+		} else if price < 0 {
+			result.Errors = append(result.Errors, "price cannot be negative")
+		}
+		product.Price = price
+	}
+
+	if text := field(ImportFieldCriticalLimit); text != "" {
+		limit, err := parseImportNumber(text, decimalComma, models.ParseQuantity)
+		if err != nil {
+			result.Errors = append(result.Errors, "critical limit: "+err.Error())
+		} else if limit < 0 {
+			result.Errors = append(result.Errors, "critical limit cannot be negative")
+		}
+		product.CriticalLimit = limit
+	}
+
+	if text := field(ImportFieldOpeningStock); text != "" {
+		stock, err := parseImportNumber(text, decimalComma, models.ParseQuantity)
+		if err != nil {
+			result.Errors = append(result.Errors, "opening stock: "+err.Error())
+		} else if stock < 0 {
+			result.Errors = append(result.Errors, "opening stock cannot be negative")
+		}
+		result.OpeningStock = stock
+	}
+
+	return importRow{result: result, product: product}
+}
+
+// parseImportNumber parses a number as written in the file
+func parseImportNumber[T models.Quantity | models.Money](text string, decimalComma bool, parse func(string) (T, error)) (T, error) {
+	normalized, err := normalizeDecimal(text, decimalComma)
+	if err != nil {
+		return 0, err
+	}
+	return parse(normalized)
+}
+
+// delimiter returns the field separator to use
+func (o ProductImportOptions) delimiter() (rune, error) {
+	if o.Delimiter == "" {
+		if o.DecimalComma {
+			return ';', nil
+		}
+		return ',', nil
+	}
+	if o.Delimiter == `\t` {
+		return '\t', nil
+	}
+
+	delimiter, size := utf8.DecodeRuneInString(o.Delimiter)
+	if size != len(o.Delimiter) || delimiter == '"' || delimiter == '\r' || delimiter == '\n' {
+		return 0, fmt.Errorf("invalid delimiter '%s'", o.Delimiter)
+	}
+	return delimiter, nil
+}
+
+// mapColumns finds the column index of each field from the mapping or the
+// header line. Without a header only numbered columns can be mapped.
+func (o ProductImportOptions) mapColumns(header []string) (map[string]int, error) {
+	for field := range o.Columns {
+		if !isImportField(field) {
+			return nil, fmt.Errorf("unknown import field '%s'", field)
+		}
+	}
+
+	columns := make(map[string]int)
+	for _, field := range importFields {
+		candidates := field.headers
+		if mapped := strings.TrimSpace(o.Columns[field.name]); mapped != "" {
+			if number, err := strconv.Atoi(mapped); err == nil {
+				if number < 1 || number > len(header) {
+					return nil, fmt.Errorf("column %d for %s is outside the file's %d columns", number, field.name, len(header))
+				}
+				columns[field.name] = number - 1
+				continue
+			}
+			candidates = []string{mapped}
+		}
+
+		if !o.NoHeader {
+			if index := findHeader(header, candidates); index >= 0 {
+				columns[field.name] = index
+				continue
+			}
+		}
+		if field.required {
+			return nil, fmt.Errorf("no column for %s; map it to a header name or column number", field.name)
+		}
+	}
+
+	return columns, nil
+}
+
+// findHeader returns the index of the first header matching a candidate name
+func findHeader(header, candidates []string) int {
+	for _, candidate := range candidates {
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), candidate) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isImportField reports whether name is one of the import fields
+func isImportField(name string) bool {
+	for _, field := range importFields {
+		if field.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDecimal rewrites a number to the "1234.5" form the parsers expect.
+// With decimalComma the comma is the decimal mark and dots may only group
+// thousands, so "1.234,5" is read as 1234.5; a lone "12.5" is refused rather
+// than guessed.
+func normalizeDecimal(text string, decimalComma bool) (string, error) {
+	text = strings.ReplaceAll(text, " ", "")
+	if !decimalComma {
+		return text, nil
+	}
+
+	whole, frac, hasFrac := strings.Cut(text, ",")
+	if groups := strings.Split(whole, "."); len(groups) > 1 {
+		for _, group := range groups[1:] {
+			if len(group) != 3 {
+				return "", fmt.Errorf("invalid number '%s': use a comma for decimals", text)
+			}
+		}
+		whole = strings.Join(groups, "")
+	}
+	if hasFrac {
+		return whole + "." + frac, nil
+	}
+	return whole, nil
+}
+
+// isBlankRecord reports whether every field of a record is empty
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+)
+
+func TestNormalizeDecimal(t *testing.T) {
+	tests := []struct {
+		in           string
+		decimalComma bool
+		want         string
+		wantErr      bool
+	}{
+		{in: "12.5", want: "12.5"},
+		{in: "1 234.5", want: "1234.5"},
+		{in: "1,5", want: "1,5"}, // Left for the parser to refuse
+		{in: "1.234,5", decimalComma: true, want: "1234.5"},
+		{in: "1.234.567,89", decimalComma: true, want: "1234567.89"},
+		{in: "1 234,5", decimalComma: true, want: "1234.5"},
+		{in: "-1.234,5", decimalComma: true, want: "-1234.5"},
+		{in: "12,5", decimalComma: true, want: "12.5"},
+		{in: ",5", decimalComma: true, want: ".5"},
+		{in: "12", decimalComma: true, want: "12"},
+		{in: "1.234", decimalComma: true, want: "1234"}, // A dot only groups thousands
+		{in: "12.5", decimalComma: true, wantErr: true},
+		{in: "1.2345", decimalComma: true, wantErr: true},
+		{in: "1.23,4", decimalComma: true, wantErr: true},
+		{in: "1..234", decimalComma: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeDecimal(tt.in, tt.decimalComma)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("normalizeDecimal(%q, %v) = %q, want error", tt.in, tt.decimalComma, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("normalizeDecimal(%q, %v) error: %v", tt.in, tt.decimalComma, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeDecimal(%q, %v) = %q, want %q", tt.in, tt.decimalComma, got, tt.want)
+		}
+	}
+}
+
+func TestMapColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		options ProductImportOptions
+		want    map[string]int
+		wantErr bool
+	}{
+		{
+			name:   "English header",
+			header: []string{"Code", "Name", "Category", "Unit", "Price"},
+			want:   map[string]int{"code": 0, "name": 1, "category": 2, "unit": 3, "price": 4},
+		},
+		{
+			name:   "Turkish header in another order",
+			header: []string{"Birim", "STOK KODU", "Stok Adı", "Kategori", "Miktar", "Kritik Stok"},
+			want:   map[string]int{"unit": 0, "code": 1, "name": 2, "category": 3, "opening_stock": 4, "critical_limit": 5},
+		},
+		{
+			name:   "header with spaces",
+			header: []string{" code ", "name", "category", "unit"},
+			want:   map[string]int{"code": 0, "name": 1, "category": 2, "unit": 3},
+		},
+		{
+			name:    "mapped by header name",
+			header:  []string{"SKU", "Name", "Group", "Unit"},
+			options: ProductImportOptions{Columns: map[string]string{"code": "sku", "category": "Group"}},
+			want:    map[string]int{"code": 0, "name": 1, "category": 2, "unit": 3},
+		},
+		{
+			name:    "mapped by number without header",
+			header:  []string{"A-1", "Bolt", "General", "adet", "5"},
+			options: ProductImportOptions{NoHeader: true, Columns: map[string]string{"code": "1", "name": "2", "category": "3", "unit": "4", "opening_stock": "5"}},
+			want:    map[string]int{"code": 0, "name": 1, "category": 2, "unit": 3, "opening_stock": 4},
+		},
+		{
+			name:    "no header and no mapping",
+			header:  []string{"code", "name", "category", "unit"},
+			options: ProductImportOptions{NoHeader: true},
+			wantErr: true,
+		},
+		{
+			name:    "column number outside the file",
+			header:  []string{"code", "name", "category", "unit"},
+			options: ProductImportOptions{Columns: map[string]string{"price": "5"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			header:  []string{"code", "name", "category", "unit"},
+			options: ProductImportOptions{Columns: map[string]string{"colour": "1"}},
+			wantErr: true,
+		},
+		{
+			name:    "required column missing",
+			header:  []string{"code", "name", "unit"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		got, err := tt.options.mapColumns(tt.header)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: mapColumns = %v, want error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: mapColumns error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mapColumns = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadImportRows(t *testing.T) {
+	csv := "\ufeffKod;Ad;Kategori;Birim;Miktar\n" +
+		"A-1;Bolt;Genel;adet;1.234,5\n" +
+		"A-1;Nut;Genel;adet;2\n" +
+		";;;;\n" +
+		"B-1;Washer;Genel;adet;12.5\n"
+
+	rows, err := readImportRows(strings.NewReader(csv), ProductImportOptions{DecimalComma: true})
+	if err != nil {
+		t.Fatalf("read rows: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("rows = %d, want 3", len(rows))
+	}
+
+	if first := rows[0].result; first.Code != "A-1" || first.Line != 2 || first.OpeningStock != 1234500 || len(first.Errors) != 0 {
+		t.Errorf("first row = %+v, want A-1 on line 2 with 1234.5 in stock", *first)
+	}
+	if errs := rows[1].result.Errors; len(errs) != 1 || errs[0] != "duplicate code, first used on line 2" {
+		t.Errorf("duplicate row errors = %v", errs)
+	}
+	if errs := rows[2].result.Errors; len(errs) != 1 || !strings.HasPrefix(errs[0], "opening stock:") {
+		t.Errorf("decimal point row errors = %v", errs)
+	}
+}
+
+func TestImportProductsWritesNothingUnlessEveryRowIsValid(t *testing.T) {
+	dbManager := database.GetConnectionManager()
+	if err := dbManager.Connect(filepath.Join(t.TempDir(), "import.db")); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer dbManager.Close()
+
+	auditService := NewAuditService(dbManager)
+	productService := NewProductService(dbManager, auditService)
+	categoryService := NewCategoryService(dbManager, auditService)
+	movementService := NewMovementService(dbManager, auditService, NewAuthService(dbManager, auditService))
+	importService := NewImportService(dbManager, productService, categoryService, movementService)
+
+	valid := "code,name,category,unit,opening stock\n" +
+		"P-1,Bolt,Fasteners,adet,10\n" +
+		"P-2,Nut,Fasteners,adet,5\n"
+	oneInvalid := valid + "P-3,Washer,Fasteners,adet,-1\n"
+
+	countRows := func() (products, categories, movements int64) {
+		db := dbManager.GetDB()
+		db.Model(&models.Product{}).Count(&products)
+		db.Model(&models.Category{}).Where("name = ?", "Fasteners").Count(&categories)
+		db.Model(&models.StockMovement{}).Count(&movements)
+		return
+	}
+
+	report, err := importService.ImportProducts(strings.NewReader(valid), ProductImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if report.Imported || report.ValidRows != 2 || report.InvalidRows != 0 {
+		t.Errorf("dry run report = imported %v, %d valid, %d invalid; want not imported, 2 valid", report.Imported, report.ValidRows, report.InvalidRows)
+	}
+	if len(report.CategoriesCreated) != 1 || report.CategoriesCreated[0] != "Fasteners" {
+		t.Errorf("dry run categories = %v, want [Fasteners]", report.CategoriesCreated)
+	}
+	for _, row := range report.Rows {
+		if row.ProductID != 0 || row.OpeningMovement != 0 {
+			t.Errorf("dry run row %s has IDs %d/%d, want none", row.Code, row.ProductID, row.OpeningMovement)
+		}
+	}
+	if products, categories, movements := countRows(); products+categories+movements != 0 {
+		t.Errorf("dry run wrote %d products, %d categories, %d movements", products, categories, movements)
+	}
+
+	report, err = importService.ImportProducts(strings.NewReader(oneInvalid), ProductImportOptions{})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if report.Imported || report.ValidRows != 2 || report.InvalidRows != 1 {
+		t.Errorf("import report = imported %v, %d valid, %d invalid; want not imported, 2 valid, 1 invalid", report.Imported, report.ValidRows, report.InvalidRows)
+	}
+	if products, categories, movements := countRows(); products+categories+movements != 0 {
+		t.Errorf("rolled back import wrote %d products, %d categories, %d movements", products, categories, movements)
+	}
+
+	report, err = importService.ImportProducts(strings.NewReader(valid), ProductImportOptions{})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !report.Imported {
+		t.Fatalf("valid import not imported: %+v", report.Rows)
+	}
+	if products, categories, movements := countRows(); products != 2 || categories != 1 || movements != 2 {
+		t.Errorf("import wrote %d products, %d categories, %d movements; want 2, 1, 2", products, categories, movements)
+	}
+}
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"stoktakip/internal/config"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/ean"
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Label sheet formats
+const (
+	LabelFormatPDF = "pdf"
+	LabelFormatPNG = "png"
+)
+
+// Label rendering limits
+const (
+	labelPNGDPI     = 300 // Resolution of PNG sheets, enough for scanners
+	labelQuietZone  = 10  // Blank modules on each side of the bars
+	maxLabelCopies  = 1000
+	maxLabelsPerJob = 10000
+)
+
+// LabelItem asks for Copies labels of a product. An empty Barcode prints the
+// product's first barcode, or its code as Code128 when it has none.
+type LabelItem struct {
+	ProductID uint   `json:"product_id"`
+	Barcode   string `json:"barcode"`
+	Copies    int    `json:"copies"`
+}
+
+// LabelService renders barcode labels on sheets laid out as configured
+type LabelService struct {
+	dbManager     *database.ConnectionManager
+	configManager *config.Manager
+}
+
+// NewLabelService creates a new label service
+func NewLabelService(dbManager *database.ConnectionManager, configManager *config.Manager) *LabelService {
+	return &LabelService{
+		dbManager:     dbManager,
+		configManager: configManager,
+	}
+}
+
+// IsValidLabelFormat reports whether format names a label sheet format
+func IsValidLabelFormat(format string) bool {
+	return format == LabelFormatPDF || format == LabelFormatPNG
+}
+
+// label is one printed label
+type label struct {
+	name    string
+	price   string
+	text    string // Digits or text printed under the bars
+	modules []bool // Bars, one entry per module, true for black
+}
+
+// WritePDF renders the labels as a PDF with one page per sheet
+func (s *LabelService) WritePDF(w io.Writer, items []LabelItem) error {
+	labels, err := s.prepare(items)
+	if err != nil {
+		return err
+	}
+
+	layout := s.configManager.GetLabelLayout()
+	if err := layout.Validate(); err != nil {
+		return err
+	}
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddUTF8FontFromBytes(reportFont, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(reportFont, "B", gobold.TTF)
+	pdf.SetFillColor(0, 0, 0)
+
+	canvas := &pdfLabelCanvas{pdf: pdf}
+	perSheet := layout.Columns * layout.Rows
+	for start := 0; start < len(labels); start += perSheet {
+		pdf.AddPageFormat("P", fpdf.SizeType{Wd: layout.PageWidth, Ht: layout.PageHeight})
+		drawSheet(canvas, layout, labels[start:min(start+perSheet, len(labels))])
+	}
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("failed to write labels: %w", err)
+	}
+	return nil
+}
+
+// RenderPNG renders the labels as PNG images, one per sheet
+func (s *LabelService) RenderPNG(items []LabelItem) ([][]byte, error) {
+	labels, err := s.prepare(items)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := s.configManager.GetLabelLayout()
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	canvas, err := newImageLabelCanvas(layout, labelPNGDPI)
+	if err != nil {
+		return nil, err
+	}
+
+	perSheet := layout.Columns * layout.Rows
+	var sheets [][]byte
+	for start := 0; start < len(labels); start += perSheet {
+		canvas.clear()
+		drawSheet(canvas, layout, labels[start:min(start+perSheet, len(labels))])
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, canvas.img); err != nil {
+			return nil, fmt.Errorf("failed to write labels: %w", err)
+		}
+		sheets = append(sheets, buf.Bytes())
+	}
+	return sheets, nil
+}
+
+// prepare loads the products and encodes the barcodes of the labels
+func (s *LabelService) prepare(items []LabelItem) ([]label, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no labels to print")
+	}
+
+	total := 0
+	for _, item := range items {
+		if item.Copies < 1 || item.Copies > maxLabelCopies {
+			return nil, fmt.Errorf("label copies must be between 1 and %d", maxLabelCopies)
+		}
+		total += item.Copies
+	}
+	if total > maxLabelsPerJob {
+		return nil, fmt.Errorf("cannot print more than %d labels at once", maxLabelsPerJob)
+	}
+
+	money := labelsFor(s.configManager.GetLanguage())
+	labels := make([]label, 0, total)
+	for _, item := range items {
+		var product models.Product
+		if err := db.First(&product, item.ProductID).Error; err != nil {
+			return nil, fmt.Errorf("product not found: %w", err)
+		}
+
+		var code models.ProductBarcode
+		query := db.Where("product_id = ?", product.ID)
+		if item.Barcode != "" {
+			query = query.Where("barcode = ?", item.Barcode)
+		}
+		if err := query.Order("id").Limit(1).Find(&code).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch barcodes: %w", err)
+		}
+		if code.ID == 0 {
+			if item.Barcode != "" {
+				return nil, fmt.Errorf("barcode '%s' not found for product '%s'", item.Barcode, product.Name)
+			}
+			code = models.ProductBarcode{Barcode: product.Code, Symbology: models.BarcodeCode128}
+		}
+
+		modules, err := barcodeModules(code.Barcode, code.Symbology)
+		if err != nil {
+			return nil, fmt.Errorf("cannot print barcode of product '%s': %w", product.Name, err)
+		}
+
+		l := label{
+			name:    product.Name,
+			price:   money.formatMoney(product.Price) + " TL", // The Go fonts have no ₺ glyph
+			text:    code.Barcode,
+			modules: modules,
+		}
+		for i := 0; i < item.Copies; i++ {
+			labels = append(labels, l)
+		}
+	}
+	return labels, nil
+}
+
+// barcodeModules encodes code and returns its bars, one entry per module
+func barcodeModules(code string, symbology models.BarcodeSymbology) ([]bool, error) {
+	var encoded barcode.Barcode
+	var err error
+	switch symbology {
+	case models.BarcodeEAN13, models.BarcodeEAN8:
+		encoded, err = ean.Encode(code)
+	case models.BarcodeUPCA:
+		// UPC-A is EAN-13 with a leading zero
+		encoded, err = ean.Encode("0" + code)
+	case models.BarcodeCode128:
+		encoded, err = code128.Encode(code)
+	default:
+		err = fmt.Errorf("invalid barcode symbology: %s", symbology)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := encoded.Bounds()
+	modules := make([]bool, bounds.Dx())
+	for x := range modules {
+		r, _, _, _ := encoded.At(bounds.Min.X+x, bounds.Min.Y).RGBA()
+		modules[x] = r == 0
+	}
+	return modules, nil
+}
+
+// labelCanvas is a page labels are drawn on, measured in millimetres
+type labelCanvas interface {
+	fillRect(x, y, w, h float64)
+	textWidth(text string, size float64, bold bool) float64
+	// text draws text with its baseline at y; size is the font height in mm
+	text(x, y float64, text string, size float64, bold bool)
+}
+
+// drawSheet draws labels on the cells of one sheet, left to right, then
+// top to bottom
+func drawSheet(canvas labelCanvas, layout config.LabelLayout, labels []label) {
+	for i, l := range labels {
+		column, row := i%layout.Columns, i/layout.Columns
+		x := layout.MarginLeft + float64(column)*(layout.LabelWidth+layout.GapX)
+		y := layout.MarginTop + float64(row)*(layout.LabelHeight+layout.GapY)
+		drawLabel(canvas, x, y, layout.LabelWidth, layout.LabelHeight, l)
+	}
+}
+
+// drawLabel draws the product name on top, the bars with their text below
+// them and the price at the bottom of a label cell
+func drawLabel(canvas labelCanvas, x, y, width, height float64, l label) {
+	pad := math.Min(2, height/10)
+	line := math.Min(3.5, height/9) // Text line height
+	size := line * 0.8
+	inner := width - 2*pad
+
+	name := fitText(canvas, l.name, inner, size, false)
+	canvas.text(x+pad+(inner-canvas.textWidth(name, size, false))/2, y+pad+size, name, size, false)
+
+	// Bars fill the width between the quiet zones
+	barTop := y + pad + line + pad/2
+	barHeight := height - 2*pad - 3*line - pad
+	module := inner / float64(len(l.modules)+2*labelQuietZone)
+	barX := x + pad + labelQuietZone*module
+	for i := 0; i < len(l.modules); {
+		if !l.modules[i] {
+			i++
+			continue
+		}
+		run := i
+		for run < len(l.modules) && l.modules[run] {
+			run++
+		}
+		canvas.fillRect(barX+float64(i)*module, barTop, float64(run-i)*module, barHeight)
+		i = run
+	}
+
+	textSize := size * 0.9
+	text := fitText(canvas, l.text, inner, textSize, false)
+	canvas.text(x+pad+(inner-canvas.textWidth(text, textSize, false))/2, barTop+barHeight+line, text, textSize, false)
+
+	priceSize := size * 1.1
+	canvas.text(x+pad+(inner-canvas.textWidth(l.price, priceSize, true))/2, y+height-pad, l.price, priceSize, true)
+}
+
+// fitText shortens text with an ellipsis to fit width
+func fitText(canvas labelCanvas, text string, width, size float64, bold bool) string {
+	if canvas.textWidth(text, size, bold) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && canvas.textWidth(string(runes)+"…", size, bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// pdfLabelCanvas draws labels on the current page of a PDF
+type pdfLabelCanvas struct {
+	pdf *fpdf.Fpdf
+}
+
+func (c *pdfLabelCanvas) fillRect(x, y, w, h float64) {
+	c.pdf.Rect(x, y, w, h, "F")
+}
+
+func (c *pdfLabelCanvas) setFont(size float64, bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	c.pdf.SetFont(reportFont, style, size*72/25.4)
+}
+
+func (c *pdfLabelCanvas) textWidth(text string, size float64, bold bool) float64 {
+	c.setFont(size, bold)
+	return c.pdf.GetStringWidth(text)
+}
+
+func (c *pdfLabelCanvas) text(x, y float64, text string, size float64, bold bool) {
+	c.setFont(size, bold)
+	c.pdf.Text(x, y, text)
+}
+
+// imageLabelCanvas draws labels on a sheet-sized image
+type imageLabelCanvas struct {
+	img     *image.Gray
+	scale   float64 // Pixels per millimetre
+	regular *opentype.Font
+	bold    *opentype.Font
+	faces   map[labelFaceKey]font.Face
+}
+
+// labelFaceKey identifies a font face by size and weight
+type labelFaceKey struct {
+	size float64
+	bold bool
+}
+
+// newImageLabelCanvas creates a canvas for sheets of layout at dpi
+func newImageLabelCanvas(layout config.LabelLayout, dpi float64) (*imageLabelCanvas, error) {
+	regular, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label font: %w", err)
+	}
+	bold, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load label font: %w", err)
+	}
+
+	scale := dpi / 25.4
+	width := int(math.Round(layout.PageWidth * scale))
+	height := int(math.Round(layout.PageHeight * scale))
+	return &imageLabelCanvas{
+		img:     image.NewGray(image.Rect(0, 0, width, height)),
+		scale:   scale,
+		regular: regular,
+		bold:    bold,
+		faces:   make(map[labelFaceKey]font.Face),
+	}, nil
+}
+
+// clear paints the sheet white
+func (c *imageLabelCanvas) clear() {
+	draw.Draw(c.img, c.img.Bounds(), image.White, image.Point{}, draw.Src)
+}
+
+func (c *imageLabelCanvas) fillRect(x, y, w, h float64) {
+	rect := image.Rect(
+		int(math.Round(x*c.scale)), int(math.Round(y*c.scale)),
+		int(math.Round((x+w)*c.scale)), int(math.Round((y+h)*c.scale)),
+	)
+	draw.Draw(c.img, rect, image.Black, image.Point{}, draw.Src)
+}
+
+// face returns the font face for a text height in millimetres
+func (c *imageLabelCanvas) face(size float64, bold bool) font.Face {
+	key := labelFaceKey{size: size, bold: bold}
+	if face, ok := c.faces[key]; ok {
+		return face
+	}
+
+	f := c.regular
+	if bold {
+		f = c.bold
+	}
+	// Sizes are in pixels at a DPI of 72, where a point is a pixel
+	var face font.Face
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size * c.scale, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		// Not expected for the embedded Go fonts; print in a plain font instead
+		face = basicfont.Face7x13
+	}
+	c.faces[key] = face
+	return face
+}
+
+func (c *imageLabelCanvas) textWidth(text string, size float64, bold bool) float64 {
+	width := font.MeasureString(c.face(size, bold), text)
+	return float64(width) / 64 / c.scale
+}
+
+func (c *imageLabelCanvas) text(x, y float64, text string, size float64, bold bool) {
+	drawer := &font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(color.Black),
+		Face: c.face(size, bold),
+		Dot:  fixed.P(int(math.Round(x*c.scale)), int(math.Round(y*c.scale))),
+	}
+	drawer.DrawString(text)
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LocationDTO is the data transfer object for locations
+type LocationDTO struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	IsDefault   bool      `json:"is_default"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// LocationStockDTO is the stock of a product at one location
+type LocationStockDTO struct {
+	LocationID   uint            `json:"location_id"`
+	LocationName string          `json:"location_name"`
+	Quantity     models.Quantity `json:"quantity"`
+}
+
+// LocationService handles location-related operations
+type LocationService struct {
+	dbManager *database.ConnectionManager
+}
+
+// NewLocationService creates a new location service
+func NewLocationService(dbManager *database.ConnectionManager) *LocationService {
+	return &LocationService{
+		dbManager: dbManager,
+	}
+}
+
+// Helper function to convert model to DTO
+func (s *LocationService) toDTO(location *models.Location) LocationDTO {
+	return LocationDTO{
+		ID:          location.ID,
+		Name:        location.Name,
+		Description: location.Description,
+		IsDefault:   location.IsDefault,
+		CreatedAt:   location.CreatedAt,
+		UpdatedAt:   location.UpdatedAt,
+	}
+}
+
+// GetAll returns all locations as DTOs, the default location first
+func (s *LocationService) GetAll() ([]LocationDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var locations []models.Location
+	if err := db.Order("is_default DESC, name ASC").Find(&locations).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch locations: %w", err)
+	}
+
+	dtos := make([]LocationDTO, len(locations))
+	for i, location := range locations {
+		dtos[i] = s.toDTO(&location)
+	}
+
+	return dtos, nil
+}
+
+// GetByID returns a location by ID as DTO
+func (s *LocationService) GetByID(id uint) (*LocationDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var location models.Location
+	if err := db.First(&location, id).Error; err != nil {
+		return nil, fmt.Errorf("location not found: %w", err)
+	}
+
+	dto := s.toDTO(&location)
+	return &dto, nil
+}
+
+// Create creates a new location from DTO
+func (s *LocationService) Create(dto LocationDTO) (*LocationDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	// Validate
+	if dto.Name == "" {
+		return nil, fmt.Errorf("location name cannot be empty")
+	}
+
+	// Check if location with same name already exists
+	var existing models.Location
+	if err := db.Where("name = ?", dto.Name).First(&existing).Error; err == nil {
+		return nil, conflictf("location with name '%s' already exists", dto.Name)
+	}
+
+	location := &models.Location{
+		Name:        dto.Name,
+		Description: dto.Description,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(location).Error; err != nil {
+			return fmt.Errorf("failed to create location: %w", err)
+		}
+		if dto.IsDefault {
+			return s.makeDefault(tx, location)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(location)
+	return &resultDTO, nil
+}
+
+// Update updates a location from DTO
+func (s *LocationService) Update(id uint, dto LocationDTO) (*LocationDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var location models.Location
+	if err := db.First(&location, id).Error; err != nil {
+		return nil, fmt.Errorf("location not found: %w", err)
+	}
+
+	// Validate
+	if dto.Name == "" {
+		return nil, fmt.Errorf("location name cannot be empty")
+	}
+
+	// Check if another location with same name exists
+	var existing models.Location
+	if err := db.Where("name = ? AND id != ?", dto.Name, id).First(&existing).Error; err == nil {
+		return nil, conflictf("location with name '%s' already exists", dto.Name)
+	}
+
+	// The default can only be moved to another location, not cleared
+	if location.IsDefault && !dto.IsDefault {
+		return nil, fmt.Errorf("choose another default location instead of clearing it")
+	}
+
+	location.Name = dto.Name
+	location.Description = dto.Description
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&location).Error; err != nil {
+			return fmt.Errorf("failed to update location: %w", err)
+		}
+		if dto.IsDefault && !location.IsDefault {
+			return s.makeDefault(tx, &location)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(&location)
+	return &resultDTO, nil
+}
+
+// Delete deletes a location by ID
+func (s *LocationService) Delete(id uint) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	var location models.Location
+	if err := db.First(&location, id).Error; err != nil {
+		return fmt.Errorf("location not found: %w", err)
+	}
+
+	if location.IsDefault {
+		return conflictf("cannot delete the default location")
+	}
+
+	// Check if location has movements
+	var movementCount int64
+	if err := db.Model(&models.StockMovement{}).Where("location_id = ? OR to_location_id = ?", id, id).Count(&movementCount).Error; err != nil {
+		return fmt.Errorf("failed to check movements: %w", err)
+	}
+
+	if movementCount > 0 {
+		return conflictf("cannot delete location with %d movements", movementCount)
+	}
+
+	var orderCount int64
+	if err := db.Model(&models.PurchaseOrder{}).Where("location_id = ?", id).Count(&orderCount).Error; err != nil {
+		return fmt.Errorf("failed to check purchase orders: %w", err)
+	}
+	if orderCount > 0 {
+		return conflictf("cannot delete location with %d purchase orders", orderCount)
+	}
+	if err := db.Model(&models.SalesOrder{}).Where("location_id = ?", id).Count(&orderCount).Error; err != nil {
+		return fmt.Errorf("failed to check sales orders: %w", err)
+	}
+	if orderCount > 0 {
+		return conflictf("cannot delete location with %d sales orders", orderCount)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("location_id = ?", id).Delete(&models.StockBalance{}).Error; err != nil {
+			return fmt.Errorf("failed to delete stock balances: %w", err)
+		}
+		if err := tx.Delete(&models.Location{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete location: %w", err)
+		}
+		return nil
+	})
+}
+
+// makeDefault marks location as the only default location
+func (s *LocationService) makeDefault(tx *gorm.DB, location *models.Location) error {
+	if err := tx.Model(&models.Location{}).Where("id != ?", location.ID).Update("is_default", false).Error; err != nil {
+		return fmt.Errorf("failed to clear default location: %w", err)
+	}
+	if err := tx.Model(location).Update("is_default", true).Error; err != nil {
+		return fmt.Errorf("failed to set default location: %w", err)
+	}
+	return nil
+}
+
+// defaultLocationID returns the ID of the default location
+func defaultLocationID(tx *gorm.DB) (uint, error) {
+	var location models.Location
+	if err := tx.Where("is_default = ?", true).First(&location).Error; err != nil {
+		return 0, fmt.Errorf("no default location: %w", err)
+	}
+	return location.ID, nil
+}
+
+// loadLocationStocks returns per-location stock for the given products, keyed by product ID
+func loadLocationStocks(db *gorm.DB, productIDs []uint) (map[uint][]LocationStockDTO, error) {
+	stocks := make(map[uint][]LocationStockDTO)
+	if len(productIDs) == 0 {
+		return stocks, nil
+	}
+
+	var rows []struct {
+		ProductID    uint
+		LocationID   uint
+		LocationName string
+		Quantity     models.Quantity
+	}
+	err := db.Table("stock_balances AS b").
+		Select("b.product_id, b.location_id, l.name AS location_name, b.quantity").
+		Joins("JOIN locations AS l ON l.id = b.location_id").
+		Where("b.product_id IN ? AND b.quantity <> 0", productIDs).
+		Order("l.is_default DESC, l.name ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stock balances: %w", err)
+	}
+
+	for _, row := range rows {
+		stocks[row.ProductID] = append(stocks[row.ProductID], LocationStockDTO{
+			LocationID:   row.LocationID,
+			LocationName: row.LocationName,
+			Quantity:     row.Quantity,
+		})
+	}
+
+	return stocks, nil
+}
@@ -5,49 +5,135 @@ import (
 	"stoktakip/internal/database"
 	"stoktakip/internal/models"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // MovementDTO is the data transfer object for movements
 type MovementDTO struct {
-	ID        uint      `json:"id"`
-	ProductID uint      `json:"product_id"`
-	Type      string    `json:"type"` // "IN" or "OUT"
-	Quantity  int       `json:"quantity"`
-	Note      string    `json:"note"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           uint            `json:"id"`
+	ProductID    uint            `json:"product_id"`
+	LocationID   uint            `json:"location_id"`    // 0 means the default location
+	ToLocationID uint            `json:"to_location_id"` // TRANSFER only
+	Type         string          `json:"type"`           // "IN", "OUT", "TRANSFER" or "ADJUSTMENT"
+	Quantity     models.Quantity `json:"quantity"`       // In the product's base unit, signed for ADJUSTMENT
+	Note         string          `json:"note"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UserID       uint            `json:"user_id"`  // Set from the logged in user, read-only
+	Username     string          `json:"username"` // Read-only
+
+	CustomerID uint   `json:"customer_id"` // Customer, cost center or project an OUT movement issues to; 0 means none
+	Customer   string `json:"customer"`    // Read-only
+
+	// Reservation an OUT movement ships from. Without one, an OUT movement can
+	// only take stock that no active reservation holds.
+	ReservationID uint `json:"reservation_id"`
+
+	PurchaseOrderLineID uint `json:"purchase_order_line_id"` // Order line an IN movement received, read-only
+	SalesOrderLineID    uint `json:"sales_order_line_id"`    // Order line an OUT movement shipped, read-only
+
+	// Unit and quantity as recorded. When EnteredUnit is set on create,
+	// EnteredQuantity is converted into Quantity; otherwise Quantity is used as is.
+	EnteredUnit     string          `json:"entered_unit"`
+	EnteredQuantity models.Quantity `json:"entered_quantity"`
 }
 
 // MovementStats holds statistics about movements
 type MovementStats struct {
-	TotalIn       int   `json:"total_in"`
-	TotalOut      int   `json:"total_out"`
-	TodayIn       int   `json:"today_in"`
-	TodayOut      int   `json:"today_out"`
-	MovementCount int64 `json:"movement_count"`
+	TotalIn       models.Quantity `json:"total_in"`
+	TotalOut      models.Quantity `json:"total_out"`
+	TodayIn       models.Quantity `json:"today_in"`
+	TodayOut      models.Quantity `json:"today_out"`
+	MovementCount int64           `json:"movement_count"`
+}
+
+// MovementQuery selects a page of movements. Zero filter values match everything.
+type MovementQuery struct {
+	PageQuery
+	ProductID  uint      `json:"product_id"`
+	CategoryID uint      `json:"category_id"` // Category of the product
+	LocationID uint      `json:"location_id"` // Source or destination
+	CustomerID uint      `json:"customer_id"` // Destination of OUT movements
+	Type       string    `json:"type"`        // "IN", "OUT", "TRANSFER" or "ADJUSTMENT"
+	From       time.Time `json:"from"`        // Movement date range, inclusive
+	To         time.Time `json:"to"`
+}
+
+// MovementPage is one page of a movement list
+type MovementPage struct {
+	Items    []MovementDTO `json:"items"`
+	Total    int64         `json:"total"` // Matching movements on all pages
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
+
+// movementSortFields maps the sort fields of MovementQuery to SQL. Dates are
+// compared as instants because stored times carry their own offset.
+var movementSortFields = map[string]string{
+	"date":         "julianday(stock_movements.date)",
+	"created_at":   "stock_movements.created_at",
+	"type":         "stock_movements.type",
+	"quantity":     "stock_movements.quantity",
+	"product_code": "(SELECT code FROM products WHERE products.id = stock_movements.product_id)",
+	"product_name": "(SELECT name FROM products WHERE products.id = stock_movements.product_id)",
 }
 
 // MovementService handles stock movement operations
 type MovementService struct {
 	dbManager *database.ConnectionManager
+	audit     *AuditService
+	auth      *AuthService
 }
 
 // NewMovementService creates a new movement service
-func NewMovementService(dbManager *database.ConnectionManager) *MovementService {
+func NewMovementService(dbManager *database.ConnectionManager, audit *AuditService, auth *AuthService) *MovementService {
 	return &MovementService{
 		dbManager: dbManager,
+		audit:     audit,
+		auth:      auth,
 	}
 }
 
 // Helper function to convert model to DTO
 func (s *MovementService) toDTO(movement *models.StockMovement) MovementDTO {
-	return MovementDTO{
-		ID:        movement.ID,
-		ProductID: movement.ProductID,
-		Type:      string(movement.Type),
-		Quantity:  movement.Quantity,
-		Note:      movement.Note,
-		CreatedAt: movement.CreatedAt,
+	dto := MovementDTO{
+		ID:              movement.ID,
+		ProductID:       movement.ProductID,
+		LocationID:      movement.LocationID,
+		Type:            string(movement.Type),
+		Quantity:        movement.Quantity,
+		Note:            movement.Note,
+		CreatedAt:       movement.CreatedAt,
+		EnteredQuantity: movement.EnteredQuantity,
+	}
+	if movement.ToLocationID != nil {
+		dto.ToLocationID = *movement.ToLocationID
+	}
+	if movement.EnteredUnit != nil {
+		dto.EnteredUnit = movement.EnteredUnit.Name
+	}
+	if movement.UserID != nil {
+		dto.UserID = *movement.UserID
+	}
+	if movement.User != nil {
+		dto.Username = movement.User.Username
+	}
+	if movement.PurchaseOrderLineID != nil {
+		dto.PurchaseOrderLineID = *movement.PurchaseOrderLineID
+	}
+	if movement.CustomerID != nil {
+		dto.CustomerID = *movement.CustomerID
+	}
+	if movement.Customer != nil {
+		dto.Customer = movement.Customer.Name
+	}
+	if movement.SalesOrderLineID != nil {
+		dto.SalesOrderLineID = *movement.SalesOrderLineID
 	}
+	if movement.ReservationID != nil {
+		dto.ReservationID = *movement.ReservationID
+	}
+	return dto
 }
 
 // GetAll returns all movements as DTOs
@@ -58,7 +144,7 @@ func (s *MovementService) GetAll() ([]MovementDTO, error) {
 	}
 
 	var movements []models.StockMovement
-	if err := db.Order("created_at DESC").Find(&movements).Error; err != nil {
+	if err := db.Preload("EnteredUnit").Preload("User").Preload("Customer").Order("created_at DESC").Find(&movements).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch movements: %w", err)
 	}
 
@@ -70,6 +156,63 @@ func (s *MovementService) GetAll() ([]MovementDTO, error) {
 	return dtos, nil
 }
 
+// List returns one page of the movements matching q with the total count,
+// newest first unless sorted otherwise
+func (s *MovementService) List(q MovementQuery) (*MovementPage, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+	if err := q.normalize(movementSortFields, "date", SortDesc); err != nil {
+		return nil, err
+	}
+
+	query := db.Model(&models.StockMovement{})
+	if q.ProductID != 0 {
+		query = query.Where("stock_movements.product_id = ?", q.ProductID)
+	}
+	if q.CategoryID != 0 {
+		query = query.Where("stock_movements.product_id IN (SELECT id FROM products WHERE category_id = ?)", q.CategoryID)
+	}
+	if q.LocationID != 0 {
+		query = query.Where("stock_movements.location_id = ? OR stock_movements.to_location_id = ?", q.LocationID, q.LocationID)
+	}
+	if q.CustomerID != 0 {
+		query = query.Where("stock_movements.customer_id = ?", q.CustomerID)
+	}
+	if q.Type != "" {
+		if !models.MovementType(q.Type).IsValid() {
+			return nil, fmt.Errorf("invalid movement type: %s", q.Type)
+		}
+		query = query.Where("stock_movements.type = ?", q.Type)
+	}
+	if !q.From.IsZero() {
+		query = query.Where("julianday(stock_movements.date) >= julianday(?)", q.From)
+	}
+	if !q.To.IsZero() {
+		query = query.Where("julianday(stock_movements.date) <= julianday(?)", q.To)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count movements: %w", err)
+	}
+
+	var movements []models.StockMovement
+	if err := q.apply(query, movementSortFields, "stock_movements.id").
+		Preload("EnteredUnit").Preload("User").Preload("Customer").
+		Find(&movements).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch movements: %w", err)
+	}
+
+	items := make([]MovementDTO, len(movements))
+	for i, movement := range movements {
+		items[i] = s.toDTO(&movement)
+	}
+	return &MovementPage{Items: items, Total: total, Page: q.Page, PageSize: q.PageSize}, nil
+}
+
 // GetByID returns a movement by ID as DTO
 func (s *MovementService) GetByID(id uint) (*MovementDTO, error) {
 	db := s.dbManager.GetDB()
@@ -78,7 +221,7 @@ func (s *MovementService) GetByID(id uint) (*MovementDTO, error) {
 	}
 
 	var movement models.StockMovement
-	if err := db.First(&movement, id).Error; err != nil {
+	if err := db.Preload("EnteredUnit").Preload("User").Preload("Customer").First(&movement, id).Error; err != nil {
 		return nil, fmt.Errorf("movement not found: %w", err)
 	}
 
@@ -93,50 +236,93 @@ func (s *MovementService) Create(dto MovementDTO) (*MovementDTO, error) {
 		return nil, fmt.Errorf("no database connection")
 	}
 
+	var movement *models.StockMovement
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		movement, err = s.insert(tx, dto)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(movement)
+	return &resultDTO, nil
+}
+
+// insert validates and applies a new movement inside tx
+func (s *MovementService) insert(tx *gorm.DB, dto MovementDTO) (*models.StockMovement, error) {
+	movement, err := s.build(tx, dto)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(tx, movement); err != nil {
+		return nil, err
+	}
+	return movement, nil
+}
+
+// build validates dto and turns it into a movement that is not stored yet
+func (s *MovementService) build(tx *gorm.DB, dto MovementDTO) (*models.StockMovement, error) {
 	// Validate movement type
-	if dto.Type != "IN" && dto.Type != "OUT" {
+	if !models.MovementType(dto.Type).IsValid() {
 		return nil, fmt.Errorf("invalid movement type: %s", dto.Type)
 	}
-
-	// Check if product exists
-	var product models.Product
-	if err := db.First(&product, dto.ProductID).Error; err != nil {
-		return nil, fmt.Errorf("product not found: %w", err)
+	if models.MovementType(dto.Type) == models.MovementTypeAdjustment {
+		return nil, fmt.Errorf("adjustment movements are created by posting a stock count")
 	}
 
-	// For OUT movements, check if sufficient stock
-	if dto.Type == "OUT" && product.CurrentStock < dto.Quantity {
-		return nil, fmt.Errorf("insufficient stock: available %d, requested %d", product.CurrentStock, dto.Quantity)
+	if dto.EnteredUnit == "" && dto.Quantity <= 0 || dto.EnteredUnit != "" && dto.EnteredQuantity <= 0 {
+		return nil, fmt.Errorf("quantity must be greater than zero")
 	}
 
-	// Create movement
 	movement := &models.StockMovement{
-		ProductID: dto.ProductID,
-		Type:      models.MovementType(dto.Type),
-		Quantity:  dto.Quantity,
-		Date:      time.Now(),
-		Note:      dto.Note,
+		ProductID:  dto.ProductID,
+		LocationID: dto.LocationID,
+		Type:       models.MovementType(dto.Type),
+		Quantity:   dto.Quantity,
+		Date:       time.Now(),
+		Note:       dto.Note,
+		UserID:     s.auth.CurrentUserID(),
 	}
-
-	if err := db.Create(movement).Error; err != nil {
-		return nil, fmt.Errorf("failed to create movement: %w", err)
+	if dto.ToLocationID != 0 {
+		toLocationID := dto.ToLocationID
+		movement.ToLocationID = &toLocationID
 	}
-
-	// Update product stock
-	if dto.Type == "IN" {
-		product.CurrentStock += dto.Quantity
-	} else {
-		product.CurrentStock -= dto.Quantity
+	if dto.CustomerID != 0 {
+		if movement.Type != models.MovementTypeOut {
+			return nil, fmt.Errorf("only OUT movements can have a customer")
+		}
+		if err := tx.First(&models.Customer{}, dto.CustomerID).Error; err != nil {
+			return nil, fmt.Errorf("customer not found: %w", err)
+		}
+		customerID := dto.CustomerID
+		movement.CustomerID = &customerID
+	}
+	if dto.ReservationID != 0 {
+		if movement.Type != models.MovementTypeOut {
+			return nil, fmt.Errorf("only OUT movements can fulfil a reservation")
+		}
+		reservationID := dto.ReservationID
+		movement.ReservationID = &reservationID
 	}
 
-	if err := db.Save(&product).Error; err != nil {
-		// Rollback movement if stock update fails
-		db.Delete(movement)
-		return nil, fmt.Errorf("failed to update product stock: %w", err)
+	if dto.EnteredUnit != "" {
+		unit, err := resolveUnit(tx, 0, dto.EnteredUnit)
+		if err != nil {
+			return nil, err
+		}
+		movement.EnteredUnitID = &unit.ID
+		movement.EnteredQuantity = dto.EnteredQuantity
 	}
+	return movement, nil
+}
 
-	resultDTO := s.toDTO(movement)
-	return &resultDTO, nil
+// save applies a built movement and records it in the audit log inside tx
+func (s *MovementService) save(tx *gorm.DB, movement *models.StockMovement) error {
+	if err := s.apply(tx, movement); err != nil {
+		return err
+	}
+	return s.audit.record(tx, auditEntityMovement, movement.ID, models.AuditActionCreate, nil, s.toDTO(movement))
 }
 
 // Delete deletes a movement by ID
@@ -146,40 +332,311 @@ func (s *MovementService) Delete(id uint) error {
 		return fmt.Errorf("no database connection")
 	}
 
-	// Get movement first
-	var movement models.StockMovement
-	if err := db.First(&movement, id).Error; err != nil {
-		return fmt.Errorf("movement not found: %w", err)
-	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Get movement first
+		var movement models.StockMovement
+		if err := tx.Preload("EnteredUnit").Preload("User").Preload("Customer").First(&movement, id).Error; err != nil {
+			return fmt.Errorf("movement not found: %w", err)
+		}
+		if movement.Type == models.MovementTypeAdjustment {
+			return conflictf("cannot delete an adjustment booked by a stock count")
+		}
+
+		if err := s.revert(tx, &movement); err != nil {
+			return fmt.Errorf("cannot delete movement: %w", err)
+		}
+		if movement.PurchaseOrderLineID != nil {
+			if err := unreceive(tx, *movement.PurchaseOrderLineID, movement.Quantity); err != nil {
+				return err
+			}
+		}
+		if movement.SalesOrderLineID != nil {
+			if err := unship(tx, *movement.SalesOrderLineID, movement.Quantity); err != nil {
+				return err
+			}
+		}
+		if movement.ReservationID != nil {
+			if err := unfulfil(tx, *movement.ReservationID, movement.Quantity); err != nil {
+				return err
+			}
+		}
+
+		// Delete movement
+		if err := tx.Delete(&movement).Error; err != nil {
+			return fmt.Errorf("failed to delete movement: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntityMovement, movement.ID, models.AuditActionDelete, s.toDTO(&movement), nil)
+	})
+}
 
-	// Get product
+// apply stores movement and updates product and location stock inside tx.
+// OUT and TRANSFER movements are refused if they would oversell.
+func (s *MovementService) apply(tx *gorm.DB, movement *models.StockMovement) error {
+	// Check if product exists
 	var product models.Product
-	if err := db.First(&product, movement.ProductID).Error; err != nil {
+	if err := tx.First(&product, movement.ProductID).Error; err != nil {
 		return fmt.Errorf("product not found: %w", err)
 	}
 
-	// Reverse the stock change
-	if movement.Type == models.MovementTypeIn {
-		product.CurrentStock -= movement.Quantity
+	if movement.LocationID == 0 {
+		locationID, err := defaultLocationID(tx)
+		if err != nil {
+			return err
+		}
+		movement.LocationID = locationID
+	}
+	if err := tx.First(&models.Location{}, movement.LocationID).Error; err != nil {
+		return fmt.Errorf("location not found: %w", err)
+	}
+
+	if movement.Type == models.MovementTypeTransfer {
+		if movement.ToLocationID == nil {
+			return fmt.Errorf("transfer requires a destination location")
+		}
+		if *movement.ToLocationID == movement.LocationID {
+			return fmt.Errorf("transfer source and destination must differ")
+		}
+		if err := tx.First(&models.Location{}, *movement.ToLocationID).Error; err != nil {
+			return fmt.Errorf("destination location not found: %w", err)
+		}
 	} else {
-		product.CurrentStock += movement.Quantity
+		movement.ToLocationID = nil
+	}
+
+	// Normalize to the product's base unit before stock is touched
+	if movement.EnteredUnitID == nil || product.UnitID != nil && *movement.EnteredUnitID == *product.UnitID {
+		movement.EnteredUnitID = product.UnitID
+		if movement.EnteredQuantity == 0 {
+			movement.EnteredQuantity = movement.Quantity
+		}
+		movement.Quantity = movement.EnteredQuantity
+	} else {
+		unit, err := resolveUnit(tx, *movement.EnteredUnitID, "")
+		if err != nil {
+			return err
+		}
+		if movement.EnteredQuantity.Decimals() > unit.Decimals {
+			return fmt.Errorf("quantity %s has more decimals than unit '%s' allows (%d)", movement.EnteredQuantity, unit.Name, unit.Decimals)
+		}
+		if movement.Quantity, err = toBaseQuantity(tx, &product, unit, movement.EnteredQuantity); err != nil {
+			return err
+		}
+	}
+	if movement.Quantity == 0 || movement.Quantity < 0 && movement.Type != models.MovementTypeAdjustment {
+		return fmt.Errorf("quantity must be greater than zero")
+	}
+	if err := checkPrecision(tx, product.Unit, movement.Quantity); err != nil {
+		return err
+	}
+	if movement.Type != models.MovementTypeOut {
+		movement.ReservationID = nil
+	} else if movement.ReservationID != nil {
+		if err := checkFulfilment(tx, *movement.ReservationID, product.ID, movement.Quantity); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Create(movement).Error; err != nil {
+		return fmt.Errorf("failed to create movement: %w", err)
+	}
+
+	// Loaded for the DTO only, after Create so GORM does not save it back
+	if movement.EnteredUnitID != nil {
+		movement.EnteredUnit = &models.Unit{}
+		if err := tx.First(movement.EnteredUnit, *movement.EnteredUnitID).Error; err != nil {
+			return fmt.Errorf("unit not found: %w", err)
+		}
+	}
+	if movement.UserID != nil {
+		movement.User = &models.User{}
+		if err := tx.First(movement.User, *movement.UserID).Error; err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
+	}
+	if movement.CustomerID != nil {
+		movement.Customer = &models.Customer{}
+		if err := tx.First(movement.Customer, *movement.CustomerID).Error; err != nil {
+			return fmt.Errorf("customer not found: %w", err)
+		}
+	}
+
+	switch movement.Type {
+	case models.MovementTypeIn:
+		if err := s.increaseBalance(tx, movement.ProductID, movement.LocationID, movement.Quantity); err != nil {
+			return err
+		}
+		return s.increaseStock(tx, movement.ProductID, movement.Quantity)
+	case models.MovementTypeOut:
+		if err := s.decreaseBalance(tx, movement.ProductID, movement.LocationID, movement.Quantity); err != nil {
+			return err
+		}
+		if movement.ReservationID == nil {
+			return s.decreaseUnreserved(tx, movement.ProductID, movement.Quantity, 0)
+		}
+		if err := s.decreaseUnreserved(tx, movement.ProductID, movement.Quantity, *movement.ReservationID); err != nil {
+			return err
+		}
+		return fulfil(tx, *movement.ReservationID, movement.Quantity)
+	case models.MovementTypeAdjustment:
+		return s.adjustStock(tx, movement.ProductID, movement.LocationID, movement.Quantity)
+	default:
+		if err := s.decreaseBalance(tx, movement.ProductID, movement.LocationID, movement.Quantity); err != nil {
+			return err
+		}
+		return s.increaseBalance(tx, movement.ProductID, *movement.ToLocationID, movement.Quantity)
+	}
+}
+
+// revert undoes the stock change of movement inside tx, refusing to go below
+// zero or to take an IN back while reservations hold its stock
+func (s *MovementService) revert(tx *gorm.DB, movement *models.StockMovement) error {
+	switch movement.Type {
+	case models.MovementTypeIn:
+		if err := s.decreaseBalance(tx, movement.ProductID, movement.LocationID, movement.Quantity); err != nil {
+			return err
+		}
+		return s.decreaseUnreserved(tx, movement.ProductID, movement.Quantity, 0)
+	case models.MovementTypeOut:
+		if err := s.increaseBalance(tx, movement.ProductID, movement.LocationID, movement.Quantity); err != nil {
+			return err
+		}
+		return s.increaseStock(tx, movement.ProductID, movement.Quantity)
+	case models.MovementTypeAdjustment:
+		return s.adjustStock(tx, movement.ProductID, movement.LocationID, -movement.Quantity)
+	default:
+		if movement.ToLocationID == nil {
+			return fmt.Errorf("transfer has no destination location")
+		}
+		if err := s.decreaseBalance(tx, movement.ProductID, *movement.ToLocationID, movement.Quantity); err != nil {
+			return err
+		}
+		return s.increaseBalance(tx, movement.ProductID, movement.LocationID, movement.Quantity)
+	}
+}
+
+// adjustStock adds a signed quantity to a product's stock at a location and
+// in total, refusing to go below zero
+func (s *MovementService) adjustStock(tx *gorm.DB, productID, locationID uint, quantity models.Quantity) error {
+	if quantity >= 0 {
+		if err := s.increaseBalance(tx, productID, locationID, quantity); err != nil {
+			return err
+		}
+		return s.increaseStock(tx, productID, quantity)
 	}
+	if err := s.decreaseBalance(tx, productID, locationID, -quantity); err != nil {
+		return err
+	}
+	return s.decreaseStock(tx, productID, -quantity)
+}
 
-	// Prevent negative stock
-	if product.CurrentStock < 0 {
-		return fmt.Errorf("cannot delete movement: would result in negative stock")
+// adjust records an ADJUSTMENT movement of a signed quantity in the
+// product's base unit inside tx
+func (s *MovementService) adjust(tx *gorm.DB, productID, locationID uint, quantity models.Quantity, note string) (*models.StockMovement, error) {
+	movement := &models.StockMovement{
+		ProductID:  productID,
+		LocationID: locationID,
+		Type:       models.MovementTypeAdjustment,
+		Quantity:   quantity,
+		Date:       time.Now(),
+		Note:       note,
+		UserID:     s.auth.CurrentUserID(),
+	}
+	if err := s.save(tx, movement); err != nil {
+		return nil, err
 	}
+	return movement, nil
+}
 
-	// Update product stock
-	if err := db.Save(&product).Error; err != nil {
-		return fmt.Errorf("failed to update product stock: %w", err)
+// increaseStock adds quantity to a product's current stock
+func (s *MovementService) increaseStock(tx *gorm.DB, productID uint, quantity models.Quantity) error {
+	result := tx.Model(&models.Product{}).
+		Where("id = ?", productID).
+		Update("current_stock", gorm.Expr("current_stock + ?", quantity))
+	if result.Error != nil {
+		return fmt.Errorf("failed to update product stock: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("product not found")
 	}
+	return nil
+}
 
-	// Delete movement
-	if err := db.Delete(&movement).Error; err != nil {
-		return fmt.Errorf("failed to delete movement: %w", err)
+// decreaseStock subtracts quantity from a product's current stock. The check
+// and the update are a single statement so concurrent writers cannot oversell.
+func (s *MovementService) decreaseStock(tx *gorm.DB, productID uint, quantity models.Quantity) error {
+	result := tx.Model(&models.Product{}).
+		Where("id = ? AND current_stock >= ?", productID, quantity).
+		Update("current_stock", gorm.Expr("current_stock - ?", quantity))
+	if result.Error != nil {
+		return fmt.Errorf("failed to update product stock: %w", result.Error)
 	}
+	if result.RowsAffected == 0 {
+		var product models.Product
+		if err := tx.First(&product, productID).Error; err != nil {
+			return fmt.Errorf("product not found: %w", err)
+		}
+		return conflictf("insufficient stock: available %s, requested %s", product.CurrentStock, quantity)
+	}
+	return nil
+}
 
+// decreaseUnreserved subtracts quantity from a product's current stock,
+// leaving what active reservations other than reservationID hold. Like
+// decreaseStock it checks and updates in a single statement.
+func (s *MovementService) decreaseUnreserved(tx *gorm.DB, productID uint, quantity models.Quantity, reservationID uint) error {
+	now := time.Now()
+	result := tx.Model(&models.Product{}).
+		Where("id = ? AND current_stock - ? >= ("+reservedQuantitySQL+")", productID, quantity, productID, reservationID, now).
+		Update("current_stock", gorm.Expr("current_stock - ?", quantity))
+	if result.Error != nil {
+		return fmt.Errorf("failed to update product stock: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	var product models.Product
+	if err := tx.First(&product, productID).Error; err != nil {
+		return fmt.Errorf("product not found: %w", err)
+	}
+	if product.CurrentStock < quantity {
+		return conflictf("insufficient stock: available %s, requested %s", product.CurrentStock, quantity)
+	}
+	var reserved models.Quantity
+	if err := tx.Raw(reservedQuantitySQL, productID, reservationID, now).Scan(&reserved).Error; err != nil {
+		return fmt.Errorf("failed to fetch reserved quantity: %w", err)
+	}
+	return conflictf("insufficient stock: %s of the %s in stock is reserved, requested %s", min(reserved, product.CurrentStock), product.CurrentStock, quantity)
+}
+
+// increaseBalance adds quantity to a product's stock at a location
+func (s *MovementService) increaseBalance(tx *gorm.DB, productID, locationID uint, quantity models.Quantity) error {
+	err := tx.Exec(`INSERT INTO stock_balances (product_id, location_id, quantity, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (product_id, location_id) DO UPDATE SET quantity = quantity + excluded.quantity, updated_at = excluded.updated_at`,
+		productID, locationID, quantity, time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to update location stock: %w", err)
+	}
+	return nil
+}
+
+// decreaseBalance subtracts quantity from a product's stock at a location,
+// with the same single-statement check as decreaseStock
+func (s *MovementService) decreaseBalance(tx *gorm.DB, productID, locationID uint, quantity models.Quantity) error {
+	result := tx.Model(&models.StockBalance{}).
+		Where("product_id = ? AND location_id = ? AND quantity >= ?", productID, locationID, quantity).
+		Update("quantity", gorm.Expr("quantity - ?", quantity))
+	if result.Error != nil {
+		return fmt.Errorf("failed to update location stock: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		var available models.Quantity
+		tx.Model(&models.StockBalance{}).
+			Where("product_id = ? AND location_id = ?", productID, locationID).
+			Select("COALESCE(SUM(quantity), 0)").Scan(&available)
+		return conflictf("insufficient stock at location: available %s, requested %s", available, quantity)
+	}
 	return nil
 }
 
@@ -191,7 +648,7 @@ func (s *MovementService) GetByProduct(productID uint) ([]MovementDTO, error) {
 	}
 
 	var movements []models.StockMovement
-	if err := db.Where("product_id = ?", productID).Order("created_at DESC").Find(&movements).Error; err != nil {
+	if err := db.Preload("EnteredUnit").Preload("User").Preload("Customer").Where("product_id = ?", productID).Order("created_at DESC").Find(&movements).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch movements: %w", err)
 	}
 
@@ -217,33 +674,25 @@ func (s *MovementService) GetStats() (*MovementStats, error) {
 		return nil, fmt.Errorf("failed to count movements: %w", err)
 	}
 
-	// Total IN
-	var totalIn int64
-	if err := db.Model(&models.StockMovement{}).Where("type = ?", "IN").Select("COALESCE(SUM(quantity), 0)").Scan(&totalIn).Error; err != nil {
+	// Total IN (quantities are fixed-point integers, so the sums are exact)
+	if err := db.Model(&models.StockMovement{}).Where("type = ?", "IN").Select("COALESCE(SUM(quantity), 0)").Scan(&stats.TotalIn).Error; err != nil {
 		return nil, fmt.Errorf("failed to calculate total IN: %w", err)
 	}
-	stats.TotalIn = int(totalIn)
 
 	// Total OUT
-	var totalOut int64
-	if err := db.Model(&models.StockMovement{}).Where("type = ?", "OUT").Select("COALESCE(SUM(quantity), 0)").Scan(&totalOut).Error; err != nil {
+	if err := db.Model(&models.StockMovement{}).Where("type = ?", "OUT").Select("COALESCE(SUM(quantity), 0)").Scan(&stats.TotalOut).Error; err != nil {
 		return nil, fmt.Errorf("failed to calculate total OUT: %w", err)
 	}
-	stats.TotalOut = int(totalOut)
 
 	// Today's movements
 	today := time.Now().Format("2006-01-02")
-	var todayIn int64
-	if err := db.Model(&models.StockMovement{}).Where("type = ? AND DATE(date) = ?", "IN", today).Select("COALESCE(SUM(quantity), 0)").Scan(&todayIn).Error; err != nil {
+	if err := db.Model(&models.StockMovement{}).Where("type = ? AND DATE(date, 'localtime') = ?", "IN", today).Select("COALESCE(SUM(quantity), 0)").Scan(&stats.TodayIn).Error; err != nil {
 		return nil, fmt.Errorf("failed to calculate today's IN: %w", err)
 	}
-	stats.TodayIn = int(todayIn)
 
-	var todayOut int64
-	if err := db.Model(&models.StockMovement{}).Where("type = ? AND DATE(date) = ?", "OUT", today).Select("COALESCE(SUM(quantity), 0)").Scan(&todayOut).Error; err != nil {
+	if err := db.Model(&models.StockMovement{}).Where("type = ? AND DATE(date, 'localtime') = ?", "OUT", today).Select("COALESCE(SUM(quantity), 0)").Scan(&stats.TodayOut).Error; err != nil {
 		return nil, fmt.Errorf("failed to calculate today's OUT: %w", err)
 	}
-	stats.TodayOut = int(todayOut)
 
 	return stats, nil
 }
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+)
+
+func TestMovementCreateConcurrentOutNeverOversells(t *testing.T) {
+	dbManager := database.GetConnectionManager()
+	if err := dbManager.Connect(filepath.Join(t.TempDir(), "concurrency.db")); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer dbManager.Close()
+
+	auditService := NewAuditService(dbManager)
+	productService := NewProductService(dbManager, auditService)
+	movementService := NewMovementService(dbManager, auditService, NewAuthService(dbManager, auditService))
+
+	product, err := productService.Create(ProductDTO{Code: "P-001", Name: "Test", CategoryID: 1, Unit: "adet"})
+	if err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+
+	const (
+		openingStock = 100
+		workers      = 50
+		perMovement  = 3
+	)
+
+	if _, err := movementService.Create(MovementDTO{ProductID: product.ID, Type: "IN", Quantity: models.NewQuantity(openingStock)}); err != nil {
+		t.Fatalf("create IN movement: %v", err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := movementService.Create(MovementDTO{ProductID: product.ID, Type: "OUT", Quantity: models.NewQuantity(perMovement)}); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if want := openingStock / perMovement; succeeded != want {
+		t.Errorf("succeeded OUT movements = %d, want %d", succeeded, want)
+	}
+
+	var stored models.Product
+	if err := dbManager.GetDB().First(&stored, product.ID).Error; err != nil {
+		t.Fatalf("reload product: %v", err)
+	}
+	if stored.CurrentStock < 0 {
+		t.Fatalf("current stock went negative: %s", stored.CurrentStock)
+	}
+	if want := models.NewQuantity(int64(openingStock - succeeded*perMovement)); stored.CurrentStock != want {
+		t.Errorf("current stock = %s, want %s", stored.CurrentStock, want)
+	}
+
+	var outCount int64
+	dbManager.GetDB().Model(&models.StockMovement{}).Where("type = ?", "OUT").Count(&outCount)
+	if int(outCount) != succeeded {
+		t.Errorf("stored OUT movements = %d, want %d", outCount, succeeded)
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Paging defaults and limits
+const (
+	DefaultPageSize = 25
+	MaxPageSize     = 500
+)
+
+// Sort directions
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// PageQuery selects one page of a sorted list. Zero values pick the defaults.
+type PageQuery struct {
+	Page     int    `json:"page"`      // 1-based
+	PageSize int    `json:"page_size"` // Defaults to DefaultPageSize, at most MaxPageSize
+	SortBy   string `json:"sort_by"`   // One of the list's sort fields
+	SortDir  string `json:"sort_dir"`  // SortAsc or SortDesc
+}
+
+// normalize fills in defaults and checks the page against the sort fields
+// the list allows
+func (q *PageQuery) normalize(sortFields map[string]string, defaultSort, defaultDir string) error {
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = DefaultPageSize
+	}
+	if q.PageSize > MaxPageSize {
+		return fmt.Errorf("page size cannot be larger than %d", MaxPageSize)
+	}
+
+	if q.SortBy == "" {
+		q.SortBy = defaultSort
+		if q.SortDir == "" {
+			q.SortDir = defaultDir
+		}
+	}
+	if _, ok := sortFields[q.SortBy]; !ok {
+		return fmt.Errorf("invalid sort field: %s", q.SortBy)
+	}
+
+	q.SortDir = strings.ToLower(q.SortDir)
+	if q.SortDir == "" {
+		q.SortDir = SortAsc
+	}
+	if q.SortDir != SortAsc && q.SortDir != SortDesc {
+		return fmt.Errorf("invalid sort direction: %s", q.SortDir)
+	}
+	return nil
+}
+
+// apply orders query by the sort field, then by tieBreaker so pages do not
+// overlap, and limits it to the page
+func (q PageQuery) apply(query *gorm.DB, sortFields map[string]string, tieBreaker string) *gorm.DB {
+	dir := strings.ToUpper(q.SortDir)
+	return query.
+		Order(sortFields[q.SortBy] + " " + dir).
+		Order(tieBreaker + " " + dir).
+		Offset((q.Page - 1) * q.PageSize).
+		Limit(q.PageSize)
+}
+
+// likeEscaper escapes the LIKE wildcards in user input; patterns use ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePattern matches text anywhere in a column
+func likePattern(text string) string {
+	return "%" + likeEscaper.Replace(text) + "%"
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Search result limits
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 200
+)
+
+// productSearchRank orders matches by bm25 with the code weighted highest,
+// then the name, category and category description
+const productSearchRank = "bm25(product_search, 10.0, 5.0, 2.0, 1.0)"
+
+// Search finds products whose code, name, category or category description
+// contain words starting with each word of query, best matches first.
+// Case and Turkish letters are ignored, so "sise" finds "ŞİŞE".
+func (s *ProductService) Search(query string, limit int) ([]ProductDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	match := searchMatchExpression(query)
+	if match == "" {
+		return []ProductDTO{}, nil
+	}
+
+	var ids []uint
+	if err := db.Raw(`SELECT rowid FROM product_search WHERE product_search MATCH ? ORDER BY `+productSearchRank+` LIMIT ?`,
+		match, limit).Scan(&ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to search products: %w", err)
+	}
+	if len(ids) == 0 {
+		return []ProductDTO{}, nil
+	}
+
+	var found []models.Product
+	if err := db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	// Keep the rank order of the index
+	byID := make(map[uint]models.Product, len(found))
+	for _, product := range found {
+		byID[product.ID] = product
+	}
+	products := make([]models.Product, 0, len(found))
+	for _, id := range ids {
+		if product, ok := byID[id]; ok {
+			products = append(products, product)
+		}
+	}
+
+	return s.toDTOs(db, products)
+}
+
+// searchMatchExpression turns user input into an FTS5 query that needs every
+// word as a prefix, or "" when the input has no words
+func searchMatchExpression(query string) string {
+	words := strings.FieldsFunc(database.FoldSearchText(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + word + `"*`
+	}
+	return strings.Join(terms, " ")
+}
+
+// indexProduct refreshes the search entry of a product inside tx
+func indexProduct(tx *gorm.DB, productID uint) error {
+	var row struct {
+		Code        string
+		Name        string
+		Category    string
+		Description string
+	}
+	if err := tx.Raw(`SELECT p.code, p.name,
+			COALESCE(c.name, '') AS category, COALESCE(c.description, '') AS description
+		FROM products p LEFT JOIN categories c ON c.id = p.category_id
+		WHERE p.id = ?`, productID).Scan(&row).Error; err != nil {
+		return fmt.Errorf("failed to index product: %w", err)
+	}
+
+	if err := unindexProduct(tx, productID); err != nil {
+		return err
+	}
+	if err := tx.Exec(`INSERT INTO product_search(rowid, code, name, category, description) VALUES (?, ?, ?, ?, ?)`,
+		productID, database.FoldSearchText(row.Code), database.FoldSearchText(row.Name),
+		database.FoldSearchText(row.Category), database.FoldSearchText(row.Description)).Error; err != nil {
+		return fmt.Errorf("failed to index product: %w", err)
+	}
+	return nil
+}
+
+// unindexProduct removes a product from the search index inside tx
+func unindexProduct(tx *gorm.DB, productID uint) error {
+	if err := tx.Exec(`DELETE FROM product_search WHERE rowid = ?`, productID).Error; err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+	return nil
+}
+
+// indexCategoryProducts refreshes the search entries of a category's products
+// inside tx, after the category was renamed
+func indexCategoryProducts(tx *gorm.DB, categoryID uint) error {
+	var ids []uint
+	if err := tx.Model(&models.Product{}).Where("category_id = ?", categoryID).Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("failed to fetch products: %w", err)
+	}
+	for _, id := range ids {
+		if err := indexProduct(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
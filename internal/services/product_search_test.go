@@ -0,0 +1,69 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"stoktakip/internal/database"
+)
+
+func TestSearchMatchExpression(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"vida", `"vida"*`},
+		{"ŞİŞE kapak", `"sise"* "kapak"*`},
+		{"  M8x20  ", `"m8x20"*`},
+		{`"vida`, `"vida"*`},
+		{`vi"da`, `"vi"* "da"*`},
+		{"vida*", `"vida"*`},
+		{"-vida", `"vida"*`},
+		{"AB-12", `"ab"* "12"*`},
+		{"vida NOT somun", `"vida"* "not"* "somun"*`},
+		{"code:x (a OR b)", `"code"* "x"* "a"* "or"* "b"*`},
+		{`"*-^:()`, ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := searchMatchExpression(tt.in); got != tt.want {
+			t.Errorf("searchMatchExpression(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearchQuotesSyntaxCharacters(t *testing.T) {
+	dbManager := database.GetConnectionManager()
+	if err := dbManager.Connect(filepath.Join(t.TempDir(), "search.db")); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer dbManager.Close()
+
+	productService := NewProductService(dbManager, NewAuditService(dbManager))
+	if _, err := productService.Create(ProductDTO{Code: "AB-12", Name: "ŞİŞE Kapağı", CategoryID: 1, Unit: "adet"}); err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		found int
+	}{
+		{"sise kapagi", 1},
+		{`"şişe`, 1},
+		{"kap*", 1},
+		{"-ab 12", 1},
+		{"NOT sise", 0},
+		{"sise OR vida", 0},
+		{`"*-`, 0},
+	}
+	for _, tt := range tests {
+		products, err := productService.Search(tt.query, 0)
+		if err != nil {
+			t.Errorf("Search(%q) error: %v", tt.query, err)
+			continue
+		}
+		if len(products) != tt.found {
+			t.Errorf("Search(%q) found %d products, want %d", tt.query, len(products), tt.found)
+		}
+	}
+}
@@ -1,51 +1,121 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"stoktakip/internal/database"
 	"stoktakip/internal/models"
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // ProductDTO is the data transfer object for products
 type ProductDTO struct {
-	ID            uint      `json:"id"`
-	Code          string    `json:"code"`
-	Name          string    `json:"name"`
-	CategoryID    uint      `json:"category_id"`
-	Unit          string    `json:"unit"`
-	CriticalLimit int       `json:"critical_limit"`
-	Price         float64   `json:"price"`
-	CurrentStock  int       `json:"current_stock"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            uint            `json:"id"`
+	Code          string          `json:"code"`
+	Name          string          `json:"name"`
+	CategoryID    uint            `json:"category_id"`
+	UnitID        uint            `json:"unit_id"`
+	Unit          string          `json:"unit"`
+	CriticalLimit models.Quantity `json:"critical_limit"`
+	Price         models.Money    `json:"price"`
+	CurrentStock  models.Quantity `json:"current_stock"` // Total across all locations
+	StockValue    models.Money    `json:"stock_value"`   // CurrentStock * Price, read-only
+	OnOrder       models.Quantity `json:"on_order"`      // Still expected on sent purchase orders, read-only
+	Reserved      models.Quantity `json:"reserved"`      // Held by active reservations, read-only
+	Available     models.Quantity `json:"available"`     // CurrentStock - Reserved, read-only
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+
+	Locations []LocationStockDTO `json:"locations"` // Per-location stock, read-only
+}
+
+// ProductSupplierDTO is the data transfer object for the suppliers of a product
+type ProductSupplierDTO struct {
+	ID               uint            `json:"id"`
+	ProductID        uint            `json:"product_id"`
+	SupplierID       uint            `json:"supplier_id"`
+	Supplier         string          `json:"supplier"` // Supplier name, read-only
+	SupplierCode     string          `json:"supplier_code"`
+	LastPrice        models.Money    `json:"last_price"`
+	LeadTimeDays     int             `json:"lead_time_days"`
+	MinOrderQuantity models.Quantity `json:"min_order_quantity"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// Stock states a product list can be filtered by
+const (
+	StockStateOut = "out" // Nothing in stock
+	StockStateLow = "low" // In stock, at or below the critical limit
+	StockStateOK  = "ok"  // Above the critical limit
+)
+
+// ProductQuery selects a page of products. Zero filter values match everything.
+type ProductQuery struct {
+	PageQuery
+	Search     string `json:"search"` // Part of the code or name
+	CategoryID uint   `json:"category_id"`
+	StockState string `json:"stock_state"` // StockStateOut, StockStateLow or StockStateOK
+}
+
+// ProductPage is one page of a product list
+type ProductPage struct {
+	Items    []ProductDTO `json:"items"`
+	Total    int64        `json:"total"` // Matching products on all pages
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+}
+
+// productSortFields maps the sort fields of ProductQuery to SQL
+var productSortFields = map[string]string{
+	"code":           "products.code",
+	"name":           "products.name",
+	"category":       "(SELECT name FROM categories WHERE categories.id = products.category_id)",
+	"current_stock":  "products.current_stock",
+	"critical_limit": "products.critical_limit",
+	"price":          "products.price",
+	"stock_value":    "products.current_stock * products.price",
+	"created_at":     "products.created_at",
+	"updated_at":     "products.updated_at",
 }
 
 // ProductService handles product-related operations
 type ProductService struct {
 	dbManager *database.ConnectionManager
+	audit     *AuditService
 }
 
 // NewProductService creates a new product service
-func NewProductService(dbManager *database.ConnectionManager) *ProductService {
+func NewProductService(dbManager *database.ConnectionManager, audit *AuditService) *ProductService {
 	return &ProductService{
 		dbManager: dbManager,
+		audit:     audit,
 	}
 }
 
 // Helper function to convert model to DTO
 func (s *ProductService) toDTO(product *models.Product) ProductDTO {
+	var unitID uint
+	if product.UnitID != nil {
+		unitID = *product.UnitID
+	}
+
 	return ProductDTO{
 		ID:            product.ID,
 		Code:          product.Code,
 		Name:          product.Name,
 		CategoryID:    product.CategoryID,
+		UnitID:        unitID,
 		Unit:          product.Unit,
 		CriticalLimit: product.CriticalLimit,
 		Price:         product.Price,
 		CurrentStock:  product.CurrentStock,
+		StockValue:    product.CurrentStock.MulPrice(product.Price),
 		CreatedAt:     product.CreatedAt,
 		UpdatedAt:     product.UpdatedAt,
+		Locations:     []LocationStockDTO{},
 	}
 }
 
@@ -61,9 +131,87 @@ func (s *ProductService) GetAll() ([]ProductDTO, error) {
 		return nil, fmt.Errorf("failed to fetch products: %w", err)
 	}
 
+	return s.toDTOs(db, products)
+}
+
+// List returns one page of the products matching q with the total count
+func (s *ProductService) List(q ProductQuery) (*ProductPage, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+	if err := q.normalize(productSortFields, "name", SortAsc); err != nil {
+		return nil, err
+	}
+
+	query := db.Model(&models.Product{})
+	if search := strings.TrimSpace(q.Search); search != "" {
+		pattern := likePattern(search)
+		query = query.Where("products.code LIKE ? ESCAPE '\\' OR products.name LIKE ? ESCAPE '\\'", pattern, pattern)
+	}
+	if q.CategoryID != 0 {
+		query = query.Where("products.category_id = ?", q.CategoryID)
+	}
+	switch q.StockState {
+	case "":
+	case StockStateOut:
+		query = query.Where("products.current_stock <= 0")
+	case StockStateLow:
+		query = query.Where("products.current_stock <= products.critical_limit AND products.current_stock > 0")
+	case StockStateOK:
+		query = query.Where("products.current_stock > products.critical_limit AND products.current_stock > 0")
+	default:
+		return nil, fmt.Errorf("invalid stock state: %s", q.StockState)
+	}
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count products: %w", err)
+	}
+
+	var products []models.Product
+	if err := q.apply(query, productSortFields, "products.id").Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	items, err := s.toDTOs(db, products)
+	if err != nil {
+		return nil, err
+	}
+	return &ProductPage{Items: items, Total: total, Page: q.Page, PageSize: q.PageSize}, nil
+}
+
+// toDTOs converts products to DTOs including their per-location stock and
+// the quantities on order and reserved
+func (s *ProductService) toDTOs(db *gorm.DB, products []models.Product) ([]ProductDTO, error) {
+	ids := make([]uint, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+
+	stocks, err := loadLocationStocks(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	onOrder, err := loadOnOrder(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	reserved, err := loadReserved(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
 	dtos := make([]ProductDTO, len(products))
 	for i, product := range products {
 		dtos[i] = s.toDTO(&product)
+		if locations, ok := stocks[product.ID]; ok {
+			dtos[i].Locations = locations
+		}
+		dtos[i].OnOrder = onOrder[product.ID]
+		dtos[i].Reserved = reserved[product.ID]
+		dtos[i].Available = product.CurrentStock - reserved[product.ID]
 	}
 
 	return dtos, nil
@@ -81,8 +229,32 @@ func (s *ProductService) GetByID(id uint) (*ProductDTO, error) {
 		return nil, fmt.Errorf("product not found: %w", err)
 	}
 
-	dto := s.toDTO(&product)
-	return &dto, nil
+	dtos, err := s.toDTOs(db, []models.Product{product})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos[0], nil
+}
+
+// GetByCode returns a product by its code as DTO
+func (s *ProductService) GetByCode(code string) (*ProductDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var product models.Product
+	if err := db.Where("code = ?", code).First(&product).Error; err != nil {
+		return nil, fmt.Errorf("product '%s' not found: %w", code, err)
+	}
+
+	dtos, err := s.toDTOs(db, []models.Product{product})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos[0], nil
 }
 
 // Create creates a new product from DTO
@@ -97,28 +269,60 @@ func (s *ProductService) Create(dto ProductDTO) (*ProductDTO, error) {
 		return nil, fmt.Errorf("code and name are required")
 	}
 
+	var product *models.Product
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		product, err = s.insert(tx, dto)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(product)
+	return &resultDTO, nil
+}
+
+// insert validates and stores a new product with no stock inside tx
+func (s *ProductService) insert(tx *gorm.DB, dto ProductDTO) (*models.Product, error) {
+	unit, err := resolveUnit(tx, dto.UnitID, dto.Unit)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateAmounts(tx, dto, unit); err != nil {
+		return nil, err
+	}
+	if err := checkCategory(tx, dto.CategoryID); err != nil {
+		return nil, err
+	}
+
 	// Check if product with same code already exists
 	var existing models.Product
-	if err := db.Where("code = ?", dto.Code).First(&existing).Error; err == nil {
-		return nil, fmt.Errorf("product with code '%s' already exists", dto.Code)
+	if err := tx.Where("code = ?", dto.Code).First(&existing).Error; err == nil {
+		return nil, conflictf("product with code '%s' already exists", dto.Code)
 	}
 
 	product := &models.Product{
 		Code:          dto.Code,
 		Name:          dto.Name,
 		CategoryID:    dto.CategoryID,
-		Unit:          dto.Unit,
+		UnitID:        &unit.ID,
+		Unit:          unit.Name,
 		CriticalLimit: dto.CriticalLimit,
 		Price:         dto.Price,
 		CurrentStock:  0, // Initial stock is 0
 	}
 
-	if err := db.Create(product).Error; err != nil {
+	if err := tx.Create(product).Error; err != nil {
 		return nil, fmt.Errorf("failed to create product: %w", err)
 	}
+	if err := indexProduct(tx, product.ID); err != nil {
+		return nil, err
+	}
 
-	resultDTO := s.toDTO(product)
-	return &resultDTO, nil
+	if err := s.audit.record(tx, auditEntityProduct, product.ID, models.AuditActionCreate, nil, s.toDTO(product)); err != nil {
+		return nil, err
+	}
+	return product, nil
 }
 
 // Update updates a product from DTO
@@ -128,36 +332,89 @@ func (s *ProductService) Update(id uint, dto ProductDTO) (*ProductDTO, error) {
 		return nil, fmt.Errorf("no database connection")
 	}
 
-	var product models.Product
-	if err := db.First(&product, id).Error; err != nil {
-		return nil, fmt.Errorf("product not found: %w", err)
-	}
-
 	// Validate
 	if dto.Code == "" || dto.Name == "" {
 		return nil, fmt.Errorf("code and name are required")
 	}
 
-	// Check if another product with same code exists
-	var existing models.Product
-	if err := db.Where("code = ? AND id != ?", dto.Code, id).First(&existing).Error; err == nil {
-		return nil, fmt.Errorf("product with code '%s' already exists", dto.Code)
+	var product models.Product
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&product, id).Error; err != nil {
+			return fmt.Errorf("product not found: %w", err)
+		}
+		before := s.toDTO(&product)
+
+		unit, err := resolveUnit(tx, dto.UnitID, dto.Unit)
+		if err != nil {
+			return err
+		}
+		if err := s.validateAmounts(tx, dto, unit); err != nil {
+			return err
+		}
+		if err := checkCategory(tx, dto.CategoryID); err != nil {
+			return err
+		}
+
+		// Check if another product with same code exists
+		var existing models.Product
+		if err := tx.Where("code = ? AND id != ?", dto.Code, id).First(&existing).Error; err == nil {
+			return conflictf("product with code '%s' already exists", dto.Code)
+		}
+
+		// Stock is kept in the base unit, so it cannot change once stock has moved
+		if product.UnitID == nil || *product.UnitID != unit.ID {
+			var movementCount int64
+			if err := tx.Model(&models.StockMovement{}).Where("product_id = ?", id).Count(&movementCount).Error; err != nil {
+				return fmt.Errorf("failed to check movements: %w", err)
+			}
+			if movementCount > 0 && product.UnitID != nil {
+				return conflictf("cannot change the unit of a product with %d movements", movementCount)
+			}
+		}
+
+		// Update fields (but not current_stock, that's managed by movements)
+		product.Code = dto.Code
+		product.Name = dto.Name
+		product.CategoryID = dto.CategoryID
+		product.UnitID = &unit.ID
+		product.Unit = unit.Name
+		product.CriticalLimit = dto.CriticalLimit
+		product.Price = dto.Price
+
+		if err := tx.Save(&product).Error; err != nil {
+			return fmt.Errorf("failed to update product: %w", err)
+		}
+		if err := indexProduct(tx, product.ID); err != nil {
+			return err
+		}
+
+		return s.audit.record(tx, auditEntityProduct, product.ID, models.AuditActionUpdate, before, s.toDTO(&product))
+	}); err != nil {
+		return nil, err
 	}
 
-	// Update fields (but not current_stock, that's managed by movements)
-	product.Code = dto.Code
-	product.Name = dto.Name
-	product.CategoryID = dto.CategoryID
-	product.Unit = dto.Unit
-	product.CriticalLimit = dto.CriticalLimit
-	product.Price = dto.Price
+	resultDTO := s.toDTO(&product)
+	return &resultDTO, nil
+}
 
-	if err := db.Save(&product).Error; err != nil {
-		return nil, fmt.Errorf("failed to update product: %w", err)
+// validateAmounts checks the price and critical limit of a product DTO
+func (s *ProductService) validateAmounts(db *gorm.DB, dto ProductDTO, unit *models.Unit) error {
+	if dto.Price < 0 {
+		return fmt.Errorf("price cannot be negative")
 	}
+	if dto.CriticalLimit < 0 {
+		return fmt.Errorf("critical limit cannot be negative")
+	}
+	return checkPrecision(db, unit.Name, dto.CriticalLimit)
+}
 
-	resultDTO := s.toDTO(&product)
-	return &resultDTO, nil
+// checkCategory checks that the category a product is put in exists
+func checkCategory(db *gorm.DB, categoryID uint) error {
+	var category models.Category
+	if err := db.Select("id").First(&category, categoryID).Error; err != nil {
+		return fmt.Errorf("category not found: %w", err)
+	}
+	return nil
 }
 
 // Delete deletes a product by ID
@@ -167,40 +424,253 @@ func (s *ProductService) Delete(id uint) error {
 		return fmt.Errorf("no database connection")
 	}
 
-	// Check if product has movements
-	var movementCount int64
-	if err := db.Model(&models.StockMovement{}).Where("product_id = ?", id).Count(&movementCount).Error; err != nil {
-		return fmt.Errorf("failed to check movements: %w", err)
-	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.First(&product, id).Error; err != nil {
+			return fmt.Errorf("product not found: %w", err)
+		}
+
+		// Check if product has movements
+		var movementCount int64
+		if err := tx.Model(&models.StockMovement{}).Where("product_id = ?", id).Count(&movementCount).Error; err != nil {
+			return fmt.Errorf("failed to check movements: %w", err)
+		}
+
+		if movementCount > 0 {
+			return conflictf("cannot delete product with %d movements", movementCount)
+		}
+
+		// Open counts would post an adjustment for it
+		var countCount int64
+		if err := tx.Table("stock_count_lines AS l").
+			Joins("JOIN stock_counts AS c ON c.id = l.count_id").
+			Where("l.product_id = ? AND c.status = ?", id, models.StockCountOpen).
+			Count(&countCount).Error; err != nil {
+			return fmt.Errorf("failed to check stock counts: %w", err)
+		}
+		if countCount > 0 {
+			return conflictf("cannot delete product in %d open stock counts", countCount)
+		}
+
+		var orderCount int64
+		if err := tx.Model(&models.PurchaseOrderLine{}).Where("product_id = ?", id).Distinct("order_id").Count(&orderCount).Error; err != nil {
+			return fmt.Errorf("failed to check purchase orders: %w", err)
+		}
+		if orderCount > 0 {
+			return conflictf("cannot delete product with %d purchase orders", orderCount)
+		}
+		if err := tx.Model(&models.SalesOrderLine{}).Where("product_id = ?", id).Distinct("order_id").Count(&orderCount).Error; err != nil {
+			return fmt.Errorf("failed to check sales orders: %w", err)
+		}
+		if orderCount > 0 {
+			return conflictf("cannot delete product with %d sales orders", orderCount)
+		}
+
+		// Delete product
+		if err := tx.Delete(&models.Product{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete product: %w", err)
+		}
+		if err := unindexProduct(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Where("product_id = ?", id).Delete(&models.ProductBarcode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete barcodes: %w", err)
+		}
+		if err := tx.Where("product_id = ?", id).Delete(&models.ProductSupplier{}).Error; err != nil {
+			return fmt.Errorf("failed to delete product suppliers: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntityProduct, product.ID, models.AuditActionDelete, s.toDTO(&product), nil)
+	})
+}
 
-	if movementCount > 0 {
-		return fmt.Errorf("cannot delete product with %d movements", movementCount)
+// GetLowStock returns products with low stock
+func (s *ProductService) GetLowStock() ([]ProductDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
 	}
 
-	// Delete product
-	if err := db.Delete(&models.Product{}, id).Error; err != nil {
-		return fmt.Errorf("failed to delete product: %w", err)
+	var products []models.Product
+	if err := db.Where("current_stock <= critical_limit AND current_stock > 0").Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch low stock products: %w", err)
 	}
 
-	return nil
+	return s.toDTOs(db, products)
 }
 
-// GetLowStock returns products with low stock
-func (s *ProductService) GetLowStock() ([]ProductDTO, error) {
+// GetLowStockAtLocation returns products whose stock at a location is at or below the critical limit
+func (s *ProductService) GetLowStockAtLocation(locationID uint) ([]ProductDTO, error) {
 	db := s.dbManager.GetDB()
 	if db == nil {
 		return nil, fmt.Errorf("no database connection")
 	}
 
 	var products []models.Product
-	if err := db.Where("current_stock <= critical_limit AND current_stock > 0").Find(&products).Error; err != nil {
+	if err := db.Joins("JOIN stock_balances AS b ON b.product_id = products.id AND b.location_id = ?", locationID).
+		Where("b.quantity <= products.critical_limit AND b.quantity > 0").
+		Order("products.name ASC").
+		Find(&products).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch low stock products: %w", err)
 	}
 
-	dtos := make([]ProductDTO, len(products))
-	for i, product := range products {
-		dtos[i] = s.toDTO(&product)
+	return s.toDTOs(db, products)
+}
+
+// supplierToDTO converts a product supplier with its Supplier loaded to DTO
+func (s *ProductService) supplierToDTO(link *models.ProductSupplier) ProductSupplierDTO {
+	return ProductSupplierDTO{
+		ID:               link.ID,
+		ProductID:        link.ProductID,
+		SupplierID:       link.SupplierID,
+		Supplier:         link.Supplier.Name,
+		SupplierCode:     link.SupplierCode,
+		LastPrice:        link.LastPrice,
+		LeadTimeDays:     link.LeadTimeDays,
+		MinOrderQuantity: link.MinOrderQuantity,
+		UpdatedAt:        link.UpdatedAt,
 	}
+}
 
+// GetSuppliers returns the suppliers of a product, cheapest first. Suppliers
+// without a price come last.
+func (s *ProductService) GetSuppliers(productID uint) ([]ProductSupplierDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var links []models.ProductSupplier
+	if err := db.Preload("Supplier").
+		Joins("JOIN suppliers ON suppliers.id = product_suppliers.supplier_id").
+		Where("product_suppliers.product_id = ?", productID).
+		Order("product_suppliers.last_price = 0, product_suppliers.last_price ASC, product_suppliers.lead_time_days ASC, suppliers.name ASC").
+		Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch product suppliers: %w", err)
+	}
+
+	dtos := make([]ProductSupplierDTO, len(links))
+	for i := range links {
+		dtos[i] = s.supplierToDTO(&links[i])
+	}
 	return dtos, nil
 }
+
+// GetCheapestSupplier returns the supplier with the lowest last price for a
+// product, preferring the shorter lead time on a tie. It returns nil when no
+// supplier of the product has a price yet.
+func (s *ProductService) GetCheapestSupplier(productID uint) (*ProductSupplierDTO, error) {
+	suppliers, err := s.GetSuppliers(productID)
+	if err != nil {
+		return nil, err
+	}
+	if len(suppliers) == 0 || suppliers[0].LastPrice == 0 {
+		return nil, nil
+	}
+	return &suppliers[0], nil
+}
+
+// SetSupplier links a supplier to a product or updates the terms of an
+// existing link
+func (s *ProductService) SetSupplier(dto ProductSupplierDTO) (*ProductSupplierDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var link models.ProductSupplier
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.First(&product, dto.ProductID).Error; err != nil {
+			return fmt.Errorf("product not found: %w", err)
+		}
+		var supplier models.Supplier
+		if err := tx.First(&supplier, dto.SupplierID).Error; err != nil {
+			return fmt.Errorf("supplier not found: %w", err)
+		}
+
+		if dto.LastPrice < 0 {
+			return fmt.Errorf("last price cannot be negative")
+		}
+		if dto.LeadTimeDays < 0 {
+			return fmt.Errorf("lead time cannot be negative")
+		}
+		if dto.MinOrderQuantity < 0 {
+			return fmt.Errorf("minimum order quantity cannot be negative")
+		}
+		if err := checkPrecision(tx, product.Unit, dto.MinOrderQuantity); err != nil {
+			return err
+		}
+
+		action := models.AuditActionUpdate
+		var before interface{}
+		err := tx.Where("product_id = ? AND supplier_id = ?", product.ID, supplier.ID).First(&link).Error
+		if err == nil {
+			link.Supplier = supplier
+			before = s.supplierToDTO(&link)
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			action = models.AuditActionCreate
+			link = models.ProductSupplier{ProductID: product.ID, SupplierID: supplier.ID}
+		} else {
+			return fmt.Errorf("failed to fetch product supplier: %w", err)
+		}
+
+		link.SupplierCode = strings.TrimSpace(dto.SupplierCode)
+		link.LastPrice = dto.LastPrice
+		link.LeadTimeDays = dto.LeadTimeDays
+		link.MinOrderQuantity = dto.MinOrderQuantity
+		if err := tx.Omit("Product", "Supplier").Save(&link).Error; err != nil {
+			return fmt.Errorf("failed to save product supplier: %w", err)
+		}
+		link.Supplier = supplier
+
+		return s.audit.record(tx, auditEntityProductSupplier, link.ID, action, before, s.supplierToDTO(&link))
+	}); err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.supplierToDTO(&link)
+	return &resultDTO, nil
+}
+
+// RemoveSupplier unlinks a supplier from a product
+func (s *ProductService) RemoveSupplier(productID, supplierID uint) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var link models.ProductSupplier
+		if err := tx.Preload("Supplier").Where("product_id = ? AND supplier_id = ?", productID, supplierID).
+			First(&link).Error; err != nil {
+			return fmt.Errorf("product supplier not found: %w", err)
+		}
+
+		if err := tx.Delete(&models.ProductSupplier{}, link.ID).Error; err != nil {
+			return fmt.Errorf("failed to delete product supplier: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntityProductSupplier, link.ID, models.AuditActionDelete, s.supplierToDTO(&link), nil)
+	})
+}
+
+// recordPurchasePrice stores the price a product was last bought at from a
+// supplier, linking the two if they are not yet, inside tx
+func recordPurchasePrice(tx *gorm.DB, productID, supplierID uint, price models.Money) error {
+	result := tx.Model(&models.ProductSupplier{}).
+		Where("product_id = ? AND supplier_id = ?", productID, supplierID).
+		Update("last_price", price)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update last purchase price: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	link := models.ProductSupplier{ProductID: productID, SupplierID: supplierID, LastPrice: price}
+	if err := tx.Omit("Product", "Supplier").Create(&link).Error; err != nil {
+		return fmt.Errorf("failed to link product supplier: %w", err)
+	}
+	return nil
+}
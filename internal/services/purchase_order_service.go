@@ -0,0 +1,594 @@
+package services
+
+import (
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// purchaseOrderPrefix starts every purchase order number
+const purchaseOrderPrefix = "PO-"
+
+// PurchaseOrderDTO is the data transfer object for purchase orders
+type PurchaseOrderDTO struct {
+	ID           uint                   `json:"id"`
+	Number       string                 `json:"number"` // Assigned on create, read-only
+	SupplierID   uint                   `json:"supplier_id"`
+	Supplier     string                 `json:"supplier"`    // Read-only
+	Status       string                 `json:"status"`      // "DRAFT", "SENT", "PARTIAL", "RECEIVED" or "CANCELLED", read-only
+	LocationID   uint                   `json:"location_id"` // Where goods are received; 0 means the default location
+	Location     string                 `json:"location"`    // Read-only
+	ExpectedDate *time.Time             `json:"expected_date"`
+	Note         string                 `json:"note"`
+	CreatedBy    string                 `json:"created_by"` // Read-only
+	SentAt       *time.Time             `json:"sent_at"`
+	ClosedAt     *time.Time             `json:"closed_at"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+	Lines        []PurchaseOrderLineDTO `json:"lines"`
+	Total        models.Money           `json:"total"` // Ordered quantity * unit price over all lines, read-only
+}
+
+// PurchaseOrderLineDTO is one product of a purchase order. Outstanding,
+// Over and Short track deliveries against the ordered quantity.
+type PurchaseOrderLineDTO struct {
+	ID          uint            `json:"id"`
+	ProductID   uint            `json:"product_id"`
+	Code        string          `json:"code"` // Read-only
+	Name        string          `json:"name"` // Read-only
+	Unit        string          `json:"unit"` // Read-only
+	Quantity    models.Quantity `json:"quantity"`
+	Received    models.Quantity `json:"received"` // Read-only
+	UnitPrice   models.Money    `json:"unit_price"`
+	Note        string          `json:"note"`
+	Outstanding models.Quantity `json:"outstanding"` // Still expected while the order is open, read-only
+	Over        models.Quantity `json:"over"`        // Received beyond the ordered quantity, read-only
+	Short       models.Quantity `json:"short"`       // Never delivered on a cancelled order, read-only
+	Total       models.Money    `json:"total"`       // Quantity * UnitPrice, read-only
+}
+
+// PurchaseReceiptLine receives a quantity of a purchase order line, in the
+// product's base unit
+type PurchaseReceiptLine struct {
+	LineID     uint            `json:"line_id"`
+	Quantity   models.Quantity `json:"quantity"`
+	LocationID uint            `json:"location_id"` // 0 means the order's location
+}
+
+// PurchaseOrderService handles purchase orders and receiving them
+type PurchaseOrderService struct {
+	dbManager *database.ConnectionManager
+	audit     *AuditService
+	auth      *AuthService
+	movements *MovementService
+}
+
+// NewPurchaseOrderService creates a new purchase order service
+func NewPurchaseOrderService(dbManager *database.ConnectionManager, audit *AuditService, auth *AuthService, movements *MovementService) *PurchaseOrderService {
+	return &PurchaseOrderService{
+		dbManager: dbManager,
+		audit:     audit,
+		auth:      auth,
+		movements: movements,
+	}
+}
+
+// Helper function to convert model to DTO, with its relations loaded
+func (s *PurchaseOrderService) toDTO(order *models.PurchaseOrder) PurchaseOrderDTO {
+	dto := PurchaseOrderDTO{
+		ID:           order.ID,
+		Number:       order.Number,
+		SupplierID:   order.SupplierID,
+		Supplier:     order.Supplier.Name,
+		Status:       string(order.Status),
+		LocationID:   order.LocationID,
+		Location:     order.Location.Name,
+		ExpectedDate: order.ExpectedDate,
+		Note:         order.Note,
+		SentAt:       order.SentAt,
+		ClosedAt:     order.ClosedAt,
+		CreatedAt:    order.CreatedAt,
+		UpdatedAt:    order.UpdatedAt,
+		Lines:        make([]PurchaseOrderLineDTO, len(order.Lines)),
+	}
+	if order.Creator != nil {
+		dto.CreatedBy = order.Creator.Username
+	}
+
+	for i, line := range order.Lines {
+		lineDTO := PurchaseOrderLineDTO{
+			ID:        line.ID,
+			ProductID: line.ProductID,
+			Code:      line.Product.Code,
+			Name:      line.Product.Name,
+			Unit:      line.Product.Unit,
+			Quantity:  line.Quantity,
+			Received:  line.Received,
+			UnitPrice: line.UnitPrice,
+			Note:      line.Note,
+			Total:     line.Quantity.MulPrice(line.UnitPrice),
+		}
+		if line.Received > line.Quantity {
+			lineDTO.Over = line.Received - line.Quantity
+		} else if order.Status.IsOpen() {
+			lineDTO.Outstanding = line.Quantity - line.Received
+		} else if order.Status == models.PurchaseOrderCancelled {
+			lineDTO.Short = line.Quantity - line.Received
+		}
+		dto.Lines[i] = lineDTO
+		dto.Total += lineDTO.Total
+	}
+	return dto
+}
+
+// preloadOrder loads the relations PurchaseOrderDTO shows
+func preloadOrder(db *gorm.DB) *gorm.DB {
+	return db.Preload("Supplier").Preload("Location").Preload("Creator").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("purchase_order_lines.id") }).
+		Preload("Lines.Product")
+}
+
+// GetAll returns purchase orders, newest first; a status other than ""
+// limits them to that status
+func (s *PurchaseOrderService) GetAll(status string) ([]PurchaseOrderDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	query := preloadOrder(db)
+	if status != "" {
+		if !models.PurchaseOrderStatus(status).IsValid() {
+			return nil, fmt.Errorf("invalid purchase order status: %s", status)
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []models.PurchaseOrder
+	if err := query.Order("id DESC").Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch purchase orders: %w", err)
+	}
+
+	dtos := make([]PurchaseOrderDTO, len(orders))
+	for i := range orders {
+		dtos[i] = s.toDTO(&orders[i])
+	}
+	return dtos, nil
+}
+
+// GetByID returns a purchase order by ID as DTO
+func (s *PurchaseOrderService) GetByID(id uint) (*PurchaseOrderDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	order, err := s.load(db, id)
+	if err != nil {
+		return nil, err
+	}
+	dto := s.toDTO(order)
+	return &dto, nil
+}
+
+// load loads an order with its relations
+func (s *PurchaseOrderService) load(db *gorm.DB, id uint) (*models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	if err := preloadOrder(db).First(&order, id).Error; err != nil {
+		return nil, fmt.Errorf("purchase order not found: %w", err)
+	}
+	return &order, nil
+}
+
+// Create creates a draft purchase order with its lines
+func (s *PurchaseOrderService) Create(dto PurchaseOrderDTO) (*PurchaseOrderDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var order *models.PurchaseOrder
+	err := db.Transaction(func(tx *gorm.DB) error {
+		number, err := nextPurchaseOrderNumber(tx)
+		if err != nil {
+			return err
+		}
+
+		order = &models.PurchaseOrder{
+			Number:    number,
+			Status:    models.PurchaseOrderDraft,
+			CreatedBy: s.auth.CurrentUserID(),
+		}
+		if err := s.assign(tx, order, dto); err != nil {
+			return err
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return fmt.Errorf("failed to create purchase order: %w", err)
+		}
+		if err := s.insertLines(tx, order.ID, dto.Lines); err != nil {
+			return err
+		}
+
+		if order, err = s.load(tx, order.ID); err != nil {
+			return err
+		}
+		return s.audit.record(tx, auditEntityPurchaseOrder, order.ID, models.AuditActionCreate, nil, s.toDTO(order))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(order)
+	return &resultDTO, nil
+}
+
+// Update replaces the header and lines of a draft purchase order
+func (s *PurchaseOrderService) Update(id uint, dto PurchaseOrderDTO) (*PurchaseOrderDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var order *models.PurchaseOrder
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if order, err = s.load(tx, id); err != nil {
+			return err
+		}
+		if order.Status != models.PurchaseOrderDraft {
+			return conflictf("cannot change purchase order %s: it is %s", order.Number, order.Status)
+		}
+		before := s.toDTO(order)
+
+		if err := s.assign(tx, order, dto); err != nil {
+			return err
+		}
+		if err := tx.Model(order).Updates(map[string]interface{}{
+			"supplier_id":   order.SupplierID,
+			"location_id":   order.LocationID,
+			"expected_date": order.ExpectedDate,
+			"note":          order.Note,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update purchase order: %w", err)
+		}
+		if err := tx.Where("order_id = ?", order.ID).Delete(&models.PurchaseOrderLine{}).Error; err != nil {
+			return fmt.Errorf("failed to update purchase order lines: %w", err)
+		}
+		if err := s.insertLines(tx, order.ID, dto.Lines); err != nil {
+			return err
+		}
+
+		if order, err = s.load(tx, order.ID); err != nil {
+			return err
+		}
+		return s.audit.record(tx, auditEntityPurchaseOrder, order.ID, models.AuditActionUpdate, before, s.toDTO(order))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(order)
+	return &resultDTO, nil
+}
+
+// assign validates the header of dto and copies it to order
+func (s *PurchaseOrderService) assign(tx *gorm.DB, order *models.PurchaseOrder, dto PurchaseOrderDTO) error {
+	if dto.SupplierID == 0 {
+		return fmt.Errorf("supplier is required")
+	}
+	if err := tx.First(&models.Supplier{}, dto.SupplierID).Error; err != nil {
+		return fmt.Errorf("supplier not found: %w", err)
+	}
+
+	locationID := dto.LocationID
+	if locationID == 0 {
+		var err error
+		if locationID, err = defaultLocationID(tx); err != nil {
+			return err
+		}
+	}
+	if err := tx.First(&models.Location{}, locationID).Error; err != nil {
+		return fmt.Errorf("location not found: %w", err)
+	}
+
+	order.SupplierID = dto.SupplierID
+	order.LocationID = locationID
+	order.ExpectedDate = dto.ExpectedDate
+	order.Note = dto.Note
+	return nil
+}
+
+// insertLines validates and stores the lines of an order inside tx
+func (s *PurchaseOrderService) insertLines(tx *gorm.DB, orderID uint, dtos []PurchaseOrderLineDTO) error {
+	if len(dtos) == 0 {
+		return fmt.Errorf("purchase order needs at least one line")
+	}
+
+	seen := make(map[uint]bool, len(dtos))
+	for _, dto := range dtos {
+		var product models.Product
+		if err := tx.First(&product, dto.ProductID).Error; err != nil {
+			return fmt.Errorf("product not found: %w", err)
+		}
+		if seen[product.ID] {
+			return fmt.Errorf("product '%s' is on the order more than once", product.Code)
+		}
+		seen[product.ID] = true
+
+		if dto.Quantity <= 0 {
+			return fmt.Errorf("quantity of product '%s' must be greater than zero", product.Code)
+		}
+		if err := checkPrecision(tx, product.Unit, dto.Quantity); err != nil {
+			return err
+		}
+		if dto.UnitPrice < 0 {
+			return fmt.Errorf("unit price of product '%s' cannot be negative", product.Code)
+		}
+
+		line := &models.PurchaseOrderLine{
+			OrderID:   orderID,
+			ProductID: product.ID,
+			Quantity:  dto.Quantity,
+			UnitPrice: dto.UnitPrice,
+			Note:      dto.Note,
+		}
+		if err := tx.Create(line).Error; err != nil {
+			return fmt.Errorf("failed to create purchase order line: %w", err)
+		}
+	}
+	return nil
+}
+
+// nextPurchaseOrderNumber returns the number after the highest one in use
+func nextPurchaseOrderNumber(tx *gorm.DB) (string, error) {
+	var last int64
+	if err := tx.Model(&models.PurchaseOrder{}).
+		Where("number LIKE ?", purchaseOrderPrefix+"%").
+		Select("COALESCE(MAX(CAST(SUBSTR(number, ?) AS INTEGER)), 0)", len(purchaseOrderPrefix)+1).
+		Scan(&last).Error; err != nil {
+		return "", fmt.Errorf("failed to number purchase order: %w", err)
+	}
+	return fmt.Sprintf("%s%06d", purchaseOrderPrefix, last+1), nil
+}
+
+// Delete deletes a draft purchase order
+func (s *PurchaseOrderService) Delete(id uint) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		order, err := s.load(tx, id)
+		if err != nil {
+			return err
+		}
+		if order.Status != models.PurchaseOrderDraft {
+			return conflictf("cannot delete purchase order %s: it is %s and only drafts can be deleted", order.Number, order.Status)
+		}
+
+		if err := tx.Where("order_id = ?", id).Delete(&models.PurchaseOrderLine{}).Error; err != nil {
+			return fmt.Errorf("failed to delete purchase order lines: %w", err)
+		}
+		if err := tx.Delete(&models.PurchaseOrder{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete purchase order: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntityPurchaseOrder, order.ID, models.AuditActionDelete, s.toDTO(order), nil)
+	})
+}
+
+// Send marks a draft order as placed with the supplier; its lines count as
+// on order from then on
+func (s *PurchaseOrderService) Send(id uint) (*PurchaseOrderDTO, error) {
+	return s.transition(id, func(tx *gorm.DB, order *models.PurchaseOrder) error {
+		if order.Status != models.PurchaseOrderDraft {
+			return fmt.Errorf("cannot send purchase order %s: it is %s", order.Number, order.Status)
+		}
+		now := time.Now()
+		return tx.Model(order).Updates(map[string]interface{}{
+			"status":  models.PurchaseOrderSent,
+			"sent_at": now,
+		}).Error
+	})
+}
+
+// Cancel closes an order that has not been received in full. Goods already
+// received stay in stock and the rest of each line is reported as short.
+func (s *PurchaseOrderService) Cancel(id uint) (*PurchaseOrderDTO, error) {
+	return s.transition(id, func(tx *gorm.DB, order *models.PurchaseOrder) error {
+		if order.Status != models.PurchaseOrderDraft && !order.Status.IsOpen() {
+			return fmt.Errorf("cannot cancel purchase order %s: it is %s", order.Number, order.Status)
+		}
+		now := time.Now()
+		return tx.Model(order).Updates(map[string]interface{}{
+			"status":    models.PurchaseOrderCancelled,
+			"closed_at": now,
+		}).Error
+	})
+}
+
+// transition changes the state of an order inside a transaction and records it
+func (s *PurchaseOrderService) transition(id uint, change func(tx *gorm.DB, order *models.PurchaseOrder) error) (*PurchaseOrderDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var order *models.PurchaseOrder
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if order, err = s.load(tx, id); err != nil {
+			return err
+		}
+		before := s.toDTO(order)
+
+		if err := change(tx, order); err != nil {
+			return err
+		}
+
+		if order, err = s.load(tx, id); err != nil {
+			return err
+		}
+		return s.audit.record(tx, auditEntityPurchaseOrder, order.ID, models.AuditActionUpdate, before, s.toDTO(order))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(order)
+	return &resultDTO, nil
+}
+
+// Receive books delivered goods as IN movements linked to their order lines,
+// all in one transaction. More than ordered may be received; the excess is
+// reported as over-delivery. The order becomes RECEIVED once every line is
+// received in full and PARTIAL before that.
+func (s *PurchaseOrderService) Receive(id uint, receipts []PurchaseReceiptLine, note string) (*PurchaseOrderDTO, error) {
+	if len(receipts) == 0 {
+		return nil, fmt.Errorf("nothing to receive")
+	}
+
+	return s.transition(id, func(tx *gorm.DB, order *models.PurchaseOrder) error {
+		if !order.Status.IsOpen() {
+			return fmt.Errorf("cannot receive purchase order %s: it is %s", order.Number, order.Status)
+		}
+
+		lines := make(map[uint]models.PurchaseOrderLine, len(order.Lines))
+		for _, line := range order.Lines {
+			lines[line.ID] = line
+		}
+		if note == "" {
+			note = fmt.Sprintf("%s, %s", order.Number, order.Supplier.Name)
+		}
+
+		for _, receipt := range receipts {
+			line, ok := lines[receipt.LineID]
+			if !ok {
+				return fmt.Errorf("line %d not found on purchase order %s", receipt.LineID, order.Number)
+			}
+			if receipt.Quantity <= 0 {
+				return fmt.Errorf("received quantity of product '%s' must be greater than zero", line.Product.Code)
+			}
+
+			locationID := receipt.LocationID
+			if locationID == 0 {
+				locationID = order.LocationID
+			}
+			movement, err := s.movements.build(tx, MovementDTO{
+				ProductID:  line.ProductID,
+				LocationID: locationID,
+				Type:       string(models.MovementTypeIn),
+				Quantity:   receipt.Quantity,
+				Note:       note,
+			})
+			if err != nil {
+				return err
+			}
+			lineID := line.ID
+			movement.PurchaseOrderLineID = &lineID
+			if err := s.movements.save(tx, movement); err != nil {
+				return fmt.Errorf("cannot receive product '%s': %w", line.Product.Code, err)
+			}
+
+			if err := tx.Model(&models.PurchaseOrderLine{}).Where("id = ?", line.ID).
+				Update("received", gorm.Expr("received + ?", movement.Quantity)).Error; err != nil {
+				return fmt.Errorf("failed to update received quantity: %w", err)
+			}
+			if line.UnitPrice > 0 {
+				if err := recordPurchasePrice(tx, line.ProductID, order.SupplierID, line.UnitPrice); err != nil {
+					return err
+				}
+			}
+		}
+
+		return refreshOrderStatus(tx, order.ID)
+	})
+}
+
+// unreceive takes back quantity from the received total of an order line
+// when its IN movement is deleted, inside tx
+func unreceive(tx *gorm.DB, lineID uint, quantity models.Quantity) error {
+	var line models.PurchaseOrderLine
+	if err := tx.First(&line, lineID).Error; err != nil {
+		return fmt.Errorf("purchase order line not found: %w", err)
+	}
+	if err := tx.Model(&line).Update("received", gorm.Expr("received - ?", quantity)).Error; err != nil {
+		return fmt.Errorf("failed to update received quantity: %w", err)
+	}
+	return refreshOrderStatus(tx, line.OrderID)
+}
+
+// refreshOrderStatus sets an open or received order to SENT, PARTIAL or
+// RECEIVED from what its lines have received, inside tx
+func refreshOrderStatus(tx *gorm.DB, orderID uint) error {
+	var order models.PurchaseOrder
+	if err := tx.Preload("Lines").First(&order, orderID).Error; err != nil {
+		return fmt.Errorf("purchase order not found: %w", err)
+	}
+	if !order.Status.IsOpen() && order.Status != models.PurchaseOrderReceived {
+		return nil
+	}
+
+	complete, started := true, false
+	for _, line := range order.Lines {
+		if line.Received < line.Quantity {
+			complete = false
+		}
+		if line.Received > 0 {
+			started = true
+		}
+	}
+
+	status := models.PurchaseOrderSent
+	switch {
+	case complete:
+		status = models.PurchaseOrderReceived
+	case started:
+		status = models.PurchaseOrderPartial
+	}
+	if status == order.Status {
+		return nil
+	}
+
+	updates := map[string]interface{}{"status": status, "closed_at": nil}
+	if status == models.PurchaseOrderReceived {
+		updates["closed_at"] = time.Now()
+	}
+	if err := tx.Model(&order).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update purchase order status: %w", err)
+	}
+	return nil
+}
+
+// loadOnOrder returns the quantity still expected on sent and partially
+// received orders for the given products, keyed by product ID
+func loadOnOrder(db *gorm.DB, productIDs []uint) (map[uint]models.Quantity, error) {
+	onOrder := make(map[uint]models.Quantity)
+	if len(productIDs) == 0 {
+		return onOrder, nil
+	}
+
+	var rows []struct {
+		ProductID uint
+		Quantity  models.Quantity
+	}
+	err := db.Table("purchase_order_lines AS l").
+		Select("l.product_id, SUM(MAX(l.quantity - l.received, 0)) AS quantity").
+		Joins("JOIN purchase_orders AS o ON o.id = l.order_id").
+		Where("l.product_id IN ? AND o.status IN ?", productIDs,
+			[]models.PurchaseOrderStatus{models.PurchaseOrderSent, models.PurchaseOrderPartial}).
+		Group("l.product_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch quantities on order: %w", err)
+	}
+
+	for _, row := range rows {
+		onOrder[row.ProductID] = row.Quantity
+	}
+	return onOrder, nil
+}
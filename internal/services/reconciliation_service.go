@@ -0,0 +1,287 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockDiscrepancy describes a product whose stored stock differs from its movements
+type StockDiscrepancy struct {
+	ProductID     uint            `json:"product_id"`
+	ProductCode   string          `json:"product_code"`
+	ProductName   string          `json:"product_name"`
+	StoredStock   models.Quantity `json:"stored_stock"`   // Product.CurrentStock
+	ComputedStock models.Quantity `json:"computed_stock"` // SUM(IN) - SUM(OUT)
+	Difference    models.Quantity `json:"difference"`     // StoredStock - ComputedStock
+}
+
+// LocationStockDiscrepancy describes a per-location balance that differs from its movements
+type LocationStockDiscrepancy struct {
+	ProductID     uint            `json:"product_id"`
+	LocationID    uint            `json:"location_id"`
+	StoredStock   models.Quantity `json:"stored_stock"`   // StockBalance.Quantity
+	ComputedStock models.Quantity `json:"computed_stock"` // Net of IN, OUT and TRANSFER at the location
+	Difference    models.Quantity `json:"difference"`     // StoredStock - ComputedStock
+}
+
+// StockReconciliationReport is the result of a stock verification run
+type StockReconciliationReport struct {
+	CheckedAt            time.Time                  `json:"checked_at"`
+	ProductCount         int                        `json:"product_count"`
+	Discrepancies        []StockDiscrepancy         `json:"discrepancies"`
+	BalanceDiscrepancies []LocationStockDiscrepancy `json:"balance_discrepancies"`
+	Fixed                bool                       `json:"fixed"` // Stored values were corrected
+}
+
+// ReconciliationService verifies Product.CurrentStock against the movement ledger
+type ReconciliationService struct {
+	dbManager  *database.ConnectionManager
+	audit      *AuditService
+	mutex      sync.RWMutex
+	lastReport *StockReconciliationReport
+}
+
+// NewReconciliationService creates a new reconciliation service
+func NewReconciliationService(dbManager *database.ConnectionManager, audit *AuditService) *ReconciliationService {
+	return &ReconciliationService{
+		dbManager: dbManager,
+		audit:     audit,
+	}
+}
+
+// stockFixAudit is the part of a product a fix corrects, as audited
+type stockFixAudit struct {
+	CurrentStock models.Quantity `json:"current_stock"`
+}
+
+// balanceFixAudit is the location balance of a product a fix corrects, as audited
+type balanceFixAudit struct {
+	LocationID uint            `json:"location_id"`
+	Quantity   models.Quantity `json:"quantity"`
+}
+
+// ledgerRow holds the stored and computed stock of one product
+type ledgerRow struct {
+	ID            uint
+	Code          string
+	Name          string
+	CurrentStock  models.Quantity
+	ComputedStock models.Quantity
+}
+
+// balanceKey identifies one product at one location
+type balanceKey struct {
+	ProductID  uint
+	LocationID uint
+}
+
+// Verify recomputes every product's stock and reports the ones that differ
+func (s *ReconciliationService) Verify() (*StockReconciliationReport, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	return s.run(db, false)
+}
+
+// Fix recomputes every product's stock and corrects the stored values in one transaction
+func (s *ReconciliationService) Fix() (*StockReconciliationReport, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	return s.run(db, true)
+}
+
+// GetLastReport returns the most recent report, including the automatic check on connect
+func (s *ReconciliationService) GetLastReport() *StockReconciliationReport {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.lastReport
+}
+
+// VerifyOnConnect is a database.ConnectHook that checks the ledger of a freshly opened database
+func (s *ReconciliationService) VerifyOnConnect(db *gorm.DB) {
+	report, err := s.run(db, false)
+	if err != nil {
+		log.Printf("Warning: Stock verification failed: %v", err)
+		return
+	}
+
+	if len(report.Discrepancies) > 0 || len(report.BalanceDiscrepancies) > 0 {
+		log.Printf("Warning: %d product total(s) and %d location balance(s) do not match their movements",
+			len(report.Discrepancies), len(report.BalanceDiscrepancies))
+	}
+}
+
+// run performs the reconciliation inside a single transaction
+func (s *ReconciliationService) run(db *gorm.DB, fix bool) (*StockReconciliationReport, error) {
+	report := &StockReconciliationReport{
+		CheckedAt:            time.Now(),
+		Discrepancies:        []StockDiscrepancy{},
+		BalanceDiscrepancies: []LocationStockDiscrepancy{},
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		rows, err := s.computeLedger(tx)
+		if err != nil {
+			return err
+		}
+
+		report.ProductCount = len(rows)
+		for _, row := range rows {
+			if row.CurrentStock == row.ComputedStock {
+				continue
+			}
+
+			report.Discrepancies = append(report.Discrepancies, StockDiscrepancy{
+				ProductID:     row.ID,
+				ProductCode:   row.Code,
+				ProductName:   row.Name,
+				StoredStock:   row.CurrentStock,
+				ComputedStock: row.ComputedStock,
+				Difference:    row.CurrentStock - row.ComputedStock,
+			})
+
+			if fix {
+				if err := tx.Model(&models.Product{}).Where("id = ?", row.ID).
+					Update("current_stock", row.ComputedStock).Error; err != nil {
+					return fmt.Errorf("failed to fix stock of product '%s': %w", row.Code, err)
+				}
+				if err := s.audit.record(tx, auditEntityProduct, row.ID, models.AuditActionUpdate,
+					stockFixAudit{row.CurrentStock}, stockFixAudit{row.ComputedStock}); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := s.reconcileBalances(tx, report, fix); err != nil {
+			return err
+		}
+
+		report.Fixed = fix && (len(report.Discrepancies) > 0 || len(report.BalanceDiscrepancies) > 0)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	s.lastReport = report
+	s.mutex.Unlock()
+
+	return report, nil
+}
+
+// computeLedger sums IN minus OUT movements plus signed ADJUSTMENT
+// movements for every product
+func (s *ReconciliationService) computeLedger(tx *gorm.DB) ([]ledgerRow, error) {
+	var rows []ledgerRow
+	err := tx.Table("products AS p").
+		Select(`p.id, p.code, p.name, p.current_stock,
+			COALESCE(SUM(CASE WHEN m.type IN (?, ?) THEN m.quantity WHEN m.type = ? THEN -m.quantity ELSE 0 END), 0) AS computed_stock`,
+			models.MovementTypeIn, models.MovementTypeAdjustment, models.MovementTypeOut).
+		Joins("LEFT JOIN stock_movements AS m ON m.product_id = p.id").
+		Group("p.id").
+		Order("p.code ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute stock from movements: %w", err)
+	}
+
+	return rows, nil
+}
+
+// reconcileBalances compares per-location balances with the movement ledger
+func (s *ReconciliationService) reconcileBalances(tx *gorm.DB, report *StockReconciliationReport, fix bool) error {
+	computed, err := s.computeBalances(tx)
+	if err != nil {
+		return err
+	}
+
+	var balances []models.StockBalance
+	if err := tx.Find(&balances).Error; err != nil {
+		return fmt.Errorf("failed to fetch stock balances: %w", err)
+	}
+
+	stored := make(map[balanceKey]models.Quantity, len(balances))
+	for _, balance := range balances {
+		stored[balanceKey{balance.ProductID, balance.LocationID}] = balance.Quantity
+		if _, ok := computed[balanceKey{balance.ProductID, balance.LocationID}]; !ok {
+			computed[balanceKey{balance.ProductID, balance.LocationID}] = 0
+		}
+	}
+
+	for key, quantity := range computed {
+		if stored[key] == quantity {
+			continue
+		}
+
+		report.BalanceDiscrepancies = append(report.BalanceDiscrepancies, LocationStockDiscrepancy{
+			ProductID:     key.ProductID,
+			LocationID:    key.LocationID,
+			StoredStock:   stored[key],
+			ComputedStock: quantity,
+			Difference:    stored[key] - quantity,
+		})
+
+		if fix {
+			if err := tx.Exec(`INSERT INTO stock_balances (product_id, location_id, quantity, updated_at) VALUES (?, ?, ?, ?)
+				ON CONFLICT (product_id, location_id) DO UPDATE SET quantity = excluded.quantity, updated_at = excluded.updated_at`,
+				key.ProductID, key.LocationID, quantity, time.Now()).Error; err != nil {
+				return fmt.Errorf("failed to fix location stock: %w", err)
+			}
+			if err := s.audit.record(tx, auditEntityProduct, key.ProductID, models.AuditActionUpdate,
+				balanceFixAudit{key.LocationID, stored[key]}, balanceFixAudit{key.LocationID, quantity}); err != nil {
+				return err
+			}
+		}
+	}
+
+	sort.Slice(report.BalanceDiscrepancies, func(i, j int) bool {
+		a, b := report.BalanceDiscrepancies[i], report.BalanceDiscrepancies[j]
+		if a.ProductID != b.ProductID {
+			return a.ProductID < b.ProductID
+		}
+		return a.LocationID < b.LocationID
+	})
+
+	return nil
+}
+
+// computeBalances nets IN, OUT, ADJUSTMENT and both legs of TRANSFER
+// movements per product and location
+func (s *ReconciliationService) computeBalances(tx *gorm.DB) (map[balanceKey]models.Quantity, error) {
+	var rows []struct {
+		ProductID  uint
+		LocationID uint
+		Quantity   models.Quantity
+	}
+	err := tx.Raw(`SELECT product_id, location_id, SUM(delta) AS quantity FROM (
+			SELECT product_id, location_id, CASE WHEN type IN (?, ?) THEN quantity ELSE -quantity END AS delta
+			FROM stock_movements
+			UNION ALL
+			SELECT product_id, to_location_id, quantity
+			FROM stock_movements WHERE type = ? AND to_location_id IS NOT NULL
+		) GROUP BY product_id, location_id`,
+		models.MovementTypeIn, models.MovementTypeAdjustment, models.MovementTypeTransfer).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute location stock from movements: %w", err)
+	}
+
+	computed := make(map[balanceKey]models.Quantity, len(rows))
+	for _, row := range rows {
+		computed[balanceKey{row.ProductID, row.LocationID}] = row.Quantity
+	}
+
+	return computed, nil
+}
@@ -0,0 +1,487 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"stoktakip/internal/config"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"gorm.io/gorm"
+)
+
+// Printable reports
+const (
+	ReportCountSheet = "count_sheet"
+	ReportValuation  = "valuation"
+	ReportLowStock   = "low_stock"
+)
+
+// reportFont is the embedded font family; the Go fonts cover Turkish letters
+const reportFont = "Go"
+
+// ReportService renders printable PDF reports
+type ReportService struct {
+	dbManager     *database.ConnectionManager
+	products      *ProductService
+	configManager *config.Manager
+}
+
+// NewReportService creates a new report service
+func NewReportService(dbManager *database.ConnectionManager, products *ProductService, configManager *config.Manager) *ReportService {
+	return &ReportService{
+		dbManager:     dbManager,
+		products:      products,
+		configManager: configManager,
+	}
+}
+
+// reportProduct is a product with its category name, as printed
+type reportProduct struct {
+	models.Product
+	CategoryName string
+}
+
+// IsValidReport reports whether kind names a printable report
+func IsValidReport(kind string) bool {
+	return kind == ReportCountSheet || kind == ReportValuation || kind == ReportLowStock
+}
+
+// Write renders the report named kind to w
+func (s *ReportService) Write(w io.Writer, kind string) error {
+	switch kind {
+	case ReportCountSheet:
+		return s.CountSheet(w)
+	case ReportValuation:
+		return s.Valuation(w)
+	case ReportLowStock:
+		return s.LowStock(w)
+	default:
+		return fmt.Errorf("unknown report: %s", kind)
+	}
+}
+
+// CountSheet writes a stock count sheet grouped by category, with blank
+// columns for the counted quantity and the difference
+func (s *ReportService) CountSheet(w io.Writer) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	products, err := loadReportProducts(db)
+	if err != nil {
+		return err
+	}
+	decimals, err := loadUnitDecimals(db)
+	if err != nil {
+		return err
+	}
+
+	labels := labelsFor(s.configManager.GetLanguage())
+	doc := s.newReport(labels, labels.countSheetTitle)
+	table := doc.table([]reportColumn{
+		{title: labels.code, width: 28},
+		{title: labels.name, width: 70},
+		{title: labels.unit, width: 16},
+		{title: labels.stock, width: 22, align: "R"},
+		{title: labels.counted, width: 25, align: "R"},
+		{title: labels.difference, width: 25, align: "R"},
+	})
+
+	if len(products) == 0 {
+		doc.note(labels.noProducts)
+	}
+	for _, group := range groupByCategory(products) {
+		table.group(group.name)
+		for _, product := range group.products {
+			// Taller rows leave room to write by hand
+			table.rowHeight(9, []string{
+				product.Code,
+				product.Name,
+				product.Unit,
+				labels.formatQuantity(product.CurrentStock, decimals[strings.ToLower(product.Unit)]),
+				"",
+				"",
+			})
+		}
+	}
+
+	return doc.output(w)
+}
+
+// Valuation writes the stock value of each product, category subtotals and
+// the grand total
+func (s *ReportService) Valuation(w io.Writer) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	products, err := loadReportProducts(db)
+	if err != nil {
+		return err
+	}
+	decimals, err := loadUnitDecimals(db)
+	if err != nil {
+		return err
+	}
+
+	labels := labelsFor(s.configManager.GetLanguage())
+	doc := s.newReport(labels, labels.valuationTitle)
+	table := doc.table([]reportColumn{
+		{title: labels.code, width: 26},
+		{title: labels.name, width: 62},
+		{title: labels.unit, width: 14},
+		{title: labels.stock, width: 22, align: "R"},
+		{title: labels.price, width: 28, align: "R"},
+		{title: labels.stockValue, width: 34, align: "R"},
+	})
+
+	if len(products) == 0 {
+		doc.note(labels.noProducts)
+	}
+	var total models.Money
+	for _, group := range groupByCategory(products) {
+		table.group(group.name)
+
+		var subtotal models.Money
+		for _, product := range group.products {
+			value := product.CurrentStock.MulPrice(product.Price)
+			subtotal += value
+			table.row([]string{
+				product.Code,
+				product.Name,
+				product.Unit,
+				labels.formatQuantity(product.CurrentStock, decimals[strings.ToLower(product.Unit)]),
+				labels.formatMoney(product.Price),
+				labels.formatMoney(value),
+			})
+		}
+		table.total(labels.subtotal+": "+group.name, labels.formatMoney(subtotal))
+		total += subtotal
+	}
+	table.total(labels.total, labels.formatMoney(total))
+
+	return doc.output(w)
+}
+
+// LowStock writes the products at or below their critical limit, as listed
+// by ProductService.GetLowStock
+func (s *ReportService) LowStock(w io.Writer) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	lowStock, err := s.products.GetLowStock()
+	if err != nil {
+		return err
+	}
+	decimals, err := loadUnitDecimals(db)
+	if err != nil {
+		return err
+	}
+
+	var categories []models.Category
+	if err := db.Find(&categories).Error; err != nil {
+		return fmt.Errorf("failed to fetch categories: %w", err)
+	}
+	categoryNames := make(map[uint]string, len(categories))
+	for _, category := range categories {
+		categoryNames[category.ID] = category.Name
+	}
+
+	// Largest shortfall first, then by code
+	sort.Slice(lowStock, func(i, j int) bool {
+		si := lowStock[i].CriticalLimit - lowStock[i].CurrentStock
+		sj := lowStock[j].CriticalLimit - lowStock[j].CurrentStock
+		if si != sj {
+			return si > sj
+		}
+		return lowStock[i].Code < lowStock[j].Code
+	})
+
+	labels := labelsFor(s.configManager.GetLanguage())
+	doc := s.newReport(labels, labels.lowStockTitle)
+	table := doc.table([]reportColumn{
+		{title: labels.code, width: 24},
+		{title: labels.name, width: 56},
+		{title: labels.category, width: 30},
+		{title: labels.unit, width: 14},
+		{title: labels.stock, width: 20, align: "R"},
+		{title: labels.criticalLimit, width: 22, align: "R"},
+		{title: labels.shortfall, width: 20, align: "R"},
+	})
+
+	if len(lowStock) == 0 {
+		doc.note(labels.noProducts)
+	}
+	for _, product := range lowStock {
+		unitDecimals := decimals[strings.ToLower(product.Unit)]
+		table.row([]string{
+			product.Code,
+			product.Name,
+			categoryNames[product.CategoryID],
+			product.Unit,
+			labels.formatQuantity(product.CurrentStock, unitDecimals),
+			labels.formatQuantity(product.CriticalLimit, unitDecimals),
+			labels.formatQuantity(product.CriticalLimit-product.CurrentStock, unitDecimals),
+		})
+	}
+
+	return doc.output(w)
+}
+
+// loadReportProducts returns every product with its category name
+func loadReportProducts(db *gorm.DB) ([]reportProduct, error) {
+	var products []models.Product
+	if err := db.Preload("Category").Order("code ASC").Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+
+	result := make([]reportProduct, len(products))
+	for i, product := range products {
+		result[i] = reportProduct{Product: product, CategoryName: product.Category.Name}
+	}
+	return result, nil
+}
+
+// categoryGroup is the products of one category, in code order
+type categoryGroup struct {
+	name     string
+	products []reportProduct
+}
+
+// groupByCategory groups products by category name in alphabetical order
+func groupByCategory(products []reportProduct) []categoryGroup {
+	index := make(map[string]int)
+	var groups []categoryGroup
+	for _, product := range products {
+		i, ok := index[product.CategoryName]
+		if !ok {
+			i = len(groups)
+			index[product.CategoryName] = i
+			groups = append(groups, categoryGroup{name: product.CategoryName})
+		}
+		groups[i].products = append(groups[i].products, product)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].name < groups[j].name })
+	return groups
+}
+
+// reportDocument is a PDF with the report header and page numbers
+type reportDocument struct {
+	pdf    *fpdf.Fpdf
+	labels exportLabels
+}
+
+// newReport starts an A4 report titled title for the current database
+func (s *ReportService) newReport(labels exportLabels, title string) *reportDocument {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(12, 12, 12)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddUTF8FontFromBytes(reportFont, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(reportFont, "B", gobold.TTF)
+	pdf.AliasNbPages("")
+	pdf.SetTitle(title, true)
+
+	databaseName := strings.TrimSuffix(filepath.Base(s.dbManager.GetPath()), filepath.Ext(s.dbManager.GetPath()))
+	printedAt := time.Now().Format(labels.timeLayout)
+
+	pdf.SetHeaderFunc(func() {
+		left, _, right, _ := pdf.GetMargins()
+		pageWidth, _ := pdf.GetPageSize()
+		width := pageWidth - left - right
+
+		pdf.SetFont(reportFont, "B", 14)
+		pdf.CellFormat(width*0.6, 7, title, "", 0, "L", false, 0, "")
+		pdf.SetFont(reportFont, "", 9)
+		pdf.CellFormat(width*0.4, 7, printedAt, "", 1, "R", false, 0, "")
+		pdf.CellFormat(width, 5, labels.database+": "+databaseName, "", 1, "L", false, 0, "")
+
+		y := pdf.GetY() + 1
+		pdf.Line(left, y, pageWidth-right, y)
+		pdf.SetY(y + 3)
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(reportFont, "", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf(labels.page, pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+	return &reportDocument{pdf: pdf, labels: labels}
+}
+
+// note writes a line of plain text
+func (d *reportDocument) note(text string) {
+	d.pdf.SetFont(reportFont, "", 10)
+	d.pdf.CellFormat(0, 8, text, "", 1, "L", false, 0, "")
+}
+
+// output writes the finished PDF to w
+func (d *reportDocument) output(w io.Writer) error {
+	if err := d.pdf.Output(w); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// reportColumn is a column of a report table, widths in millimetres
+type reportColumn struct {
+	title string
+	width float64
+	align string // "L" by default
+}
+
+// reportTable draws rows and repeats its header on every page
+type reportTable struct {
+	doc     *reportDocument
+	columns []reportColumn
+	page    int // Page the header was last drawn on
+}
+
+// table starts a table below the current position
+func (d *reportDocument) table(columns []reportColumn) *reportTable {
+	t := &reportTable{doc: d, columns: columns}
+	t.header()
+	return t
+}
+
+// header draws the column titles
+func (t *reportTable) header() {
+	pdf := t.doc.pdf
+	pdf.SetFont(reportFont, "B", 9)
+	pdf.SetFillColor(229, 231, 235)
+	for _, column := range t.columns {
+		pdf.CellFormat(column.width, 7, t.fit(column.title, column.width), "1", 0, alignOf(column), true, 0, "")
+	}
+	pdf.Ln(-1)
+	t.page = pdf.PageNo()
+}
+
+// ensure starts a new page with the header when height does not fit
+func (t *reportTable) ensure(height float64) {
+	pdf := t.doc.pdf
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+height > pageHeight-bottom {
+		pdf.AddPage()
+	}
+	if pdf.PageNo() != t.page {
+		t.header()
+	}
+}
+
+// group draws a category heading, kept on the page with its first row
+func (t *reportTable) group(name string) {
+	t.ensure(7 + 7)
+	pdf := t.doc.pdf
+	pdf.SetFont(reportFont, "B", 9)
+	pdf.SetFillColor(243, 244, 246)
+	pdf.CellFormat(t.width(), 7, t.fit(name, t.width()), "1", 1, "L", true, 0, "")
+}
+
+// row draws a row of values
+func (t *reportTable) row(values []string) {
+	t.rowHeight(6, values)
+}
+
+// rowHeight draws a row of values with the given height
+func (t *reportTable) rowHeight(height float64, values []string) {
+	t.ensure(height)
+	pdf := t.doc.pdf
+	pdf.SetFont(reportFont, "", 9)
+	for i, column := range t.columns {
+		pdf.CellFormat(column.width, height, t.fit(values[i], column.width), "1", 0, alignOf(column), false, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// total draws a bold row with a label and a value in the last column
+func (t *reportTable) total(label, value string) {
+	t.ensure(7)
+	pdf := t.doc.pdf
+	last := t.columns[len(t.columns)-1].width
+	pdf.SetFont(reportFont, "B", 9)
+	pdf.CellFormat(t.width()-last, 7, t.fit(label, t.width()-last), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(last, 7, value, "1", 1, "R", false, 0, "")
+}
+
+// width returns the width of the whole table
+func (t *reportTable) width() float64 {
+	var width float64
+	for _, column := range t.columns {
+		width += column.width
+	}
+	return width
+}
+
+// fit shortens text with an ellipsis to fit a cell of width
+func (t *reportTable) fit(text string, width float64) string {
+	pdf := t.doc.pdf
+	room := width - 2*pdf.GetCellMargin()
+	if pdf.GetStringWidth(text) <= room {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"…") > room {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// alignOf returns the fpdf alignment of a column
+func alignOf(column reportColumn) string {
+	if column.align == "" {
+		return "L"
+	}
+	return column.align
+}
+
+// formatQuantity formats a quantity with the unit's decimals and the
+// language's separators
+func (l exportLabels) formatQuantity(q models.Quantity, decimals int) string {
+	return l.formatNumber(q.String(), decimals)
+}
+
+// formatMoney formats an amount with two decimals and the language's separators
+func (l exportLabels) formatMoney(m models.Money) string {
+	return l.formatNumber(m.Round2().String(), 2)
+}
+
+// formatNumber groups the thousands of a plain decimal such as "-1234.5" and
+// pads its fraction to at least decimals digits
+func (l exportLabels) formatNumber(text string, decimals int) string {
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+
+	whole, frac, _ := strings.Cut(text, ".")
+	if len(frac) < decimals {
+		frac += strings.Repeat("0", decimals-len(frac))
+	}
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(l.thousandsMark)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	if frac == "" {
+		return sign + grouped.String()
+	}
+	return sign + grouped.String() + l.decimalMark + frac
+}
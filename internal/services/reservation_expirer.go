@@ -0,0 +1,104 @@
+package services
+
+import (
+	"log"
+	"stoktakip/internal/database"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// expiryInterval is how often the expirer looks for expired reservations
+const expiryInterval = time.Minute
+
+// ReservationExpirer releases expired reservations of the active database in
+// the background. Expired reservations stop holding stock at their expiry
+// anyway; this keeps their status in line.
+type ReservationExpirer struct {
+	reservations *ReservationService
+	dbManager    *database.ConnectionManager
+
+	mu   sync.Mutex
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReservationExpirer creates a new reservation expirer
+func NewReservationExpirer(reservations *ReservationService, dbManager *database.ConnectionManager) *ReservationExpirer {
+	return &ReservationExpirer{
+		reservations: reservations,
+		dbManager:    dbManager,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Start runs the expirer in the background until Stop
+func (e *ReservationExpirer) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stop != nil {
+		return
+	}
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	go e.run(e.stop, e.done)
+}
+
+// Stop ends the expirer and waits for a running pass to finish
+func (e *ReservationExpirer) Stop() {
+	e.mu.Lock()
+	stop, done := e.stop, e.done
+	e.stop, e.done = nil, nil
+	e.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// OnConnect releases what expired while the newly opened database was closed
+func (e *ReservationExpirer) OnConnect(db *gorm.DB) {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run is the expirer loop
+func (e *ReservationExpirer) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		e.expire()
+
+		select {
+		case <-stop:
+			return
+		case <-e.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// expire releases the expired reservations of the active database, if any
+func (e *ReservationExpirer) expire() {
+	if !e.dbManager.IsConnected() {
+		return
+	}
+	count, err := e.reservations.ReleaseExpired()
+	if err != nil {
+		log.Printf("Warning: failed to release expired reservations: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("Released %d expired reservations", count)
+	}
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// reservedQuantitySQL sums what the active, unexpired reservations of a
+// product hold, leaving one reservation out. Its arguments are the product
+// ID, the ID of the reservation to leave out (0 for none) and the current time.
+const reservedQuantitySQL = `SELECT COALESCE(SUM(r.quantity - r.fulfilled), 0) FROM stock_reservations AS r
+	WHERE r.product_id = ? AND r.id <> ? AND r.status = 'ACTIVE'
+	AND (r.expires_at IS NULL OR julianday(r.expires_at) > julianday(?))`
+
+// ReservationDTO is the data transfer object for stock reservations
+type ReservationDTO struct {
+	ID               uint            `json:"id"`
+	ProductID        uint            `json:"product_id"`
+	Code             string          `json:"code"` // Read-only
+	Name             string          `json:"name"` // Read-only
+	Unit             string          `json:"unit"` // Read-only
+	Quantity         models.Quantity `json:"quantity"`
+	Fulfilled        models.Quantity `json:"fulfilled"` // Read-only
+	Open             models.Quantity `json:"open"`      // Still held while active, read-only
+	Status           string          `json:"status"`    // "ACTIVE", "FULFILLED", "RELEASED" or "EXPIRED", read-only
+	Reason           string          `json:"reason"`
+	Reference        string          `json:"reference"`
+	ExpiresAt        *time.Time      `json:"expires_at"`          // Nil holds the stock until released
+	SalesOrderLineID uint            `json:"sales_order_line_id"` // Read-only
+	CreatedBy        string          `json:"created_by"`          // Read-only
+	ReleasedAt       *time.Time      `json:"released_at"`         // Read-only
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// ReservationService handles stock reservations
+type ReservationService struct {
+	dbManager *database.ConnectionManager
+	audit     *AuditService
+	auth      *AuthService
+}
+
+// NewReservationService creates a new reservation service
+func NewReservationService(dbManager *database.ConnectionManager, audit *AuditService, auth *AuthService) *ReservationService {
+	return &ReservationService{
+		dbManager: dbManager,
+		audit:     audit,
+		auth:      auth,
+	}
+}
+
+// Helper function to convert model to DTO, with its relations loaded
+func (s *ReservationService) toDTO(reservation *models.StockReservation) ReservationDTO {
+	dto := ReservationDTO{
+		ID:         reservation.ID,
+		ProductID:  reservation.ProductID,
+		Code:       reservation.Product.Code,
+		Name:       reservation.Product.Name,
+		Unit:       reservation.Product.Unit,
+		Quantity:   reservation.Quantity,
+		Fulfilled:  reservation.Fulfilled,
+		Status:     string(reservation.Status),
+		Reason:     reservation.Reason,
+		Reference:  reservation.Reference,
+		ExpiresAt:  reservation.ExpiresAt,
+		ReleasedAt: reservation.ReleasedAt,
+		CreatedAt:  reservation.CreatedAt,
+		UpdatedAt:  reservation.UpdatedAt,
+	}
+	if reservation.Status == models.ReservationActive {
+		dto.Open = reservation.Quantity - reservation.Fulfilled
+	}
+	if reservation.SalesOrderLineID != nil {
+		dto.SalesOrderLineID = *reservation.SalesOrderLineID
+	}
+	if reservation.Creator != nil {
+		dto.CreatedBy = reservation.Creator.Username
+	}
+	return dto
+}
+
+// GetAll returns reservations, newest first. A product ID other than 0 and a
+// status other than "" limit them to that product and status.
+func (s *ReservationService) GetAll(productID uint, status string) ([]ReservationDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	query := db.Preload("Product").Preload("Creator")
+	if productID != 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+	if status != "" {
+		if !models.ReservationStatus(status).IsValid() {
+			return nil, fmt.Errorf("invalid reservation status: %s", status)
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var reservations []models.StockReservation
+	if err := query.Order("id DESC").Find(&reservations).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch reservations: %w", err)
+	}
+
+	dtos := make([]ReservationDTO, len(reservations))
+	for i := range reservations {
+		dtos[i] = s.toDTO(&reservations[i])
+	}
+	return dtos, nil
+}
+
+// GetByID returns a reservation by ID as DTO
+func (s *ReservationService) GetByID(id uint) (*ReservationDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	reservation, err := s.load(db, id)
+	if err != nil {
+		return nil, err
+	}
+	dto := s.toDTO(reservation)
+	return &dto, nil
+}
+
+// load loads a reservation with its relations
+func (s *ReservationService) load(db *gorm.DB, id uint) (*models.StockReservation, error) {
+	var reservation models.StockReservation
+	if err := db.Preload("Product").Preload("Creator").First(&reservation, id).Error; err != nil {
+		return nil, fmt.Errorf("reservation not found: %w", err)
+	}
+	return &reservation, nil
+}
+
+// Create reserves stock of a product. It is refused when the product does
+// not have enough stock left after the other active reservations.
+func (s *ReservationService) Create(dto ReservationDTO) (*ReservationDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var reservation *models.StockReservation
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var product models.Product
+		if err := tx.First(&product, dto.ProductID).Error; err != nil {
+			return fmt.Errorf("product not found: %w", err)
+		}
+		if dto.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than zero")
+		}
+		if err := checkPrecision(tx, product.Unit, dto.Quantity); err != nil {
+			return err
+		}
+		if dto.ExpiresAt != nil && !dto.ExpiresAt.After(time.Now()) {
+			return fmt.Errorf("expiry must be in the future")
+		}
+
+		reservation = &models.StockReservation{
+			ProductID: product.ID,
+			Quantity:  dto.Quantity,
+			Reason:    strings.TrimSpace(dto.Reason),
+			Reference: strings.TrimSpace(dto.Reference),
+			ExpiresAt: dto.ExpiresAt,
+			CreatedBy: s.auth.CurrentUserID(),
+		}
+		if err := reserve(tx, reservation); err != nil {
+			return err
+		}
+
+		var err error
+		if reservation, err = s.load(tx, reservation.ID); err != nil {
+			return err
+		}
+		return s.audit.record(tx, auditEntityReservation, reservation.ID, models.AuditActionCreate, nil, s.toDTO(reservation))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(reservation)
+	return &resultDTO, nil
+}
+
+// Release gives up what an active reservation still holds. Reservations of
+// sales orders are released by cancelling the order.
+func (s *ReservationService) Release(id uint) (*ReservationDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var reservation *models.StockReservation
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if reservation, err = s.load(tx, id); err != nil {
+			return err
+		}
+		if reservation.Status != models.ReservationActive {
+			return fmt.Errorf("cannot release reservation %d: it is %s", reservation.ID, reservation.Status)
+		}
+		if reservation.SalesOrderLineID != nil {
+			return fmt.Errorf("cannot release reservation %d: cancel sales order %s instead", reservation.ID, reservation.Reference)
+		}
+		before := s.toDTO(reservation)
+
+		if err := closeReservation(tx, reservation, models.ReservationReleased); err != nil {
+			return err
+		}
+		return s.audit.record(tx, auditEntityReservation, reservation.ID, models.AuditActionUpdate, before, s.toDTO(reservation))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(reservation)
+	return &resultDTO, nil
+}
+
+// ReleaseExpired marks the active reservations whose expiry has passed as
+// EXPIRED and returns how many there were. Expired reservations stop holding
+// stock at their expiry whether or not this has run.
+func (s *ReservationService) ReleaseExpired() (int, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return 0, fmt.Errorf("no database connection")
+	}
+
+	var count int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var reservations []models.StockReservation
+		if err := tx.Preload("Product").Preload("Creator").
+			Where("status = ? AND expires_at IS NOT NULL AND julianday(expires_at) <= julianday(?)", models.ReservationActive, time.Now()).
+			Find(&reservations).Error; err != nil {
+			return fmt.Errorf("failed to fetch expired reservations: %w", err)
+		}
+
+		for i := range reservations {
+			reservation := &reservations[i]
+			before := s.toDTO(reservation)
+			if err := closeReservation(tx, reservation, models.ReservationExpired); err != nil {
+				return err
+			}
+			if err := s.audit.record(tx, auditEntityReservation, reservation.ID, models.AuditActionUpdate, before, s.toDTO(reservation)); err != nil {
+				return err
+			}
+		}
+		count = len(reservations)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// reserve stores a new active reservation inside tx, refusing it when the
+// product's stock does not cover every active reservation. The row is written
+// before the check so a concurrent writer waits for this transaction.
+func reserve(tx *gorm.DB, reservation *models.StockReservation) error {
+	reservation.Status = models.ReservationActive
+	reservation.Fulfilled = 0
+	if err := tx.Omit("Product", "Creator").Create(reservation).Error; err != nil {
+		return fmt.Errorf("failed to create reservation: %w", err)
+	}
+
+	var product models.Product
+	if err := tx.First(&product, reservation.ProductID).Error; err != nil {
+		return fmt.Errorf("product not found: %w", err)
+	}
+	var others models.Quantity
+	if err := tx.Raw(reservedQuantitySQL, product.ID, reservation.ID, time.Now()).Scan(&others).Error; err != nil {
+		return fmt.Errorf("failed to fetch reserved quantity: %w", err)
+	}
+	if available := product.CurrentStock - others; available < reservation.Quantity {
+		return conflictf("insufficient stock to reserve product '%s': available %s, requested %s",
+			product.Code, max(available, 0), reservation.Quantity)
+	}
+	return nil
+}
+
+// closeReservation ends an active reservation with status inside tx
+func closeReservation(tx *gorm.DB, reservation *models.StockReservation, status models.ReservationStatus) error {
+	now := time.Now()
+	if err := tx.Model(reservation).Updates(map[string]interface{}{
+		"status":      status,
+		"released_at": now,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update reservation: %w", err)
+	}
+	reservation.Status = status
+	reservation.ReleasedAt = &now
+	return nil
+}
+
+// checkFulfilment loads the reservation an OUT movement of quantity fulfils
+// and makes sure it can take it
+func checkFulfilment(tx *gorm.DB, reservationID, productID uint, quantity models.Quantity) error {
+	var reservation models.StockReservation
+	if err := tx.First(&reservation, reservationID).Error; err != nil {
+		return fmt.Errorf("reservation not found: %w", err)
+	}
+	if reservation.ProductID != productID {
+		return fmt.Errorf("reservation %d is for another product", reservation.ID)
+	}
+	if reservation.Status != models.ReservationActive ||
+		reservation.ExpiresAt != nil && !reservation.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("reservation %d is no longer active", reservation.ID)
+	}
+	if open := reservation.Quantity - reservation.Fulfilled; quantity > open {
+		return fmt.Errorf("cannot fulfil %s from reservation %d: it holds %s", quantity, reservation.ID, open)
+	}
+	return nil
+}
+
+// fulfil counts quantity shipped against a reservation inside tx and marks
+// it FULFILLED once nothing is left open
+func fulfil(tx *gorm.DB, reservationID uint, quantity models.Quantity) error {
+	var reservation models.StockReservation
+	if err := tx.First(&reservation, reservationID).Error; err != nil {
+		return fmt.Errorf("reservation not found: %w", err)
+	}
+
+	updates := map[string]interface{}{"fulfilled": reservation.Fulfilled + quantity}
+	if reservation.Fulfilled+quantity >= reservation.Quantity && reservation.Status == models.ReservationActive {
+		updates["status"] = models.ReservationFulfilled
+		updates["released_at"] = time.Now()
+	}
+	if err := tx.Model(&reservation).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update reservation: %w", err)
+	}
+	return nil
+}
+
+// unfulfil takes back quantity from what a reservation has fulfilled when
+// its OUT movement is deleted, inside tx. A fulfilled reservation becomes
+// active again; released and expired ones stay closed.
+func unfulfil(tx *gorm.DB, reservationID uint, quantity models.Quantity) error {
+	var reservation models.StockReservation
+	if err := tx.First(&reservation, reservationID).Error; err != nil {
+		return fmt.Errorf("reservation not found: %w", err)
+	}
+
+	updates := map[string]interface{}{"fulfilled": max(reservation.Fulfilled-quantity, 0)}
+	if reservation.Status == models.ReservationFulfilled {
+		updates["status"] = models.ReservationActive
+		updates["released_at"] = nil
+	}
+	if err := tx.Model(&reservation).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update reservation: %w", err)
+	}
+	return nil
+}
+
+// loadReserved returns what active, unexpired reservations hold of the given
+// products, keyed by product ID
+func loadReserved(db *gorm.DB, productIDs []uint) (map[uint]models.Quantity, error) {
+	reserved := make(map[uint]models.Quantity)
+	if len(productIDs) == 0 {
+		return reserved, nil
+	}
+
+	var rows []struct {
+		ProductID uint
+		Quantity  models.Quantity
+	}
+	err := db.Table("stock_reservations AS r").
+		Select("r.product_id, SUM(r.quantity - r.fulfilled) AS quantity").
+		Where("r.product_id IN ? AND r.status = ?", productIDs, models.ReservationActive).
+		Where("r.expires_at IS NULL OR julianday(r.expires_at) > julianday(?)", time.Now()).
+		Group("r.product_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reserved quantities: %w", err)
+	}
+
+	for _, row := range rows {
+		reserved[row.ProductID] = row.Quantity
+	}
+	return reserved, nil
+}
@@ -0,0 +1,593 @@
+package services
+
+import (
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// salesOrderPrefix starts every sales order number
+const salesOrderPrefix = "SO-"
+
+// SalesOrderDTO is the data transfer object for sales orders
+type SalesOrderDTO struct {
+	ID          uint                `json:"id"`
+	Number      string              `json:"number"` // Assigned on create, read-only
+	CustomerID  uint                `json:"customer_id"`
+	Customer    string              `json:"customer"`    // Read-only
+	Status      string              `json:"status"`      // "DRAFT", "CONFIRMED", "PARTIAL", "SHIPPED" or "CANCELLED", read-only
+	LocationID  uint                `json:"location_id"` // Where goods ship from; 0 means the default location
+	Location    string              `json:"location"`    // Read-only
+	DueDate     *time.Time          `json:"due_date"`
+	Note        string              `json:"note"`
+	CreatedBy   string              `json:"created_by"` // Read-only
+	ConfirmedAt *time.Time          `json:"confirmed_at"`
+	ClosedAt    *time.Time          `json:"closed_at"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	Lines       []SalesOrderLineDTO `json:"lines"`
+	Total       models.Money        `json:"total"` // Ordered quantity * unit price over all lines, read-only
+}
+
+// SalesOrderLineDTO is one product of a sales order
+type SalesOrderLineDTO struct {
+	ID          uint            `json:"id"`
+	ProductID   uint            `json:"product_id"`
+	Code        string          `json:"code"` // Read-only
+	Name        string          `json:"name"` // Read-only
+	Unit        string          `json:"unit"` // Read-only
+	Quantity    models.Quantity `json:"quantity"`
+	Shipped     models.Quantity `json:"shipped"` // Read-only
+	UnitPrice   models.Money    `json:"unit_price"`
+	Note        string          `json:"note"`
+	Outstanding models.Quantity `json:"outstanding"` // Reserved and still to ship while the order is open, read-only
+	Total       models.Money    `json:"total"`       // Quantity * UnitPrice, read-only
+}
+
+// SalesShipmentLine ships a quantity of a sales order line, in the
+// product's base unit
+type SalesShipmentLine struct {
+	LineID     uint            `json:"line_id"`
+	Quantity   models.Quantity `json:"quantity"`
+	LocationID uint            `json:"location_id"` // 0 means the order's location
+}
+
+// SalesOrderService handles sales and issue orders and shipping them
+type SalesOrderService struct {
+	dbManager *database.ConnectionManager
+	audit     *AuditService
+	auth      *AuthService
+	movements *MovementService
+}
+
+// NewSalesOrderService creates a new sales order service
+func NewSalesOrderService(dbManager *database.ConnectionManager, audit *AuditService, auth *AuthService, movements *MovementService) *SalesOrderService {
+	return &SalesOrderService{
+		dbManager: dbManager,
+		audit:     audit,
+		auth:      auth,
+		movements: movements,
+	}
+}
+
+// Helper function to convert model to DTO, with its relations loaded
+func (s *SalesOrderService) toDTO(order *models.SalesOrder) SalesOrderDTO {
+	dto := SalesOrderDTO{
+		ID:          order.ID,
+		Number:      order.Number,
+		CustomerID:  order.CustomerID,
+		Customer:    order.Customer.Name,
+		Status:      string(order.Status),
+		LocationID:  order.LocationID,
+		Location:    order.Location.Name,
+		DueDate:     order.DueDate,
+		Note:        order.Note,
+		ConfirmedAt: order.ConfirmedAt,
+		ClosedAt:    order.ClosedAt,
+		CreatedAt:   order.CreatedAt,
+		UpdatedAt:   order.UpdatedAt,
+		Lines:       make([]SalesOrderLineDTO, len(order.Lines)),
+	}
+	if order.Creator != nil {
+		dto.CreatedBy = order.Creator.Username
+	}
+
+	for i, line := range order.Lines {
+		lineDTO := SalesOrderLineDTO{
+			ID:        line.ID,
+			ProductID: line.ProductID,
+			Code:      line.Product.Code,
+			Name:      line.Product.Name,
+			Unit:      line.Product.Unit,
+			Quantity:  line.Quantity,
+			Shipped:   line.Shipped,
+			UnitPrice: line.UnitPrice,
+			Note:      line.Note,
+			Total:     line.Quantity.MulPrice(line.UnitPrice),
+		}
+		if order.Status.IsOpen() {
+			lineDTO.Outstanding = line.Quantity - line.Shipped
+		}
+		dto.Lines[i] = lineDTO
+		dto.Total += lineDTO.Total
+	}
+	return dto
+}
+
+// preloadSalesOrder loads the relations SalesOrderDTO shows
+func preloadSalesOrder(db *gorm.DB) *gorm.DB {
+	return db.Preload("Customer").Preload("Location").Preload("Creator").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("sales_order_lines.id") }).
+		Preload("Lines.Product")
+}
+
+// GetAll returns sales orders, newest first; a status other than "" limits
+// them to that status
+func (s *SalesOrderService) GetAll(status string) ([]SalesOrderDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	query := preloadSalesOrder(db)
+	if status != "" {
+		if !models.SalesOrderStatus(status).IsValid() {
+			return nil, fmt.Errorf("invalid sales order status: %s", status)
+		}
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []models.SalesOrder
+	if err := query.Order("id DESC").Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch sales orders: %w", err)
+	}
+
+	dtos := make([]SalesOrderDTO, len(orders))
+	for i := range orders {
+		dtos[i] = s.toDTO(&orders[i])
+	}
+	return dtos, nil
+}
+
+// GetByID returns a sales order by ID as DTO
+func (s *SalesOrderService) GetByID(id uint) (*SalesOrderDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	order, err := s.load(db, id)
+	if err != nil {
+		return nil, err
+	}
+	dto := s.toDTO(order)
+	return &dto, nil
+}
+
+// load loads an order with its relations
+func (s *SalesOrderService) load(db *gorm.DB, id uint) (*models.SalesOrder, error) {
+	var order models.SalesOrder
+	if err := preloadSalesOrder(db).First(&order, id).Error; err != nil {
+		return nil, fmt.Errorf("sales order not found: %w", err)
+	}
+	return &order, nil
+}
+
+// Create creates a draft sales order with its lines
+func (s *SalesOrderService) Create(dto SalesOrderDTO) (*SalesOrderDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var order *models.SalesOrder
+	err := db.Transaction(func(tx *gorm.DB) error {
+		number, err := nextSalesOrderNumber(tx)
+		if err != nil {
+			return err
+		}
+
+		order = &models.SalesOrder{
+			Number:    number,
+			Status:    models.SalesOrderDraft,
+			CreatedBy: s.auth.CurrentUserID(),
+		}
+		if err := s.assign(tx, order, dto); err != nil {
+			return err
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return fmt.Errorf("failed to create sales order: %w", err)
+		}
+		if err := s.insertLines(tx, order.ID, dto.Lines); err != nil {
+			return err
+		}
+
+		if order, err = s.load(tx, order.ID); err != nil {
+			return err
+		}
+		return s.audit.record(tx, auditEntitySalesOrder, order.ID, models.AuditActionCreate, nil, s.toDTO(order))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(order)
+	return &resultDTO, nil
+}
+
+// Update replaces the header and lines of a draft sales order
+func (s *SalesOrderService) Update(id uint, dto SalesOrderDTO) (*SalesOrderDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var order *models.SalesOrder
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if order, err = s.load(tx, id); err != nil {
+			return err
+		}
+		if order.Status != models.SalesOrderDraft {
+			return conflictf("cannot change sales order %s: it is %s", order.Number, order.Status)
+		}
+		before := s.toDTO(order)
+
+		if err := s.assign(tx, order, dto); err != nil {
+			return err
+		}
+		if err := tx.Model(order).Updates(map[string]interface{}{
+			"customer_id": order.CustomerID,
+			"location_id": order.LocationID,
+			"due_date":    order.DueDate,
+			"note":        order.Note,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update sales order: %w", err)
+		}
+		if err := tx.Where("order_id = ?", order.ID).Delete(&models.SalesOrderLine{}).Error; err != nil {
+			return fmt.Errorf("failed to update sales order lines: %w", err)
+		}
+		if err := s.insertLines(tx, order.ID, dto.Lines); err != nil {
+			return err
+		}
+
+		if order, err = s.load(tx, order.ID); err != nil {
+			return err
+		}
+		return s.audit.record(tx, auditEntitySalesOrder, order.ID, models.AuditActionUpdate, before, s.toDTO(order))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(order)
+	return &resultDTO, nil
+}
+
+// assign validates the header of dto and copies it to order
+func (s *SalesOrderService) assign(tx *gorm.DB, order *models.SalesOrder, dto SalesOrderDTO) error {
+	if dto.CustomerID == 0 {
+		return fmt.Errorf("customer is required")
+	}
+	if err := tx.First(&models.Customer{}, dto.CustomerID).Error; err != nil {
+		return fmt.Errorf("customer not found: %w", err)
+	}
+
+	locationID := dto.LocationID
+	if locationID == 0 {
+		var err error
+		if locationID, err = defaultLocationID(tx); err != nil {
+			return err
+		}
+	}
+	if err := tx.First(&models.Location{}, locationID).Error; err != nil {
+		return fmt.Errorf("location not found: %w", err)
+	}
+
+	order.CustomerID = dto.CustomerID
+	order.LocationID = locationID
+	order.DueDate = dto.DueDate
+	order.Note = dto.Note
+	return nil
+}
+
+// insertLines validates and stores the lines of an order inside tx
+func (s *SalesOrderService) insertLines(tx *gorm.DB, orderID uint, dtos []SalesOrderLineDTO) error {
+	if len(dtos) == 0 {
+		return fmt.Errorf("sales order needs at least one line")
+	}
+
+	seen := make(map[uint]bool, len(dtos))
+	for _, dto := range dtos {
+		var product models.Product
+		if err := tx.First(&product, dto.ProductID).Error; err != nil {
+			return fmt.Errorf("product not found: %w", err)
+		}
+		if seen[product.ID] {
+			return fmt.Errorf("product '%s' is on the order more than once", product.Code)
+		}
+		seen[product.ID] = true
+
+		if dto.Quantity <= 0 {
+			return fmt.Errorf("quantity of product '%s' must be greater than zero", product.Code)
+		}
+		if err := checkPrecision(tx, product.Unit, dto.Quantity); err != nil {
+			return err
+		}
+		if dto.UnitPrice < 0 {
+			return fmt.Errorf("unit price of product '%s' cannot be negative", product.Code)
+		}
+
+		line := &models.SalesOrderLine{
+			OrderID:   orderID,
+			ProductID: product.ID,
+			Quantity:  dto.Quantity,
+			UnitPrice: dto.UnitPrice,
+			Note:      dto.Note,
+		}
+		if err := tx.Create(line).Error; err != nil {
+			return fmt.Errorf("failed to create sales order line: %w", err)
+		}
+	}
+	return nil
+}
+
+// nextSalesOrderNumber returns the number after the highest one in use
+func nextSalesOrderNumber(tx *gorm.DB) (string, error) {
+	var last int64
+	if err := tx.Model(&models.SalesOrder{}).
+		Where("number LIKE ?", salesOrderPrefix+"%").
+		Select("COALESCE(MAX(CAST(SUBSTR(number, ?) AS INTEGER)), 0)", len(salesOrderPrefix)+1).
+		Scan(&last).Error; err != nil {
+		return "", fmt.Errorf("failed to number sales order: %w", err)
+	}
+	return fmt.Sprintf("%s%06d", salesOrderPrefix, last+1), nil
+}
+
+// Delete deletes a draft sales order
+func (s *SalesOrderService) Delete(id uint) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		order, err := s.load(tx, id)
+		if err != nil {
+			return err
+		}
+		if order.Status != models.SalesOrderDraft {
+			return conflictf("cannot delete sales order %s: it is %s and only drafts can be deleted", order.Number, order.Status)
+		}
+
+		if err := tx.Where("order_id = ?", id).Delete(&models.SalesOrderLine{}).Error; err != nil {
+			return fmt.Errorf("failed to delete sales order lines: %w", err)
+		}
+		if err := tx.Delete(&models.SalesOrder{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete sales order: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntitySalesOrder, order.ID, models.AuditActionDelete, s.toDTO(order), nil)
+	})
+}
+
+// Confirm reserves the stock of each line of a draft order. It is refused
+// when a product does not have enough stock left after the other active
+// reservations.
+func (s *SalesOrderService) Confirm(id uint) (*SalesOrderDTO, error) {
+	return s.transition(id, func(tx *gorm.DB, order *models.SalesOrder) error {
+		if order.Status != models.SalesOrderDraft {
+			return fmt.Errorf("cannot confirm sales order %s: it is %s", order.Number, order.Status)
+		}
+
+		for _, line := range order.Lines {
+			lineID := line.ID
+			if err := reserve(tx, &models.StockReservation{
+				ProductID:        line.ProductID,
+				Quantity:         line.Quantity,
+				Reason:           order.Customer.Name,
+				Reference:        order.Number,
+				SalesOrderLineID: &lineID,
+				CreatedBy:        s.auth.CurrentUserID(),
+			}); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(order).Updates(map[string]interface{}{
+			"status":       models.SalesOrderConfirmed,
+			"confirmed_at": now,
+		}).Error
+	})
+}
+
+// Cancel closes an order that has not been shipped in full and releases
+// what it still reserves. Goods already shipped stay shipped.
+func (s *SalesOrderService) Cancel(id uint) (*SalesOrderDTO, error) {
+	return s.transition(id, func(tx *gorm.DB, order *models.SalesOrder) error {
+		if order.Status != models.SalesOrderDraft && !order.Status.IsOpen() {
+			return fmt.Errorf("cannot cancel sales order %s: it is %s", order.Number, order.Status)
+		}
+
+		var reservations []models.StockReservation
+		if err := tx.Where("sales_order_line_id IN (SELECT id FROM sales_order_lines WHERE order_id = ?) AND status = ?",
+			order.ID, models.ReservationActive).Find(&reservations).Error; err != nil {
+			return fmt.Errorf("failed to fetch reservations: %w", err)
+		}
+		for i := range reservations {
+			if err := closeReservation(tx, &reservations[i], models.ReservationReleased); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		return tx.Model(order).Updates(map[string]interface{}{
+			"status":    models.SalesOrderCancelled,
+			"closed_at": now,
+		}).Error
+	})
+}
+
+// Ship books shipped goods as OUT movements to the order's customer, linked
+// to their order lines and fulfilling their reservations, all in one
+// transaction. No line can ship more than ordered. The order becomes
+// SHIPPED once every line is shipped in full and PARTIAL before that.
+func (s *SalesOrderService) Ship(id uint, shipments []SalesShipmentLine, note string) (*SalesOrderDTO, error) {
+	if len(shipments) == 0 {
+		return nil, fmt.Errorf("nothing to ship")
+	}
+
+	return s.transition(id, func(tx *gorm.DB, order *models.SalesOrder) error {
+		if !order.Status.IsOpen() {
+			return fmt.Errorf("cannot ship sales order %s: it is %s", order.Number, order.Status)
+		}
+
+		lines := make(map[uint]models.SalesOrderLine, len(order.Lines))
+		for _, line := range order.Lines {
+			lines[line.ID] = line
+		}
+		if note == "" {
+			note = fmt.Sprintf("%s, %s", order.Number, order.Customer.Name)
+		}
+
+		for _, shipment := range shipments {
+			line, ok := lines[shipment.LineID]
+			if !ok {
+				return fmt.Errorf("line %d not found on sales order %s", shipment.LineID, order.Number)
+			}
+			if shipment.Quantity <= 0 {
+				return fmt.Errorf("shipped quantity of product '%s' must be greater than zero", line.Product.Code)
+			}
+			if outstanding := line.Quantity - line.Shipped; shipment.Quantity > outstanding {
+				return fmt.Errorf("cannot ship %s of product '%s': only %s left on the order", shipment.Quantity, line.Product.Code, outstanding)
+			}
+
+			locationID := shipment.LocationID
+			if locationID == 0 {
+				locationID = order.LocationID
+			}
+			var reservation models.StockReservation
+			if err := tx.Where("sales_order_line_id = ? AND status = ?", line.ID, models.ReservationActive).
+				First(&reservation).Error; err != nil {
+				return fmt.Errorf("reservation of product '%s' not found: %w", line.Product.Code, err)
+			}
+			movement, err := s.movements.build(tx, MovementDTO{
+				ProductID:     line.ProductID,
+				LocationID:    locationID,
+				Type:          string(models.MovementTypeOut),
+				Quantity:      shipment.Quantity,
+				Note:          note,
+				CustomerID:    order.CustomerID,
+				ReservationID: reservation.ID,
+			})
+			if err != nil {
+				return err
+			}
+			lineID := line.ID
+			movement.SalesOrderLineID = &lineID
+			if err := s.movements.save(tx, movement); err != nil {
+				return fmt.Errorf("cannot ship product '%s': %w", line.Product.Code, err)
+			}
+
+			line.Shipped += movement.Quantity
+			lines[line.ID] = line
+			if err := tx.Model(&models.SalesOrderLine{}).Where("id = ?", line.ID).
+				Update("shipped", line.Shipped).Error; err != nil {
+				return fmt.Errorf("failed to update shipped quantity: %w", err)
+			}
+		}
+
+		return refreshSalesOrderStatus(tx, order.ID)
+	})
+}
+
+// transition changes the state of an order inside a transaction and records it
+func (s *SalesOrderService) transition(id uint, change func(tx *gorm.DB, order *models.SalesOrder) error) (*SalesOrderDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var order *models.SalesOrder
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if order, err = s.load(tx, id); err != nil {
+			return err
+		}
+		before := s.toDTO(order)
+
+		if err := change(tx, order); err != nil {
+			return err
+		}
+
+		if order, err = s.load(tx, id); err != nil {
+			return err
+		}
+		return s.audit.record(tx, auditEntitySalesOrder, order.ID, models.AuditActionUpdate, before, s.toDTO(order))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(order)
+	return &resultDTO, nil
+}
+
+// unship takes back quantity from the shipped total of an order line when
+// its OUT movement is deleted, inside tx
+func unship(tx *gorm.DB, lineID uint, quantity models.Quantity) error {
+	var line models.SalesOrderLine
+	if err := tx.First(&line, lineID).Error; err != nil {
+		return fmt.Errorf("sales order line not found: %w", err)
+	}
+	if err := tx.Model(&line).Update("shipped", gorm.Expr("shipped - ?", quantity)).Error; err != nil {
+		return fmt.Errorf("failed to update shipped quantity: %w", err)
+	}
+	return refreshSalesOrderStatus(tx, line.OrderID)
+}
+
+// refreshSalesOrderStatus sets an open or shipped order to CONFIRMED,
+// PARTIAL or SHIPPED from what its lines have shipped, inside tx
+func refreshSalesOrderStatus(tx *gorm.DB, orderID uint) error {
+	var order models.SalesOrder
+	if err := tx.Preload("Lines").First(&order, orderID).Error; err != nil {
+		return fmt.Errorf("sales order not found: %w", err)
+	}
+	if !order.Status.IsOpen() && order.Status != models.SalesOrderShipped {
+		return nil
+	}
+
+	complete, started := true, false
+	for _, line := range order.Lines {
+		if line.Shipped < line.Quantity {
+			complete = false
+		}
+		if line.Shipped > 0 {
+			started = true
+		}
+	}
+
+	status := models.SalesOrderConfirmed
+	switch {
+	case complete:
+		status = models.SalesOrderShipped
+	case started:
+		status = models.SalesOrderPartial
+	}
+	if status == order.Status {
+		return nil
+	}
+
+	updates := map[string]interface{}{"status": status, "closed_at": nil}
+	if status == models.SalesOrderShipped {
+		updates["closed_at"] = time.Now()
+	}
+	if err := tx.Model(&order).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update sales order status: %w", err)
+	}
+	return nil
+}
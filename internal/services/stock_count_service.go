@@ -0,0 +1,530 @@
+package services
+
+import (
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockCountDTO is the data transfer object for stock count sessions
+type StockCountDTO struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Note       string     `json:"note"`
+	Status     string     `json:"status"`      // "OPEN", "POSTED" or "CANCELLED", read-only
+	CategoryID uint       `json:"category_id"` // 0 counts every product
+	Category   string     `json:"category"`    // Read-only
+	LocationID uint       `json:"location_id"` // Where stock is counted and differences booked; 0 means the default location
+	Location   string     `json:"location"`    // Read-only
+	OpenedBy   string     `json:"opened_by"`   // Read-only
+	ClosedBy   string     `json:"closed_by"`   // Read-only
+	CreatedAt  time.Time  `json:"created_at"`
+	ClosedAt   *time.Time `json:"closed_at"`
+
+	Products int64 `json:"products"` // Lines in the count, read-only
+	Counted  int64 `json:"counted"`  // Lines counted so far, read-only
+}
+
+// StockCountLineDTO is one product of a stock count
+type StockCountLineDTO struct {
+	ID         uint             `json:"id"`
+	ProductID  uint             `json:"product_id"`
+	Code       string           `json:"code"`
+	Name       string           `json:"name"`
+	CategoryID uint             `json:"category_id"`
+	Unit       string           `json:"unit"`
+	Expected   models.Quantity  `json:"expected"` // Stock at the count's location when it was opened
+	Counted    *models.Quantity `json:"counted"`  // nil until counted
+	Price      models.Money     `json:"price"`    // Price when the count was opened
+	CountedBy  string           `json:"counted_by"`
+	CountedAt  *time.Time       `json:"counted_at"`
+	MovementID uint             `json:"movement_id"` // ADJUSTMENT booked by posting, 0 if none
+
+	Difference      models.Quantity `json:"difference"`       // Counted - Expected, 0 until counted
+	ValueDifference models.Money    `json:"value_difference"` // Difference * Price
+}
+
+// StockCountVariance lists the differences of a count and their value
+type StockCountVariance struct {
+	Count     StockCountDTO       `json:"count"`
+	Lines     []StockCountLineDTO `json:"lines"`     // Counted lines that differ, by product code
+	Matched   int                 `json:"matched"`   // Counted lines without difference
+	Uncounted int                 `json:"uncounted"` // Lines not counted yet
+	Surplus   models.Money        `json:"surplus"`   // Value of stock found beyond the snapshot
+	Shortage  models.Money        `json:"shortage"`  // Value of stock missing, negative
+	Net       models.Money        `json:"net"`       // Surplus + Shortage
+}
+
+// StockCountService handles physical stock counts. Opening a count
+// snapshots the stock of its products; posting it books counted minus
+// snapshot as ADJUSTMENT movements, so movements recorded while counting
+// are kept.
+type StockCountService struct {
+	dbManager *database.ConnectionManager
+	audit     *AuditService
+	auth      *AuthService
+	movements *MovementService
+}
+
+// NewStockCountService creates a new stock count service
+func NewStockCountService(dbManager *database.ConnectionManager, audit *AuditService, auth *AuthService, movements *MovementService) *StockCountService {
+	return &StockCountService{
+		dbManager: dbManager,
+		audit:     audit,
+		auth:      auth,
+		movements: movements,
+	}
+}
+
+// Helper function to convert model to DTO
+func (s *StockCountService) toDTO(count *models.StockCount) StockCountDTO {
+	dto := StockCountDTO{
+		ID:         count.ID,
+		Name:       count.Name,
+		Note:       count.Note,
+		Status:     string(count.Status),
+		LocationID: count.LocationID,
+		Location:   count.Location.Name,
+		CreatedAt:  count.CreatedAt,
+		ClosedAt:   count.ClosedAt,
+	}
+	if count.CategoryID != nil {
+		dto.CategoryID = *count.CategoryID
+	}
+	if count.Category != nil {
+		dto.Category = count.Category.Name
+	}
+	if count.Opener != nil {
+		dto.OpenedBy = count.Opener.Username
+	}
+	if count.Closer != nil {
+		dto.ClosedBy = count.Closer.Username
+	}
+	return dto
+}
+
+// lineToDTO converts a line with its product and counter loaded
+func (s *StockCountService) lineToDTO(line *models.StockCountLine) StockCountLineDTO {
+	dto := StockCountLineDTO{
+		ID:         line.ID,
+		ProductID:  line.ProductID,
+		Code:       line.Product.Code,
+		Name:       line.Product.Name,
+		CategoryID: line.Product.CategoryID,
+		Unit:       line.Product.Unit,
+		Expected:   line.Expected,
+		Counted:    line.Counted,
+		Price:      line.Price,
+		CountedAt:  line.CountedAt,
+	}
+	if line.Counter != nil {
+		dto.CountedBy = line.Counter.Username
+	}
+	if line.MovementID != nil {
+		dto.MovementID = *line.MovementID
+	}
+	if line.Counted != nil {
+		dto.Difference = *line.Counted - line.Expected
+		dto.ValueDifference = dto.Difference.MulPrice(line.Price)
+	}
+	return dto
+}
+
+// preloadCount loads the relations StockCountDTO shows
+func preloadCount(db *gorm.DB) *gorm.DB {
+	return db.Preload("Category").Preload("Location").Preload("Opener").Preload("Closer")
+}
+
+// withProgress fills in the line totals of counts
+func (s *StockCountService) withProgress(db *gorm.DB, dtos []StockCountDTO) error {
+	if len(dtos) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(dtos))
+	for i, dto := range dtos {
+		ids[i] = dto.ID
+	}
+
+	var rows []struct {
+		CountID  uint
+		Products int64
+		Counted  int64
+	}
+	if err := db.Model(&models.StockCountLine{}).
+		Select("count_id, COUNT(*) AS products, COUNT(counted) AS counted").
+		Where("count_id IN ?", ids).Group("count_id").Scan(&rows).Error; err != nil {
+		return fmt.Errorf("failed to count stock count lines: %w", err)
+	}
+
+	byID := make(map[uint]int, len(dtos))
+	for i, dto := range dtos {
+		byID[dto.ID] = i
+	}
+	for _, row := range rows {
+		dtos[byID[row.CountID]].Products = row.Products
+		dtos[byID[row.CountID]].Counted = row.Counted
+	}
+	return nil
+}
+
+// GetAll returns all stock counts, newest first
+func (s *StockCountService) GetAll() ([]StockCountDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var counts []models.StockCount
+	if err := preloadCount(db).Order("created_at DESC, id DESC").Find(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stock counts: %w", err)
+	}
+
+	dtos := make([]StockCountDTO, len(counts))
+	for i := range counts {
+		dtos[i] = s.toDTO(&counts[i])
+	}
+	if err := s.withProgress(db, dtos); err != nil {
+		return nil, err
+	}
+	return dtos, nil
+}
+
+// GetByID returns a stock count by ID as DTO
+func (s *StockCountService) GetByID(id uint) (*StockCountDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+	return s.get(db, id)
+}
+
+// get loads a count with its progress
+func (s *StockCountService) get(db *gorm.DB, id uint) (*StockCountDTO, error) {
+	var count models.StockCount
+	if err := preloadCount(db).First(&count, id).Error; err != nil {
+		return nil, fmt.Errorf("stock count not found: %w", err)
+	}
+
+	dtos := []StockCountDTO{s.toDTO(&count)}
+	if err := s.withProgress(db, dtos); err != nil {
+		return nil, err
+	}
+	return &dtos[0], nil
+}
+
+// GetLines returns the products of a count by code; a categoryID other than
+// 0 limits them to one category so counters can split the work
+func (s *StockCountService) GetLines(countID, categoryID uint) ([]StockCountLineDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	if err := db.First(&models.StockCount{}, countID).Error; err != nil {
+		return nil, fmt.Errorf("stock count not found: %w", err)
+	}
+
+	lines, err := s.loadLines(db, countID, categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := make([]StockCountLineDTO, len(lines))
+	for i := range lines {
+		dtos[i] = s.lineToDTO(&lines[i])
+	}
+	return dtos, nil
+}
+
+// loadLines loads the lines of a count with their products, by product code
+func (s *StockCountService) loadLines(db *gorm.DB, countID, categoryID uint) ([]models.StockCountLine, error) {
+	query := db.Joins("Product").Preload("Counter").Where("stock_count_lines.count_id = ?", countID)
+	if categoryID != 0 {
+		query = query.Where("Product.category_id = ?", categoryID)
+	}
+
+	var lines []models.StockCountLine
+	if err := query.Order("Product.code ASC").Find(&lines).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stock count lines: %w", err)
+	}
+	return lines, nil
+}
+
+// Open starts a count of every product, or of one category's products, at a
+// location and snapshots their stock there and their price. Counts of the
+// same products at the same location cannot be open at the same time.
+func (s *StockCountService) Open(dto StockCountDTO) (*StockCountDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	if dto.Name == "" {
+		return nil, fmt.Errorf("stock count name cannot be empty")
+	}
+
+	count := &models.StockCount{
+		Name:       dto.Name,
+		Note:       dto.Note,
+		Status:     models.StockCountOpen,
+		LocationID: dto.LocationID,
+		OpenedBy:   s.auth.CurrentUserID(),
+	}
+	if dto.CategoryID != 0 {
+		categoryID := dto.CategoryID
+		count.CategoryID = &categoryID
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if count.CategoryID != nil {
+			if err := tx.First(&models.Category{}, *count.CategoryID).Error; err != nil {
+				return fmt.Errorf("category not found: %w", err)
+			}
+		}
+		if count.LocationID == 0 {
+			locationID, err := defaultLocationID(tx)
+			if err != nil {
+				return err
+			}
+			count.LocationID = locationID
+		}
+		if err := tx.First(&models.Location{}, count.LocationID).Error; err != nil {
+			return fmt.Errorf("location not found: %w", err)
+		}
+
+		// A product may only be in one open count per location
+		overlapping := tx.Where("status = ? AND location_id = ?", models.StockCountOpen, count.LocationID)
+		if count.CategoryID != nil {
+			overlapping = overlapping.Where("category_id IS NULL OR category_id = ?", *count.CategoryID)
+		}
+		var open models.StockCount
+		if err := overlapping.First(&open).Error; err == nil {
+			return conflictf("stock count '%s' already exists for these products and is still open", open.Name)
+		}
+
+		if err := tx.Create(count).Error; err != nil {
+			return fmt.Errorf("failed to create stock count: %w", err)
+		}
+
+		snapshot := `INSERT INTO stock_count_lines (count_id, product_id, expected, price)
+			SELECT ?, p.id, COALESCE(b.quantity, 0), p.price FROM products AS p
+			LEFT JOIN stock_balances AS b ON b.product_id = p.id AND b.location_id = ?`
+		args := []interface{}{count.ID, count.LocationID}
+		if count.CategoryID != nil {
+			snapshot += ` WHERE p.category_id = ?`
+			args = append(args, *count.CategoryID)
+		}
+		if err := tx.Exec(snapshot, args...).Error; err != nil {
+			return fmt.Errorf("failed to snapshot stock: %w", err)
+		}
+
+		if err := preloadCount(tx).First(count, count.ID).Error; err != nil {
+			return fmt.Errorf("stock count not found: %w", err)
+		}
+		return s.audit.record(tx, auditEntityStockCount, count.ID, models.AuditActionCreate, nil, s.toDTO(count))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.get(db, count.ID)
+}
+
+// SetCounted records the quantity counted of a product, in the product's
+// base unit. Counting a product again replaces the earlier quantity.
+func (s *StockCountService) SetCounted(countID, productID uint, counted models.Quantity) (*StockCountLineDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	if counted < 0 {
+		return nil, fmt.Errorf("counted quantity cannot be negative")
+	}
+
+	var line models.StockCountLine
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.openCount(tx, countID); err != nil {
+			return err
+		}
+
+		if err := tx.Joins("Product").Where("stock_count_lines.count_id = ? AND stock_count_lines.product_id = ?", countID, productID).
+			First(&line).Error; err != nil {
+			return fmt.Errorf("product not found in stock count: %w", err)
+		}
+		if err := checkPrecision(tx, line.Product.Unit, counted); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		line.Counted = &counted
+		line.CountedBy = s.auth.CurrentUserID()
+		line.CountedAt = &now
+		if err := tx.Model(&models.StockCountLine{}).Where("id = ?", line.ID).Updates(map[string]interface{}{
+			"counted":    line.Counted,
+			"counted_by": line.CountedBy,
+			"counted_at": line.CountedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to save counted quantity: %w", err)
+		}
+
+		if line.CountedBy != nil {
+			line.Counter = &models.User{}
+			if err := tx.First(line.Counter, *line.CountedBy).Error; err != nil {
+				return fmt.Errorf("user not found: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dto := s.lineToDTO(&line)
+	return &dto, nil
+}
+
+// Variance returns the counted lines that differ from the snapshot with the
+// value of the differences at the snapshot prices
+func (s *StockCountService) Variance(countID uint) (*StockCountVariance, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	count, err := s.get(db, countID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := s.loadLines(db, countID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	variance := &StockCountVariance{Count: *count, Lines: []StockCountLineDTO{}}
+	for i := range lines {
+		dto := s.lineToDTO(&lines[i])
+		switch {
+		case dto.Counted == nil:
+			variance.Uncounted++
+		case dto.Difference == 0:
+			variance.Matched++
+		default:
+			variance.Lines = append(variance.Lines, dto)
+			if dto.ValueDifference > 0 {
+				variance.Surplus += dto.ValueDifference
+			} else {
+				variance.Shortage += dto.ValueDifference
+			}
+		}
+	}
+	variance.Net = variance.Surplus + variance.Shortage
+	return variance, nil
+}
+
+// Post books the difference of every counted product as an ADJUSTMENT
+// movement at the count's location and closes the count, all in one
+// transaction. With zeroUncounted, products nobody counted are taken as
+// counted zero; otherwise their stock is left as it is.
+func (s *StockCountService) Post(countID uint, zeroUncounted bool) (*StockCountDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		count, err := s.openCount(tx, countID)
+		if err != nil {
+			return err
+		}
+		before := s.toDTO(count)
+
+		if zeroUncounted {
+			if err := tx.Model(&models.StockCountLine{}).Where("count_id = ? AND counted IS NULL", countID).Updates(map[string]interface{}{
+				"counted":    models.Quantity(0),
+				"counted_by": s.auth.CurrentUserID(),
+				"counted_at": time.Now(),
+			}).Error; err != nil {
+				return fmt.Errorf("failed to save counted quantity: %w", err)
+			}
+		}
+
+		lines, err := s.loadLines(tx, countID, 0)
+		if err != nil {
+			return err
+		}
+		note := fmt.Sprintf("Stock count #%d: %s", count.ID, count.Name)
+		for _, line := range lines {
+			if line.Counted == nil || *line.Counted == line.Expected {
+				continue
+			}
+			movement, err := s.movements.adjust(tx, line.ProductID, count.LocationID, *line.Counted-line.Expected, note)
+			if err != nil {
+				return fmt.Errorf("cannot post stock count for product '%s': %w", line.Product.Code, err)
+			}
+			if err := tx.Model(&models.StockCountLine{}).Where("id = ?", line.ID).Update("movement_id", movement.ID).Error; err != nil {
+				return fmt.Errorf("failed to link adjustment: %w", err)
+			}
+		}
+
+		return s.close(tx, count, models.StockCountPosted, before)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.get(db, countID)
+}
+
+// Cancel closes an open count without changing any stock
+func (s *StockCountService) Cancel(countID uint) (*StockCountDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		count, err := s.openCount(tx, countID)
+		if err != nil {
+			return err
+		}
+		return s.close(tx, count, models.StockCountCancelled, s.toDTO(count))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.get(db, countID)
+}
+
+// openCount loads a count inside tx and checks that it is still open
+func (s *StockCountService) openCount(tx *gorm.DB, countID uint) (*models.StockCount, error) {
+	var count models.StockCount
+	if err := preloadCount(tx).First(&count, countID).Error; err != nil {
+		return nil, fmt.Errorf("stock count not found: %w", err)
+	}
+	if count.Status != models.StockCountOpen {
+		return nil, conflictf("cannot change stock count '%s': it is %s", count.Name, count.Status)
+	}
+	return &count, nil
+}
+
+// close sets the final status of a count inside tx
+func (s *StockCountService) close(tx *gorm.DB, count *models.StockCount, status models.StockCountStatus, before StockCountDTO) error {
+	now := time.Now()
+	if err := tx.Model(count).Updates(map[string]interface{}{
+		"status":    status,
+		"closed_by": s.auth.CurrentUserID(),
+		"closed_at": now,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to close stock count: %w", err)
+	}
+
+	if err := preloadCount(tx).First(count, count.ID).Error; err != nil {
+		return fmt.Errorf("stock count not found: %w", err)
+	}
+	return s.audit.record(tx, auditEntityStockCount, count.ID, models.AuditActionUpdate, before, s.toDTO(count))
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SupplierDTO is the data transfer object for suppliers
+type SupplierDTO struct {
+	ID              uint      `json:"id"`
+	Name            string    `json:"name"`
+	TaxNumber       string    `json:"tax_number"`
+	Contact         string    `json:"contact"`
+	PaymentTermDays int       `json:"payment_term_days"`
+	Phone           string    `json:"phone"`
+	Email           string    `json:"email"`
+	Note            string    `json:"note"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// SupplierService handles supplier-related operations
+type SupplierService struct {
+	dbManager *database.ConnectionManager
+	audit     *AuditService
+}
+
+// NewSupplierService creates a new supplier service
+func NewSupplierService(dbManager *database.ConnectionManager, audit *AuditService) *SupplierService {
+	return &SupplierService{
+		dbManager: dbManager,
+		audit:     audit,
+	}
+}
+
+// Helper function to convert model to DTO
+func (s *SupplierService) toDTO(supplier *models.Supplier) SupplierDTO {
+	return SupplierDTO{
+		ID:              supplier.ID,
+		Name:            supplier.Name,
+		TaxNumber:       supplier.TaxNumber,
+		Contact:         supplier.Contact,
+		PaymentTermDays: supplier.PaymentTermDays,
+		Phone:           supplier.Phone,
+		Email:           supplier.Email,
+		Note:            supplier.Note,
+		CreatedAt:       supplier.CreatedAt,
+		UpdatedAt:       supplier.UpdatedAt,
+	}
+}
+
+// GetAll returns all suppliers by name
+func (s *SupplierService) GetAll() ([]SupplierDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var suppliers []models.Supplier
+	if err := db.Order("name ASC").Find(&suppliers).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch suppliers: %w", err)
+	}
+
+	dtos := make([]SupplierDTO, len(suppliers))
+	for i := range suppliers {
+		dtos[i] = s.toDTO(&suppliers[i])
+	}
+	return dtos, nil
+}
+
+// GetByID returns a supplier by ID as DTO
+func (s *SupplierService) GetByID(id uint) (*SupplierDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var supplier models.Supplier
+	if err := db.First(&supplier, id).Error; err != nil {
+		return nil, fmt.Errorf("supplier not found: %w", err)
+	}
+
+	dto := s.toDTO(&supplier)
+	return &dto, nil
+}
+
+// Create creates a new supplier from DTO
+func (s *SupplierService) Create(dto SupplierDTO) (*SupplierDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	supplier := &models.Supplier{}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := s.assign(tx, supplier, dto); err != nil {
+			return err
+		}
+		if err := tx.Create(supplier).Error; err != nil {
+			return fmt.Errorf("failed to create supplier: %w", err)
+		}
+		return s.audit.record(tx, auditEntitySupplier, supplier.ID, models.AuditActionCreate, nil, s.toDTO(supplier))
+	}); err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(supplier)
+	return &resultDTO, nil
+}
+
+// Update updates an existing supplier
+func (s *SupplierService) Update(id uint, dto SupplierDTO) (*SupplierDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var supplier models.Supplier
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&supplier, id).Error; err != nil {
+			return fmt.Errorf("supplier not found: %w", err)
+		}
+		before := s.toDTO(&supplier)
+
+		if err := s.assign(tx, &supplier, dto); err != nil {
+			return err
+		}
+		if err := tx.Save(&supplier).Error; err != nil {
+			return fmt.Errorf("failed to update supplier: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntitySupplier, supplier.ID, models.AuditActionUpdate, before, s.toDTO(&supplier))
+	}); err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(&supplier)
+	return &resultDTO, nil
+}
+
+// assign validates dto and copies its fields to supplier
+func (s *SupplierService) assign(tx *gorm.DB, supplier *models.Supplier, dto SupplierDTO) error {
+	name := strings.TrimSpace(dto.Name)
+	if name == "" {
+		return fmt.Errorf("supplier name cannot be empty")
+	}
+
+	if dto.PaymentTermDays < 0 {
+		return fmt.Errorf("payment term cannot be negative")
+	}
+
+	var existing models.Supplier
+	if err := tx.Where("name = ? AND id <> ?", name, supplier.ID).First(&existing).Error; err == nil {
+		return conflictf("supplier with name '%s' already exists", name)
+	}
+
+	supplier.Name = name
+	supplier.TaxNumber = strings.TrimSpace(dto.TaxNumber)
+	supplier.Contact = strings.TrimSpace(dto.Contact)
+	supplier.PaymentTermDays = dto.PaymentTermDays
+	supplier.Phone = strings.TrimSpace(dto.Phone)
+	supplier.Email = strings.TrimSpace(dto.Email)
+	supplier.Note = dto.Note
+	return nil
+}
+
+// Delete deletes a supplier that has no purchase orders
+func (s *SupplierService) Delete(id uint) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var supplier models.Supplier
+		if err := tx.First(&supplier, id).Error; err != nil {
+			return fmt.Errorf("supplier not found: %w", err)
+		}
+
+		var orderCount int64
+		if err := tx.Model(&models.PurchaseOrder{}).Where("supplier_id = ?", id).Count(&orderCount).Error; err != nil {
+			return fmt.Errorf("failed to check purchase orders: %w", err)
+		}
+		if orderCount > 0 {
+			return conflictf("cannot delete supplier with %d purchase orders", orderCount)
+		}
+
+		if err := tx.Where("supplier_id = ?", id).Delete(&models.ProductSupplier{}).Error; err != nil {
+			return fmt.Errorf("failed to delete product suppliers: %w", err)
+		}
+		if err := tx.Delete(&models.Supplier{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete supplier: %w", err)
+		}
+
+		return s.audit.record(tx, auditEntitySupplier, supplier.ID, models.AuditActionDelete, s.toDTO(&supplier), nil)
+	})
+}
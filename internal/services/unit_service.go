@@ -0,0 +1,506 @@
+package services
+
+import (
+	"fmt"
+	"stoktakip/internal/database"
+	"stoktakip/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UnitDTO is the data transfer object for units of measure
+type UnitDTO struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Decimals  int       `json:"decimals"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UnitConversionDTO is the data transfer object for unit conversions
+type UnitConversionDTO struct {
+	ID         uint            `json:"id"`
+	ProductID  uint            `json:"product_id"` // 0 means the conversion applies to every product
+	FromUnitID uint            `json:"from_unit_id"`
+	FromUnit   string          `json:"from_unit"`
+	ToUnitID   uint            `json:"to_unit_id"`
+	ToUnit     string          `json:"to_unit"`
+	Factor     models.Quantity `json:"factor"` // ToUnit per one FromUnit
+}
+
+// UnitService handles unit-of-measure operations
+type UnitService struct {
+	dbManager *database.ConnectionManager
+}
+
+// NewUnitService creates a new unit service
+func NewUnitService(dbManager *database.ConnectionManager) *UnitService {
+	return &UnitService{
+		dbManager: dbManager,
+	}
+}
+
+// Helper function to convert model to DTO
+func (s *UnitService) toDTO(unit *models.Unit) UnitDTO {
+	return UnitDTO{
+		ID:        unit.ID,
+		Name:      unit.Name,
+		Decimals:  unit.Decimals,
+		CreatedAt: unit.CreatedAt,
+		UpdatedAt: unit.UpdatedAt,
+	}
+}
+
+// GetAll returns all units as DTOs
+func (s *UnitService) GetAll() ([]UnitDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var units []models.Unit
+	if err := db.Order("name ASC").Find(&units).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch units: %w", err)
+	}
+
+	dtos := make([]UnitDTO, len(units))
+	for i, unit := range units {
+		dtos[i] = s.toDTO(&unit)
+	}
+
+	return dtos, nil
+}
+
+// GetByID returns a unit by ID as DTO
+func (s *UnitService) GetByID(id uint) (*UnitDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var unit models.Unit
+	if err := db.First(&unit, id).Error; err != nil {
+		return nil, fmt.Errorf("unit not found: %w", err)
+	}
+
+	dto := s.toDTO(&unit)
+	return &dto, nil
+}
+
+// Create creates a new unit from DTO
+func (s *UnitService) Create(dto UnitDTO) (*UnitDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	name := strings.TrimSpace(dto.Name)
+	if err := s.validate(name, dto.Decimals); err != nil {
+		return nil, err
+	}
+
+	// Names are unique regardless of case
+	var existing models.Unit
+	if err := db.Where("name = ?", name).First(&existing).Error; err == nil {
+		return nil, conflictf("unit with name '%s' already exists", existing.Name)
+	}
+
+	unit := &models.Unit{
+		Name:     name,
+		Decimals: dto.Decimals,
+	}
+
+	if err := db.Create(unit).Error; err != nil {
+		return nil, fmt.Errorf("failed to create unit: %w", err)
+	}
+
+	resultDTO := s.toDTO(unit)
+	return &resultDTO, nil
+}
+
+// Update updates a unit from DTO; products using it follow a rename
+func (s *UnitService) Update(id uint, dto UnitDTO) (*UnitDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var unit models.Unit
+	if err := db.First(&unit, id).Error; err != nil {
+		return nil, fmt.Errorf("unit not found: %w", err)
+	}
+
+	name := strings.TrimSpace(dto.Name)
+	if err := s.validate(name, dto.Decimals); err != nil {
+		return nil, err
+	}
+
+	// Check if another unit with same name exists
+	var existing models.Unit
+	if err := db.Where("name = ? AND id != ?", name, id).First(&existing).Error; err == nil {
+		return nil, conflictf("unit with name '%s' already exists", existing.Name)
+	}
+
+	unit.Name = name
+	unit.Decimals = dto.Decimals
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&unit).Error; err != nil {
+			return fmt.Errorf("failed to update unit: %w", err)
+		}
+		if err := tx.Model(&models.Product{}).Where("unit_id = ?", unit.ID).Update("unit", unit.Name).Error; err != nil {
+			return fmt.Errorf("failed to rename unit on products: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultDTO := s.toDTO(&unit)
+	return &resultDTO, nil
+}
+
+// Delete deletes a unit that no product, movement or conversion uses
+func (s *UnitService) Delete(id uint) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	var productCount int64
+	if err := db.Model(&models.Product{}).Where("unit_id = ?", id).Count(&productCount).Error; err != nil {
+		return fmt.Errorf("failed to check products: %w", err)
+	}
+	if productCount > 0 {
+		return conflictf("cannot delete unit used by %d products", productCount)
+	}
+
+	var movementCount int64
+	if err := db.Model(&models.StockMovement{}).Where("entered_unit_id = ?", id).Count(&movementCount).Error; err != nil {
+		return fmt.Errorf("failed to check movements: %w", err)
+	}
+	if movementCount > 0 {
+		return conflictf("cannot delete unit used by %d movements", movementCount)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("from_unit_id = ? OR to_unit_id = ?", id, id).Delete(&models.UnitConversion{}).Error; err != nil {
+			return fmt.Errorf("failed to delete unit conversions: %w", err)
+		}
+		if err := tx.Delete(&models.Unit{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete unit: %w", err)
+		}
+		return nil
+	})
+}
+
+// validate checks a unit name and precision
+func (s *UnitService) validate(name string, decimals int) error {
+	if name == "" {
+		return fmt.Errorf("unit name cannot be empty")
+	}
+	if len(name) > 20 {
+		return fmt.Errorf("unit name cannot be longer than 20 characters")
+	}
+	if decimals < 0 || decimals > models.QuantityDecimals {
+		return fmt.Errorf("decimals must be between 0 and %d", models.QuantityDecimals)
+	}
+	return nil
+}
+
+// SetPrecision sets how many decimals quantities in a unit may use, creating the unit if needed
+func (s *UnitService) SetPrecision(name string, decimals int) (*UnitDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	name = strings.TrimSpace(name)
+	if err := s.validate(name, decimals); err != nil {
+		return nil, err
+	}
+
+	var unit models.Unit
+	err := db.Where("name = ?", name).First(&unit).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to fetch unit: %w", err)
+	}
+
+	if unit.ID == 0 {
+		unit.Name = name
+	}
+	unit.Decimals = decimals
+	if err := db.Save(&unit).Error; err != nil {
+		return nil, fmt.Errorf("failed to save unit: %w", err)
+	}
+
+	dto := s.toDTO(&unit)
+	return &dto, nil
+}
+
+// GetConversions returns the conversions that apply to a product, including
+// the general ones; productID 0 returns only the general conversions
+func (s *UnitService) GetConversions(productID uint) ([]UnitConversionDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var conversions []models.UnitConversion
+	if err := db.Preload("FromUnit").Preload("ToUnit").
+		Where("product_id IS NULL OR product_id = ?", productID).
+		Order("product_id IS NULL, id ASC").
+		Find(&conversions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch unit conversions: %w", err)
+	}
+
+	dtos := make([]UnitConversionDTO, len(conversions))
+	for i, conversion := range conversions {
+		dtos[i] = s.conversionToDTO(&conversion)
+	}
+
+	return dtos, nil
+}
+
+// SaveConversion creates a conversion, or updates it when dto.ID is set
+func (s *UnitService) SaveConversion(dto UnitConversionDTO) (*UnitConversionDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	// Validate
+	if dto.FromUnitID == 0 || dto.ToUnitID == 0 {
+		return nil, fmt.Errorf("both units are required")
+	}
+	if dto.FromUnitID == dto.ToUnitID {
+		return nil, fmt.Errorf("a unit cannot be converted to itself")
+	}
+	if dto.Factor <= 0 {
+		return nil, fmt.Errorf("conversion factor must be greater than zero")
+	}
+	for _, id := range []uint{dto.FromUnitID, dto.ToUnitID} {
+		if err := db.First(&models.Unit{}, id).Error; err != nil {
+			return nil, fmt.Errorf("unit not found: %w", err)
+		}
+	}
+	if dto.ProductID != 0 {
+		if err := db.First(&models.Product{}, dto.ProductID).Error; err != nil {
+			return nil, fmt.Errorf("product not found: %w", err)
+		}
+	}
+
+	// Only one conversion per unit pair and scope, in either direction
+	query := db.Model(&models.UnitConversion{}).
+		Where("((from_unit_id = ? AND to_unit_id = ?) OR (from_unit_id = ? AND to_unit_id = ?)) AND id != ?",
+			dto.FromUnitID, dto.ToUnitID, dto.ToUnitID, dto.FromUnitID, dto.ID)
+	if dto.ProductID == 0 {
+		query = query.Where("product_id IS NULL")
+	} else {
+		query = query.Where("product_id = ?", dto.ProductID)
+	}
+	var duplicates int64
+	if err := query.Count(&duplicates).Error; err != nil {
+		return nil, fmt.Errorf("failed to check unit conversions: %w", err)
+	}
+	if duplicates > 0 {
+		return nil, conflictf("a conversion between these units already exists")
+	}
+
+	var conversion models.UnitConversion
+	if dto.ID != 0 {
+		if err := db.First(&conversion, dto.ID).Error; err != nil {
+			return nil, fmt.Errorf("unit conversion not found: %w", err)
+		}
+	}
+
+	conversion.ProductID = nil
+	if dto.ProductID != 0 {
+		productID := dto.ProductID
+		conversion.ProductID = &productID
+	}
+	conversion.FromUnitID = dto.FromUnitID
+	conversion.ToUnitID = dto.ToUnitID
+	conversion.Factor = dto.Factor
+
+	if err := db.Save(&conversion).Error; err != nil {
+		return nil, fmt.Errorf("failed to save unit conversion: %w", err)
+	}
+
+	if err := db.Preload("FromUnit").Preload("ToUnit").First(&conversion, conversion.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to reload unit conversion: %w", err)
+	}
+
+	resultDTO := s.conversionToDTO(&conversion)
+	return &resultDTO, nil
+}
+
+// DeleteConversion deletes a unit conversion by ID
+func (s *UnitService) DeleteConversion(id uint) error {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return fmt.Errorf("no database connection")
+	}
+
+	if err := db.Delete(&models.UnitConversion{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete unit conversion: %w", err)
+	}
+
+	return nil
+}
+
+// GetAllowedUnits returns the units a product's movements may be recorded in:
+// its base unit and every unit that converts to or from it
+func (s *UnitService) GetAllowedUnits(productID uint) ([]UnitDTO, error) {
+	db := s.dbManager.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("no database connection")
+	}
+
+	var product models.Product
+	if err := db.First(&product, productID).Error; err != nil {
+		return nil, fmt.Errorf("product not found: %w", err)
+	}
+	if product.UnitID == nil {
+		return []UnitDTO{}, nil
+	}
+
+	var units []models.Unit
+	if err := db.Where(`id = ? OR id IN (
+			SELECT from_unit_id FROM unit_conversions WHERE to_unit_id = ? AND (product_id IS NULL OR product_id = ?)
+			UNION
+			SELECT to_unit_id FROM unit_conversions WHERE from_unit_id = ? AND (product_id IS NULL OR product_id = ?)
+		)`, *product.UnitID, *product.UnitID, productID, *product.UnitID, productID).
+		Order("name ASC").
+		Find(&units).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch units: %w", err)
+	}
+
+	dtos := make([]UnitDTO, len(units))
+	for i, unit := range units {
+		dtos[i] = s.toDTO(&unit)
+	}
+
+	return dtos, nil
+}
+
+// Helper function to convert conversion model to DTO
+func (s *UnitService) conversionToDTO(conversion *models.UnitConversion) UnitConversionDTO {
+	dto := UnitConversionDTO{
+		ID:         conversion.ID,
+		FromUnitID: conversion.FromUnitID,
+		FromUnit:   conversion.FromUnit.Name,
+		ToUnitID:   conversion.ToUnitID,
+		ToUnit:     conversion.ToUnit.Name,
+		Factor:     conversion.Factor,
+	}
+	if conversion.ProductID != nil {
+		dto.ProductID = *conversion.ProductID
+	}
+	return dto
+}
+
+// resolveUnit finds a catalog unit by ID, or by case-insensitive name when id is 0
+func resolveUnit(tx *gorm.DB, id uint, name string) (*models.Unit, error) {
+	var unit models.Unit
+	if id != 0 {
+		if err := tx.First(&unit, id).Error; err != nil {
+			return nil, fmt.Errorf("unit not found: %w", err)
+		}
+		return &unit, nil
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("unit is required")
+	}
+	if err := tx.Where("name = ?", name).First(&unit).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("unknown unit '%s'; add it to the unit list first", name)
+		}
+		return nil, fmt.Errorf("failed to fetch unit: %w", err)
+	}
+	return &unit, nil
+}
+
+// toBaseQuantity converts a quantity recorded in unit into the product's base
+// unit. Product-specific conversions win over general ones, and a conversion
+// may be used in reverse. The result must be exact.
+func toBaseQuantity(tx *gorm.DB, product *models.Product, unit *models.Unit, quantity models.Quantity) (models.Quantity, error) {
+	if product.UnitID == nil {
+		return 0, fmt.Errorf("product '%s' has no base unit", product.Code)
+	}
+	if unit.ID == *product.UnitID {
+		return quantity, nil
+	}
+
+	var conversions []models.UnitConversion
+	if err := tx.Where(`((from_unit_id = ? AND to_unit_id = ?) OR (from_unit_id = ? AND to_unit_id = ?))
+			AND (product_id IS NULL OR product_id = ?)`,
+		unit.ID, *product.UnitID, *product.UnitID, unit.ID, product.ID).
+		Order("product_id IS NULL").
+		Find(&conversions).Error; err != nil {
+		return 0, fmt.Errorf("failed to fetch unit conversions: %w", err)
+	}
+	if len(conversions) == 0 {
+		return 0, fmt.Errorf("no conversion from '%s' to '%s' for product '%s'", unit.Name, product.Unit, product.Code)
+	}
+
+	conversion := conversions[0]
+	var (
+		normalized models.Quantity
+		ok         bool
+	)
+	if conversion.FromUnitID == unit.ID {
+		normalized, ok = quantity.MulExact(conversion.Factor)
+	} else {
+		normalized, ok = quantity.DivExact(conversion.Factor)
+	}
+	if !ok {
+		return 0, fmt.Errorf("%s %s cannot be expressed exactly in '%s'", quantity, unit.Name, product.Unit)
+	}
+
+	return normalized, nil
+}
+
+// unitDecimals returns the precision of a unit; unknown units are whole-numbered
+func unitDecimals(tx *gorm.DB, name string) (int, error) {
+	var unit models.Unit
+	err := tx.Where("name = ?", strings.TrimSpace(name)).First(&unit).Error
+	if err == gorm.ErrRecordNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch unit: %w", err)
+	}
+	return unit.Decimals, nil
+}
+
+// loadUnitDecimals returns the precision of every unit by lower-case name
+func loadUnitDecimals(db *gorm.DB) (map[string]int, error) {
+	var units []models.Unit
+	if err := db.Find(&units).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch units: %w", err)
+	}
+
+	decimals := make(map[string]int, len(units))
+	for _, unit := range units {
+		decimals[strings.ToLower(unit.Name)] = unit.Decimals
+	}
+	return decimals, nil
+}
+
+// checkPrecision refuses quantities with more decimals than their unit allows
+func checkPrecision(tx *gorm.DB, unitName string, quantity models.Quantity) error {
+	decimals, err := unitDecimals(tx, unitName)
+	if err != nil {
+		return err
+	}
+	if quantity.Decimals() > decimals {
+		return fmt.Errorf("quantity %s has more decimals than unit '%s' allows (%d)", quantity, unitName, decimals)
+	}
+	return nil
+}
@@ -37,6 +37,21 @@ func (pm *PathManager) GetDataFolder() string {
 	return filepath.Join(pm.rootPath, "Data")
 }
 
+// GetBackupFolder returns the path to the Backups folder
+func (pm *PathManager) GetBackupFolder() string {
+	return filepath.Join(pm.rootPath, "Backups")
+}
+
+// GetBackupPath returns the full path to a backup file
+func (pm *PathManager) GetBackupPath(filename string) string {
+	return filepath.Join(pm.GetBackupFolder(), filename)
+}
+
+// EnsureBackupFolder creates the Backups folder if it doesn't exist
+func (pm *PathManager) EnsureBackupFolder() error {
+	return os.MkdirAll(pm.GetBackupFolder(), 0755)
+}
+
 // GetConfigPath returns the path to the config.json file
 func (pm *PathManager) GetConfigPath() string {
 	return filepath.Join(pm.rootPath, "config.json")
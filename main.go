@@ -3,8 +3,10 @@ package main
 import (
 	"embed"
 	"log"
+	"os"
 
 	"stoktakip/internal/app"
+	"stoktakip/internal/cli"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -16,6 +18,11 @@ import (
 var assets embed.FS
 
 func main() {
+	// Run command-line tasks without opening the window
+	if cli.IsCommand(os.Args[1:]) {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	// Create application instance
 	application, err := app.NewApp()
 	if err != nil {